@@ -1,6 +1,8 @@
 package container
 
 import (
+	"context"
+
 	"icctv-http-service/controllers"
 	"icctv-http-service/databases"
 	"icctv-http-service/middlewares"
@@ -12,13 +14,33 @@ import (
 
 // ServiceSet 聚合所有业务服务
 type ServiceSet struct {
-	Admin     *services.AdminService
-	Auth      *services.AuthService
-	OrangePi  *services.OrangePiService
-	Building  *services.BuildingService
-	Device    *services.DeviceService
-	PublicNet *services.PublicNetService
-	NVR       *services.NVRService
+	Admin          *services.AdminService
+	Auth           *services.AuthService
+	OrangePi       *services.OrangePiService
+	Building       *services.BuildingService
+	Device         *services.DeviceService
+	PublicNet      *services.PublicNetService
+	NVR            *services.NVRService
+	Registration   *services.RegistrationService
+	PortPool       *services.PortPoolService
+	PortMigration  *services.PortMigrationService
+	Job            *services.JobService
+	Reconcile      *services.ReconcileService
+	Audit          *services.AuditService
+	ServiceControl *services.ServiceControlService
+	Provisioning   *services.ProvisioningService
+	ConfigSnapshot *services.ConfigSnapshotService
+	Rollout        *services.RolloutService
+	Stream         *services.StreamService
+	MediaMTXSync   *services.MediaMTXSyncService
+	Recording      *services.RecordingService
+	Live           *services.LiveService
+	Lifecycle      *services.LifecycleService
+	BindingHistory *services.BindingHistoryService
+	Trash          *services.TrashService
+	Site           *services.SiteService
+	Topology       *services.TopologyService
+	ISmartSync     *services.ISmartSyncService
 }
 
 // Container 提供项目运行所需的依赖
@@ -38,26 +60,70 @@ func Build() (*Container, error) {
 
 	serviceSet := ServiceSet{
 		Admin:     services.NewAdminService(db),
-		Building:  services.NewBuildingService(db),
 		Device:    services.NewDeviceService(db),
 		PublicNet: services.NewPublicNetService(db),
 		NVR:       services.NewNVRService(db),
+		PortPool:  services.NewPortPoolService(db),
+		Job:       services.NewJobService(db),
+		Audit:     services.NewAuditService(db),
 	}
-	serviceSet.OrangePi = services.NewOrangePiService(db, serviceSet.PublicNet)
+	serviceSet.Site = services.NewSiteService(db, serviceSet.Audit)
+	serviceSet.OrangePi = services.NewOrangePiService(db, serviceSet.PublicNet, serviceSet.PortPool)
+	serviceSet.Building = services.NewBuildingService(db, serviceSet.OrangePi, serviceSet.Audit)
 	serviceSet.Auth = services.NewAuthService(db, serviceSet.Admin, serviceSet.OrangePi, serviceSet.Building)
+	serviceSet.Registration = services.NewRegistrationService(db, serviceSet.PortPool, serviceSet.Auth)
+	serviceSet.PortMigration = services.NewPortMigrationService(db, serviceSet.OrangePi, serviceSet.PortPool)
+	serviceSet.Reconcile = services.NewReconcileService(db, serviceSet.OrangePi, serviceSet.PublicNet, serviceSet.PortMigration)
+	serviceSet.ServiceControl = services.NewServiceControlService(db, serviceSet.OrangePi, serviceSet.Building, serviceSet.Audit)
+	serviceSet.Provisioning = services.NewProvisioningService(db, serviceSet.PublicNet, serviceSet.Auth, serviceSet.Audit)
+	serviceSet.ConfigSnapshot = services.NewConfigSnapshotService(db, serviceSet.OrangePi, serviceSet.Provisioning)
+	serviceSet.Stream = services.NewStreamService(db, serviceSet.OrangePi)
+	serviceSet.Topology = services.NewTopologyService(db, serviceSet.OrangePi, serviceSet.Stream)
+	serviceSet.MediaMTXSync = services.NewMediaMTXSyncService(db, serviceSet.OrangePi, serviceSet.Audit)
+	serviceSet.Recording = services.NewRecordingService(db, serviceSet.OrangePi, serviceSet.PublicNet, serviceSet.Auth)
+	serviceSet.Live = services.NewLiveService(db, serviceSet.OrangePi, serviceSet.PublicNet, serviceSet.Auth)
+	serviceSet.Lifecycle = services.NewLifecycleService(db, serviceSet.Audit)
+	serviceSet.BindingHistory = services.NewBindingHistoryService(db)
+	serviceSet.Trash = services.NewTrashService(db, serviceSet.PortPool, serviceSet.Audit)
+	serviceSet.ISmartSync = services.NewISmartSyncService(db, serviceSet.Audit)
+	serviceSet.Rollout = services.NewRolloutService(db, serviceSet.OrangePi, serviceSet.Job, serviceSet.Audit)
+	serviceSet.OrangePi.RegisterJobHandlers(serviceSet.Job)
+	serviceSet.ServiceControl.RegisterJobHandlers(serviceSet.Job)
+	serviceSet.ConfigSnapshot.RegisterJobHandlers(serviceSet.Job)
+	serviceSet.Rollout.RegisterJobHandlers(serviceSet.Job)
+	serviceSet.PortMigration.RegisterJobHandlers(serviceSet.Job)
 
 	ctrlSet := routes.ControllerSet{
-		Auth:      controllers.NewAuthController(serviceSet.Auth),
-		Admin:     controllers.NewAdminController(serviceSet.Admin),
-		OrangePi:  controllers.NewOrangePiController(serviceSet.OrangePi),
-		Building:  controllers.NewBuildingController(serviceSet.Building),
-		Device:    controllers.NewDeviceController(serviceSet.Device, serviceSet.OrangePi),
-		PublicNet: controllers.NewPublicNetController(serviceSet.PublicNet),
-		NVR:       controllers.NewNVRController(serviceSet.NVR),
+		Auth:           controllers.NewAuthController(serviceSet.Auth, serviceSet.Site),
+		Admin:          controllers.NewAdminController(serviceSet.Admin),
+		OrangePi:       controllers.NewOrangePiController(serviceSet.OrangePi, serviceSet.PortMigration, serviceSet.Job, serviceSet.Site),
+		Building:       controllers.NewBuildingController(serviceSet.Building, serviceSet.Stream, serviceSet.Site),
+		Device:         controllers.NewDeviceController(serviceSet.Device, serviceSet.OrangePi),
+		PublicNet:      controllers.NewPublicNetController(serviceSet.PublicNet),
+		NVR:            controllers.NewNVRController(serviceSet.NVR, serviceSet.Site),
+		Registration:   controllers.NewRegistrationController(serviceSet.Registration),
+		PortMigration:  controllers.NewPortMigrationController(serviceSet.PortMigration),
+		Job:            controllers.NewJobController(serviceSet.Job),
+		Reconcile:      controllers.NewReconcileController(serviceSet.Reconcile),
+		ServiceControl: controllers.NewServiceControlController(serviceSet.ServiceControl),
+		Audit:          controllers.NewAuditController(serviceSet.Audit),
+		Provisioning:   controllers.NewProvisioningController(serviceSet.Provisioning),
+		ConfigSnapshot: controllers.NewConfigSnapshotController(serviceSet.ConfigSnapshot),
+		Rollout:        controllers.NewRolloutController(serviceSet.Rollout),
+		Stream:         controllers.NewStreamController(serviceSet.Stream),
+		MediaMTXSync:   controllers.NewMediaMTXSyncController(serviceSet.MediaMTXSync),
+		Recording:      controllers.NewRecordingController(serviceSet.Recording),
+		Live:           controllers.NewLiveController(serviceSet.Live),
+		Lifecycle:      controllers.NewLifecycleController(serviceSet.Lifecycle),
+		BindingHistory: controllers.NewBindingHistoryController(serviceSet.BindingHistory),
+		Trash:          controllers.NewTrashController(serviceSet.Trash),
+		Site:           controllers.NewSiteController(serviceSet.Site),
+		Topology:       controllers.NewTopologyController(serviceSet.Topology),
+		ISmartSync:     controllers.NewISmartSyncController(serviceSet.ISmartSync),
 	}
 
 	middlewareSet := routes.MiddlewareSet{
-		Auth: middlewares.NewAuthMiddleware(serviceSet.Auth),
+		Auth: middlewares.NewAuthMiddleware(serviceSet.Auth, serviceSet.Site),
 	}
 
 	return &Container{
@@ -67,3 +133,17 @@ func Build() (*Container, error) {
 		Middlewares: middlewareSet,
 	}, nil
 }
+
+// StartBackground 启动后台任务（异步任务调度器、周期对账、配置快照、推流状态采样、MediaMTX 路径自动同步、回收站保留期清理、iSmart 楼栋定时同步），ctx 结束时停止
+func (c *Container) StartBackground(ctx context.Context) error {
+	if err := c.Services.Job.Start(ctx); err != nil {
+		return err
+	}
+	c.Services.Reconcile.Start(ctx)
+	c.Services.ConfigSnapshot.Start(ctx)
+	c.Services.Stream.Start(ctx)
+	c.Services.MediaMTXSync.Start(ctx)
+	c.Services.Trash.Start(ctx)
+	c.Services.ISmartSync.Start(ctx)
+	return nil
+}
@@ -80,7 +80,7 @@ func (c *AdminController) Create(w http.ResponseWriter, r *http.Request) {
 	}
 	admin, err := c.service.Create(r.Context(), req.Username, req.Password)
 	if err != nil {
-		respondError(w, http.StatusBadRequest, err.Error())
+		respondServiceError(w, err, http.StatusBadRequest)
 		return
 	}
 	respondData(w, http.StatusCreated, admin)
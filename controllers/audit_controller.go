@@ -0,0 +1,61 @@
+package controllers
+
+// AuditController Methods:
+//0. NewAuditController(service *services.AuditService) -> 注入 AuditService
+//1. List(w http.ResponseWriter, r *http.Request) -> 分页查询审计日志
+
+import (
+	"net/http"
+	"strconv"
+
+	"icctv-http-service/models"
+	"icctv-http-service/services"
+)
+
+// AuditControllerInterface 定义审计日志接口能力
+type AuditControllerInterface interface {
+	List(w http.ResponseWriter, r *http.Request) //1.查询日志
+}
+
+// AuditController 审计日志接口
+type AuditController struct {
+	service *services.AuditService
+}
+
+// 0. NewAuditController 构造函数
+func NewAuditController(service *services.AuditService) *AuditController {
+	return &AuditController{service: service}
+}
+
+// 1. List 分页查询审计日志，可按 action / target_type / target_id / actor 筛选
+func (c *AuditController) List(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := services.AuditListQuery{
+		PaginationQuery: models.PaginationQuery{
+			PageNum:  parseInt(q.Get("pageNum"), 1),
+			PageSize: parseInt(q.Get("pageSize"), 20),
+			Asc:      q.Get("asc") == "true",
+		},
+		Action:     q.Get("action"),
+		TargetType: q.Get("target_type"),
+		Actor:      q.Get("actor"),
+	}
+	if idStr := q.Get("target_id"); idStr != "" {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid target_id")
+			return
+		}
+		query.TargetID = id
+	}
+
+	logs, pageResult, err := c.service.List(r.Context(), query)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondData(w, http.StatusOK, map[string]interface{}{
+		"items": logs,
+		"page":  pageResult,
+	})
+}
@@ -1,9 +1,10 @@
 package controllers
 
 // AuthController Methods:
-//0. NewAuthController(service *services.AuthService) -> 注入 AuthService
+//0. NewAuthController(service *services.AuthService, siteService *services.SiteService) -> 注入 AuthService 与 SiteService
 //1. PublicToken(w http.ResponseWriter, r *http.Request) -> 返回公开 Token
 //2. Login(w http.ResponseWriter, r *http.Request) -> 管理员登录
+//3. VideoToken(w http.ResponseWriter, r *http.Request) -> 管理员按站点授权签发视频 Token
 
 import (
 	"net/http"
@@ -15,16 +16,18 @@ import (
 type AuthControllerInterface interface {
 	PublicToken(w http.ResponseWriter, r *http.Request) //1.公开 Token
 	Login(w http.ResponseWriter, r *http.Request)       //2.管理员登录
+	VideoToken(w http.ResponseWriter, r *http.Request)  //3.按站点授权签发视频 Token
 }
 
 // AuthController 认证接口
 type AuthController struct {
-	service *services.AuthService
+	service     *services.AuthService
+	siteService *services.SiteService
 }
 
 // 0. NewAuthController 构造函数
-func NewAuthController(service *services.AuthService) *AuthController {
-	return &AuthController{service: service}
+func NewAuthController(service *services.AuthService, siteService *services.SiteService) *AuthController {
+	return &AuthController{service: service, siteService: siteService}
 }
 
 type publicTokenRequest struct {
@@ -82,3 +85,34 @@ func (c *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 
 	respondData(w, http.StatusOK, token)
 }
+
+// 3. VideoToken 管理员签发视频 Token：受限管理员只能签发 video 授权范围内的楼栋与通道（楼层/区域授权只覆盖放置在其中的摄像头）
+func (c *AuthController) VideoToken(w http.ResponseWriter, r *http.Request) {
+	var req publicTokenRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.BuildingID == "" {
+		respondError(w, http.StatusBadRequest, "building_id is required")
+		return
+	}
+	if len(req.Channels) == 0 {
+		respondError(w, http.StatusBadRequest, "channels cannot be empty")
+		return
+	}
+
+	if err := c.siteService.AuthorizeVideo(r.Context(), adminUsername(r), req.BuildingID, req.Channels); err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	token, err := c.service.GenerateVideoToken(r.Context(), req.BuildingID, req.Channels)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondData(w, http.StatusOK, map[string]string{
+		"token": token,
+	})
+}
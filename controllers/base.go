@@ -3,9 +3,13 @@ package controllers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+
+	"icctv-http-service/middlewares"
+	"icctv-http-service/services"
 )
 
 type baseResponse struct {
@@ -32,6 +36,17 @@ func respondError(w http.ResponseWriter, status int, message string) {
 	})
 }
 
+// respondErrorData 返回错误并附带详细数据（如阻止操作的关联记录）
+func respondErrorData(w http.ResponseWriter, status int, message string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(baseResponse{
+		Success: false,
+		Data:    data,
+		Error:   message,
+	})
+}
+
 func respondResult(w http.ResponseWriter, err error, successStatus int, payload interface{}) {
 	if err != nil {
 		respondError(w, http.StatusBadRequest, err.Error())
@@ -63,24 +78,112 @@ func parseIDFromQuery(r *http.Request) (int64, error) {
 	return strconv.ParseInt(idStr, 10, 64)
 }
 
-// handleServiceError 根据服务层错误返回合适的HTTP状态码和错误信息
+// handleServiceError 根据服务层错误返回合适的HTTP状态码和错误信息（未识别的错误返回500）
 func handleServiceError(w http.ResponseWriter, err error) {
+	respondServiceError(w, err, http.StatusInternalServerError)
+}
+
+// respondServiceError 按服务层错误类型映射HTTP状态码，未识别的错误使用 fallback
+// 使用 errors.Is 匹配，服务层可以用 fmt.Errorf("%w: ...") 附带详细信息
+func respondServiceError(w http.ResponseWriter, err error, fallback int) {
 	if err == nil {
 		return
 	}
 
-	// 导入services包以访问错误常量
-	// 注意：这里使用字符串匹配，因为不能直接导入services包（避免循环依赖）
-	errMsg := err.Error()
-	
-	// 根据错误消息判断错误类型
-	switch errMsg {
-	case "building not found", "orangepi not found", "nvr not found":
-		respondError(w, http.StatusNotFound, errMsg)
-	case "already bound to another building", "not bound to any building", "building has no ismart_id":
-		respondError(w, http.StatusBadRequest, errMsg)
-	default:
-		// 其他错误默认为500
-		respondError(w, http.StatusInternalServerError, errMsg)
+	status := fallback
+	switch {
+	case errors.Is(err, services.ErrBuildingNotFound),
+		errors.Is(err, services.ErrOrangePiNotFound),
+		errors.Is(err, services.ErrNVRNotFound),
+		errors.Is(err, services.ErrRegistrationNotFound),
+		errors.Is(err, services.ErrJobNotFound),
+		errors.Is(err, services.ErrTemplateNotFound),
+		errors.Is(err, services.ErrSnapshotNotFound),
+		errors.Is(err, services.ErrRolloutNotFound),
+		errors.Is(err, services.ErrChannelNotFound),
+		errors.Is(err, services.ErrTrashItemNotFound),
+		errors.Is(err, services.ErrSiteNotFound),
+		errors.Is(err, services.ErrSiteGrantNotFound),
+		errors.Is(err, services.ErrISmartSyncRunNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrAlreadyBound),
+		errors.Is(err, services.ErrNotBound),
+		errors.Is(err, services.ErrBuildingNoISmartID),
+		errors.Is(err, services.ErrNoReportedDeviceID),
+		errors.Is(err, services.ErrPortOutOfRange),
+		errors.Is(err, services.ErrPortMigrationNotFailed),
+		errors.Is(err, services.ErrJobTypeUnknown),
+		errors.Is(err, services.ErrJobNoTargets),
+		errors.Is(err, services.ErrJobInvalidParam),
+		errors.Is(err, services.ErrServiceNotAllowed),
+		errors.Is(err, services.ErrInvalidServiceAction),
+		errors.Is(err, services.ErrTemplateInvalid),
+		errors.Is(err, services.ErrConfigFileUnknown),
+		errors.Is(err, services.ErrInvalidSnapshotRef),
+		errors.Is(err, services.ErrRolloutInvalid),
+		errors.Is(err, services.ErrRolloutNoDevices),
+		errors.Is(err, services.ErrRecordingRangeInvalid),
+		errors.Is(err, services.ErrLifecycleStateInvalid),
+		errors.Is(err, services.ErrReplacementInvalid),
+		errors.Is(err, services.ErrCascadeInvalid),
+		errors.Is(err, services.ErrBindingViaUpdate),
+		errors.Is(err, services.ErrTrashResourceUnknown),
+		errors.Is(err, services.ErrSiteInvalid),
+		errors.Is(err, services.ErrSiteGrantInvalid),
+		errors.Is(err, services.ErrISmartNotConfigured):
+		status = http.StatusBadRequest
+	case errors.Is(err, services.ErrRegistrationNotPending),
+		errors.Is(err, services.ErrDeviceIDMismatch),
+		errors.Is(err, services.ErrDeviceIDConflict),
+		errors.Is(err, services.ErrPortConflict),
+		errors.Is(err, services.ErrPortPoolFull),
+		errors.Is(err, services.ErrPortMigrationInProgress),
+		errors.Is(err, services.ErrPortMigrationFailed),
+		errors.Is(err, services.ErrJobFinished),
+		errors.Is(err, services.ErrRolloutStateInvalid),
+		errors.Is(err, services.ErrNoAvailableDevice),
+		errors.Is(err, services.ErrLifecycleTransition),
+		errors.Is(err, services.ErrDeviceNotMovable),
+		errors.Is(err, services.ErrISmartIDConflict),
+		errors.Is(err, services.ErrBuildingInUse),
+		errors.Is(err, services.ErrRestoreConflict),
+		errors.Is(err, services.ErrInTrash),
+		errors.Is(err, services.ErrSiteInUse),
+		errors.Is(err, services.ErrISmartSyncRunning):
+		status = http.StatusConflict
+	case errors.Is(err, services.ErrInvalidVideoToken):
+		status = http.StatusUnauthorized
+	case errors.Is(err, services.ErrVideoTokenScope),
+		errors.Is(err, services.ErrSiteForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrISmartUpstream):
+		status = http.StatusBadGateway
+	}
+	respondError(w, status, err.Error())
+}
+
+// adminUsername 读取当前登录管理员的用户名（未登录返回空字符串）
+func adminUsername(r *http.Request) string {
+	if claims := middlewares.AdminClaimsFromContext(r.Context()); claims != nil {
+		return claims.Username
+	}
+	return ""
+}
+
+// requestSiteScope 合并 ?site_id= 站点子树筛选与受限管理员的授权范围（nil 表示不限制）
+func requestSiteScope(r *http.Request, siteService *services.SiteService) (*services.SiteScope, error) {
+	scope := middlewares.SiteScopeFromContext(r.Context())
+	val := r.URL.Query().Get("site_id")
+	if val == "" || siteService == nil {
+		return scope, nil
+	}
+	id, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid site_id", services.ErrSiteInvalid)
+	}
+	subtree, err := siteService.Subtree(r.Context(), id)
+	if err != nil {
+		return nil, err
 	}
+	return subtree.Intersect(scope), nil
 }
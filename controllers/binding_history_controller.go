@@ -0,0 +1,100 @@
+package controllers
+
+// BindingHistoryController Methods:
+//0. NewBindingHistoryController(service *services.BindingHistoryService) -> 注入 BindingHistoryService
+//1. OrangePi(w http.ResponseWriter, r *http.Request) -> 查询 OrangePi 的绑定时间线
+//2. NVR(w http.ResponseWriter, r *http.Request) -> 查询 NVR 的绑定时间线
+//3. Building(w http.ResponseWriter, r *http.Request) -> 查询时间段内服务过建筑的设备
+
+import (
+	"net/http"
+	"time"
+
+	"icctv-http-service/models"
+	"icctv-http-service/services"
+)
+
+// BindingHistoryControllerInterface 定义绑定历史接口能力
+type BindingHistoryControllerInterface interface {
+	OrangePi(w http.ResponseWriter, r *http.Request) //1.OrangePi 绑定时间线
+	NVR(w http.ResponseWriter, r *http.Request)      //2.NVR 绑定时间线
+	Building(w http.ResponseWriter, r *http.Request) //3.建筑设备历史
+}
+
+// BindingHistoryController 绑定历史接口
+type BindingHistoryController struct {
+	service *services.BindingHistoryService
+}
+
+// 0. NewBindingHistoryController 构造函数
+func NewBindingHistoryController(service *services.BindingHistoryService) *BindingHistoryController {
+	return &BindingHistoryController{service: service}
+}
+
+// 1. OrangePi 查询 OrangePi 的绑定时间线：?at=(RFC3339，可选，只返回该时间点所在的时间段)
+func (c *BindingHistoryController) OrangePi(w http.ResponseWriter, r *http.Request) {
+	c.deviceTimeline(w, r, models.BindingDeviceOrangePi)
+}
+
+// 2. NVR 查询 NVR 的绑定时间线：?at=(RFC3339，可选)
+func (c *BindingHistoryController) NVR(w http.ResponseWriter, r *http.Request) {
+	c.deviceTimeline(w, r, models.BindingDeviceNVR)
+}
+
+// 3. Building 查询时间段内服务过建筑的设备：?from=&to=(RFC3339，默认全部历史至今)
+func (c *BindingHistoryController) Building(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	var from time.Time
+	to := time.Now()
+	var err error
+	if v := q.Get("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			respondError(w, http.StatusBadRequest, "invalid from, expected RFC3339")
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			respondError(w, http.StatusBadRequest, "invalid to, expected RFC3339")
+			return
+		}
+	}
+	if to.Before(from) {
+		respondError(w, http.StatusBadRequest, "to must not be before from")
+		return
+	}
+
+	periods, err := c.service.BuildingDevices(r.Context(), id, from, to)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, periods)
+}
+
+func (c *BindingHistoryController) deviceTimeline(w http.ResponseWriter, r *http.Request, deviceType string) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	var at *time.Time
+	if v := r.URL.Query().Get("at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid at, expected RFC3339")
+			return
+		}
+		at = &t
+	}
+
+	timeline, err := c.service.DeviceTimeline(r.Context(), deviceType, id, at)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, timeline)
+}
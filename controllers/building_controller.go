@@ -1,13 +1,14 @@
 package controllers
 
 // BuildingController Methods:
-//0. NewBuildingController(service *services.BuildingService) -> 注入 BuildingService
-//1. List(w http.ResponseWriter, r *http.Request) -> 列出建筑
+//0. NewBuildingController(service *services.BuildingService, streamService *services.StreamService, siteService *services.SiteService) -> 注入 BuildingService、StreamService 与 SiteService
+//1. List(w http.ResponseWriter, r *http.Request) -> 列出建筑（?site_id= 按站点子树筛选）
 //2. Create(w http.ResponseWriter, r *http.Request) -> 创建建筑
 //3. Update(w http.ResponseWriter, r *http.Request) -> 更新建筑
 //4. Delete(w http.ResponseWriter, r *http.Request) -> 删除建筑
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -23,26 +24,35 @@ type BuildingControllerInterface interface {
 	Delete(w http.ResponseWriter, r *http.Request)            //4.删除建筑
 	BindOrangePi(w http.ResponseWriter, r *http.Request)      //5.绑定OrangePi
 	UnbindOrangePi(w http.ResponseWriter, r *http.Request)    //6.解绑OrangePi
-	UpdateBind(w http.ResponseWriter, r *http.Request)        //7.更新绑定
+	UpdateBind(w http.ResponseWriter, r *http.Request)        //7.迁移OrangePi
 	GetBuildingOrangePis(w http.ResponseWriter, r *http.Request) //8.查询Building关联的OrangePi
 	BindNVR(w http.ResponseWriter, r *http.Request)           //9.绑定NVR
 	UnbindNVR(w http.ResponseWriter, r *http.Request)         //10.解绑NVR
 	GetBuildingNVRs(w http.ResponseWriter, r *http.Request)   //11.查询Building关联的NVR
+	Detail(w http.ResponseWriter, r *http.Request)            //12.建筑详情(含通道推流状态)
+	MoveNVR(w http.ResponseWriter, r *http.Request)           //13.迁移NVR
 }
 
 // BuildingController 建筑接口
 type BuildingController struct {
-	service *services.BuildingService
+	service       *services.BuildingService
+	streamService *services.StreamService
+	siteService   *services.SiteService
 }
 
 // 0. NewBuildingController 构造函数
-func NewBuildingController(service *services.BuildingService) *BuildingController {
-	return &BuildingController{service: service}
+func NewBuildingController(service *services.BuildingService, streamService *services.StreamService, siteService *services.SiteService) *BuildingController {
+	return &BuildingController{service: service, streamService: streamService, siteService: siteService}
 }
 
-// 1. List 查询建筑
+// 1. List 查询建筑（?site_id= 按站点子树筛选，受限管理员只返回授权范围内的建筑）
 func (c *BuildingController) List(w http.ResponseWriter, r *http.Request) {
-	items, err := c.service.List(r.Context())
+	scope, err := requestSiteScope(r, c.siteService)
+	if err != nil {
+		respondServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	items, err := c.service.List(r.Context(), scope)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -58,7 +68,11 @@ func (c *BuildingController) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	item, err := c.service.Create(r.Context(), req)
-	respondResult(w, err, http.StatusCreated, item)
+	if err != nil {
+		respondServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	respondData(w, http.StatusCreated, item)
 }
 
 // 3. Update 更新建筑
@@ -73,15 +87,19 @@ func (c *BuildingController) Update(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	item, err := c.service.Update(r.Context(), id, req)
-	respondResult(w, err, http.StatusOK, item)
+	item, err := c.service.Update(r.Context(), id, req, requestActor(r))
+	if err != nil {
+		respondServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	respondData(w, http.StatusOK, item)
 }
 
 type deleteBuildingRequest struct {
 	ID int64 `json:"id"`
 }
 
-// 4. Delete 删除建筑
+// 4. Delete 删除建筑：?cascade=unbind|delete 处理关联的 OrangePi 与 NVR，不指定时有关联设备返回 409 及关联列表
 func (c *BuildingController) Delete(w http.ResponseWriter, r *http.Request) {
 	var req deleteBuildingRequest
 	if err := decodeJSON(r, &req); err != nil {
@@ -92,25 +110,34 @@ func (c *BuildingController) Delete(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "id is required")
 		return
 	}
-	if err := c.service.Delete(r.Context(), req.ID); err != nil {
-		respondError(w, http.StatusInternalServerError, err.Error())
+	result, err := c.service.Delete(r.Context(), req.ID, r.URL.Query().Get("cascade"), requestActor(r))
+	if errors.Is(err, services.ErrBuildingInUse) {
+		respondErrorData(w, http.StatusConflict, err.Error(), result)
+		return
+	}
+	if err != nil {
+		handleServiceError(w, err)
 		return
 	}
-	respondData(w, http.StatusOK, map[string]bool{"deleted": true})
+	respondData(w, http.StatusOK, result)
 }
 
 type bindOrangePiRequest struct {
-	BuildingID int64 `json:"building_id"`
-	OrangePiID int64 `json:"orangepi_id"`
+	BuildingID int64  `json:"building_id"`
+	OrangePiID int64  `json:"orangepi_id"`
+	Reason     string `json:"reason"`
 }
 
 type unbindOrangePiRequest struct {
-	OrangePiID int64 `json:"orangepi_id"`
+	OrangePiID int64  `json:"orangepi_id"`
+	Reason     string `json:"reason"`
 }
 
 type updateBindRequest struct {
-	OrangePiID    int64 `json:"orangepi_id"`
-	NewBuildingID int64 `json:"new_building_id"`
+	OrangePiID     int64  `json:"orangepi_id"`
+	NewBuildingID  int64  `json:"new_building_id"`
+	Reason         string `json:"reason"`
+	RequireHealthy bool   `json:"require_healthy"`
 }
 
 // 5. BindOrangePi 绑定OrangePi到建筑
@@ -124,7 +151,7 @@ func (c *BuildingController) BindOrangePi(w http.ResponseWriter, r *http.Request
 		respondError(w, http.StatusBadRequest, "building_id and orangepi_id must be positive integers")
 		return
 	}
-	if err := c.service.BindOrangePi(r.Context(), req.BuildingID, req.OrangePiID); err != nil {
+	if err := c.service.BindOrangePi(r.Context(), req.BuildingID, req.OrangePiID, req.Reason, requestActor(r)); err != nil {
 		handleServiceError(w, err)
 		return
 	}
@@ -142,26 +169,27 @@ func (c *BuildingController) UnbindOrangePi(w http.ResponseWriter, r *http.Reque
 		respondError(w, http.StatusBadRequest, "orangepi_id must be a positive integer")
 		return
 	}
-	if err := c.service.UnbindOrangePi(r.Context(), req.OrangePiID); err != nil {
+	if err := c.service.UnbindOrangePi(r.Context(), req.OrangePiID, req.Reason, requestActor(r)); err != nil {
 		handleServiceError(w, err)
 		return
 	}
 	respondData(w, http.StatusOK, map[string]bool{"unbound": true})
 }
 
-// 7. UpdateBind 更新绑定关系
+// 7. UpdateBind 迁移OrangePi到其他建筑（require_healthy 时设备须健康或处于维护状态）
 func (c *BuildingController) UpdateBind(w http.ResponseWriter, r *http.Request) {
 	var req updateBindRequest
 	if err := decodeJSON(r, &req); err != nil {
 		respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	if req.OrangePiID == 0 || req.NewBuildingID == 0 {
-		respondError(w, http.StatusBadRequest, "orangepi_id and new_building_id are required")
+	if req.OrangePiID <= 0 || req.NewBuildingID <= 0 {
+		respondError(w, http.StatusBadRequest, "orangepi_id and new_building_id must be positive integers")
 		return
 	}
-	if err := c.service.UpdateBind(r.Context(), req.OrangePiID, req.NewBuildingID); err != nil {
-		respondError(w, http.StatusInternalServerError, err.Error())
+	opts := services.MoveOptions{Reason: req.Reason, RequireHealthy: req.RequireHealthy}
+	if err := c.service.MoveOrangePi(r.Context(), req.OrangePiID, req.NewBuildingID, opts, requestActor(r)); err != nil {
+		handleServiceError(w, err)
 		return
 	}
 	respondData(w, http.StatusOK, map[string]bool{"updated": true})
@@ -191,12 +219,14 @@ func (c *BuildingController) GetBuildingOrangePis(w http.ResponseWriter, r *http
 }
 
 type bindNVRRequest struct {
-	BuildingID int64 `json:"building_id"`
-	NVRID      int64 `json:"nvr_id"`
+	BuildingID int64  `json:"building_id"`
+	NVRID      int64  `json:"nvr_id"`
+	Reason     string `json:"reason"`
 }
 
 type unbindNVRRequest struct {
-	NVRID int64 `json:"nvr_id"`
+	NVRID  int64  `json:"nvr_id"`
+	Reason string `json:"reason"`
 }
 
 // 9. BindNVR 绑定NVR到建筑
@@ -210,7 +240,7 @@ func (c *BuildingController) BindNVR(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "building_id and nvr_id must be positive integers")
 		return
 	}
-	if err := c.service.BindNVR(r.Context(), req.BuildingID, req.NVRID); err != nil {
+	if err := c.service.BindNVR(r.Context(), req.BuildingID, req.NVRID, req.Reason, requestActor(r)); err != nil {
 		handleServiceError(w, err)
 		return
 	}
@@ -228,7 +258,7 @@ func (c *BuildingController) UnbindNVR(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, "nvr_id must be a positive integer")
 		return
 	}
-	if err := c.service.UnbindNVR(r.Context(), req.NVRID); err != nil {
+	if err := c.service.UnbindNVR(r.Context(), req.NVRID, req.Reason, requestActor(r)); err != nil {
 		handleServiceError(w, err)
 		return
 	}
@@ -257,3 +287,50 @@ func (c *BuildingController) GetBuildingNVRs(w http.ResponseWriter, r *http.Requ
 	}
 	respondData(w, http.StatusOK, nvrs)
 }
+
+// 12. Detail 查询建筑详情，streams 为每个 NVR 通道的推流状态(up/down/unknown)
+func (c *BuildingController) Detail(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	building, err := c.service.Get(r.Context(), id)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	streams, err := c.streamService.BuildingStreams(r.Context(), id)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, map[string]interface{}{
+		"building": building,
+		"streams":  streams,
+	})
+}
+
+type moveNVRRequest struct {
+	NVRID         int64  `json:"nvr_id"`
+	NewBuildingID int64  `json:"new_building_id"`
+	Reason        string `json:"reason"`
+}
+
+// 13. MoveNVR 迁移NVR到其他建筑
+func (c *BuildingController) MoveNVR(w http.ResponseWriter, r *http.Request) {
+	var req moveNVRRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.NVRID <= 0 || req.NewBuildingID <= 0 {
+		respondError(w, http.StatusBadRequest, "nvr_id and new_building_id must be positive integers")
+		return
+	}
+	if err := c.service.MoveNVR(r.Context(), req.NVRID, req.NewBuildingID, req.Reason, requestActor(r)); err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, map[string]bool{"updated": true})
+}
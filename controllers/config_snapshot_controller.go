@@ -0,0 +1,122 @@
+package controllers
+
+// ConfigSnapshotController Methods:
+//0. NewConfigSnapshotController(service *services.ConfigSnapshotService) -> 注入 ConfigSnapshotService
+//1. Capture(w http.ResponseWriter, r *http.Request) -> 立即采集设备配置
+//2. List(w http.ResponseWriter, r *http.Request) -> 查询配置版本
+//3. Get(w http.ResponseWriter, r *http.Request) -> 查询单个版本内容
+//4. Diff(w http.ResponseWriter, r *http.Request) -> 对比两个版本或与期望配置对比
+
+import (
+	"net/http"
+	"strconv"
+
+	"icctv-http-service/models"
+	"icctv-http-service/services"
+)
+
+// ConfigSnapshotControllerInterface 定义配置快照接口能力
+type ConfigSnapshotControllerInterface interface {
+	Capture(w http.ResponseWriter, r *http.Request) //1.立即采集
+	List(w http.ResponseWriter, r *http.Request)    //2.版本列表
+	Get(w http.ResponseWriter, r *http.Request)     //3.版本内容
+	Diff(w http.ResponseWriter, r *http.Request)    //4.版本对比
+}
+
+// ConfigSnapshotController 配置快照接口
+type ConfigSnapshotController struct {
+	service *services.ConfigSnapshotService
+}
+
+// 0. NewConfigSnapshotController 构造函数
+func NewConfigSnapshotController(service *services.ConfigSnapshotService) *ConfigSnapshotController {
+	return &ConfigSnapshotController{service: service}
+}
+
+// 1. Capture 立即采集设备上的 frpc.toml 与 mediamtx.yml
+func (c *ConfigSnapshotController) Capture(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	results, err := c.service.Capture(r.Context(), id, models.ConfigSnapshotTriggerManual, adminUsername(r))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, results)
+}
+
+// 2. List 查询配置版本，可按 file 筛选
+func (c *ConfigSnapshotController) List(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	items, err := c.service.List(r.Context(), id, r.URL.Query().Get("file"))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondData(w, http.StatusOK, items)
+}
+
+// 3. Get 查询单个版本（含文件内容）
+func (c *ConfigSnapshotController) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	snapshotID, ok := pathID(w, r, "snapshot_id")
+	if !ok {
+		return
+	}
+
+	snapshot, err := c.service.Get(r.Context(), id, snapshotID)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, snapshot)
+}
+
+// 4. Diff 对比配置：?file=frpc.toml&from=<版本ID|latest|desired>&to=<版本ID|latest|desired>
+// 默认 from=latest、to=desired，即设备上的实际配置与数据库期望配置的差异
+func (c *ConfigSnapshotController) Diff(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	file := q.Get("file")
+	if file == "" {
+		respondError(w, http.StatusBadRequest, "file is required")
+		return
+	}
+	from, to := q.Get("from"), q.Get("to")
+	if from == "" {
+		from = "latest"
+	}
+	if to == "" {
+		to = services.SnapshotRefDesired
+	}
+
+	diff, err := c.service.Diff(r.Context(), id, file, from, to)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, diff)
+}
+
+// pathID 解析路径参数中的正整数ID，失败时直接返回 400
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(w, http.StatusBadRequest, "invalid "+name)
+		return 0, false
+	}
+	return id, true
+}
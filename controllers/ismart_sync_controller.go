@@ -0,0 +1,99 @@
+package controllers
+
+// ISmartSyncController Methods:
+//0. NewISmartSyncController(service *services.ISmartSyncService) -> 注入 ISmartSyncService
+//1. Status(w http.ResponseWriter, r *http.Request) -> 同步配置与最近一次同步
+//2. Sync(w http.ResponseWriter, r *http.Request) -> 立即同步(可选 dry-run)
+//3. Runs(w http.ResponseWriter, r *http.Request) -> 查询同步记录
+//4. GetRun(w http.ResponseWriter, r *http.Request) -> 查询同步记录详情
+
+import (
+	"net/http"
+	"strconv"
+
+	"icctv-http-service/models"
+	"icctv-http-service/services"
+)
+
+// ISmartSyncControllerInterface 定义 iSmart 同步接口能力
+type ISmartSyncControllerInterface interface {
+	Status(w http.ResponseWriter, r *http.Request) //1.同步状态
+	Sync(w http.ResponseWriter, r *http.Request)   //2.立即同步
+	Runs(w http.ResponseWriter, r *http.Request)   //3.同步记录
+	GetRun(w http.ResponseWriter, r *http.Request) //4.同步记录详情
+}
+
+// ISmartSyncController iSmart 同步接口
+type ISmartSyncController struct {
+	service *services.ISmartSyncService
+}
+
+// 0. NewISmartSyncController 构造函数
+func NewISmartSyncController(service *services.ISmartSyncService) *ISmartSyncController {
+	return &ISmartSyncController{service: service}
+}
+
+type iSmartSyncRequest struct {
+	DryRun bool `json:"dry_run"`
+}
+
+// 1. Status 同步配置（不含凭证）与最近一次同步记录
+func (c *ISmartSyncController) Status(w http.ResponseWriter, r *http.Request) {
+	status, err := c.service.Status(r.Context())
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, status)
+}
+
+// 2. Sync 立即同步，请求体可省略（直接应用）；{"dry_run": true} 时只返回差异不修改建筑
+func (c *ISmartSyncController) Sync(w http.ResponseWriter, r *http.Request) {
+	var req iSmartSyncRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	run, err := c.service.Sync(r.Context(), req.DryRun, models.ISmartSyncTriggerManual, requestActor(r))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, run)
+}
+
+// 3. Runs 查询同步记录：?limit=（默认 20，最多 100）
+func (c *ISmartSyncController) Runs(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if val := r.URL.Query().Get("limit"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	items, err := c.service.Runs(r.Context(), limit)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, items)
+}
+
+// 4. GetRun 查询同步记录详情：/api/ismart/sync/runs/{id}
+func (c *ISmartSyncController) GetRun(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	run, err := c.service.GetRun(r.Context(), id)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, run)
+}
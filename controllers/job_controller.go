@@ -0,0 +1,109 @@
+package controllers
+
+// JobController Methods:
+//0. NewJobController(service *services.JobService) -> 注入 JobService
+//1. Create(w http.ResponseWriter, r *http.Request) -> 创建异步任务
+//2. Get(w http.ResponseWriter, r *http.Request) -> 查询任务详情及目标结果
+//3. List(w http.ResponseWriter, r *http.Request) -> 分页查询任务
+//4. Cancel(w http.ResponseWriter, r *http.Request) -> 取消任务
+
+import (
+	"net/http"
+	"strconv"
+
+	"icctv-http-service/models"
+	"icctv-http-service/services"
+)
+
+// JobControllerInterface 定义异步任务接口能力
+type JobControllerInterface interface {
+	Create(w http.ResponseWriter, r *http.Request) //1.创建任务
+	Get(w http.ResponseWriter, r *http.Request)    //2.任务详情
+	List(w http.ResponseWriter, r *http.Request)   //3.任务列表
+	Cancel(w http.ResponseWriter, r *http.Request) //4.取消任务
+}
+
+// JobController 异步任务接口
+type JobController struct {
+	service *services.JobService
+}
+
+// 0. NewJobController 构造函数
+func NewJobController(service *services.JobService) *JobController {
+	return &JobController{service: service}
+}
+
+// 1. Create 创建异步任务，立即返回 202 和任务记录
+func (c *JobController) Create(w http.ResponseWriter, r *http.Request) {
+	var req services.EnqueueJobRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Type == "" {
+		respondError(w, http.StatusBadRequest, "type is required")
+		return
+	}
+
+	job, err := c.service.Enqueue(r.Context(), req, adminUsername(r))
+	if err != nil {
+		respondServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	respondData(w, http.StatusAccepted, job)
+}
+
+// 2. Get 查询任务详情及每个目标的结果
+func (c *JobController) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	job, err := c.service.Get(r.Context(), id)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, job)
+}
+
+// 3. List 分页查询任务，可按 type / status 筛选
+func (c *JobController) List(w http.ResponseWriter, r *http.Request) {
+	query := services.JobListQuery{
+		PaginationQuery: models.PaginationQuery{
+			PageNum:  parseInt(r.URL.Query().Get("pageNum"), 1),
+			PageSize: parseInt(r.URL.Query().Get("pageSize"), 20),
+			Asc:      r.URL.Query().Get("asc") == "true",
+		},
+		Type:   r.URL.Query().Get("type"),
+		Status: r.URL.Query().Get("status"),
+	}
+
+	jobs, pageResult, err := c.service.List(r.Context(), query)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondData(w, http.StatusOK, map[string]interface{}{
+		"items": jobs,
+		"page":  pageResult,
+	})
+}
+
+// 4. Cancel 取消任务，已结束的任务返回 409
+func (c *JobController) Cancel(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	job, err := c.service.Cancel(r.Context(), id)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, job)
+}
@@ -0,0 +1,90 @@
+package controllers
+
+// LifecycleController Methods:
+//0. NewLifecycleController(service *services.LifecycleService) -> 注入 LifecycleService
+//1. Transition(w http.ResponseWriter, r *http.Request) -> 变更设备生命周期状态
+//2. History(w http.ResponseWriter, r *http.Request) -> 查询替换链及生命周期变更记录
+//3. Replace(w http.ResponseWriter, r *http.Request) -> 用新硬件替换故障设备
+
+import (
+	"net/http"
+
+	"icctv-http-service/services"
+)
+
+// LifecycleControllerInterface 定义设备生命周期接口能力
+type LifecycleControllerInterface interface {
+	Transition(w http.ResponseWriter, r *http.Request) //1.状态变更
+	History(w http.ResponseWriter, r *http.Request)    //2.变更记录
+	Replace(w http.ResponseWriter, r *http.Request)    //3.替换设备
+}
+
+// LifecycleController 设备生命周期接口
+type LifecycleController struct {
+	service *services.LifecycleService
+}
+
+// 0. NewLifecycleController 构造函数
+func NewLifecycleController(service *services.LifecycleService) *LifecycleController {
+	return &LifecycleController{service: service}
+}
+
+type lifecycleTransitionRequest struct {
+	State  string `json:"state"`
+	Reason string `json:"reason"`
+}
+
+// 1. Transition 变更设备生命周期状态：{"state": "maintenance", "reason": "..."}
+func (c *LifecycleController) Transition(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	var req lifecycleTransitionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	device, err := c.service.Transition(r.Context(), id, req.State, req.Reason, requestActor(r))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, device)
+}
+
+// 2. History 查询设备替换链及链上所有设备的生命周期变更记录
+func (c *LifecycleController) History(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	history, err := c.service.History(r.Context(), id)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, history)
+}
+
+// 3. Replace 用新硬件替换故障设备：{"device_id": "...", "name": "...", "reason": "..."}
+func (c *LifecycleController) Replace(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	var req services.DeviceReplacement
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := c.service.Replace(r.Context(), id, req, requestActor(r))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, result)
+}
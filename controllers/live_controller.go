@@ -0,0 +1,44 @@
+package controllers
+
+// LiveController Methods:
+//0. NewLiveController(service *services.LiveService) -> 注入 LiveService
+//1. Resolve(w http.ResponseWriter, r *http.Request) -> 解析楼栋通道的直播播放地址
+
+import (
+	"net/http"
+	"strconv"
+
+	"icctv-http-service/services"
+)
+
+// LiveControllerInterface 定义直播地址接口能力
+type LiveControllerInterface interface {
+	Resolve(w http.ResponseWriter, r *http.Request) //1.解析播放地址
+}
+
+// LiveController 直播地址接口
+type LiveController struct {
+	service *services.LiveService
+}
+
+// 0. NewLiveController 构造函数
+func NewLiveController(service *services.LiveService) *LiveController {
+	return &LiveController{service: service}
+}
+
+// 1. Resolve 返回 WHEP / HLS / RTSP 播放地址：/api/live/{ismartid}/{channel}?token=(可选，已有的视频 Token)
+func (c *LiveController) Resolve(w http.ResponseWriter, r *http.Request) {
+	ismartID := r.PathValue("ismartid")
+	channel, err := strconv.Atoi(r.PathValue("channel"))
+	if err != nil || channel <= 0 {
+		respondError(w, http.StatusBadRequest, "invalid channel")
+		return
+	}
+
+	view, err := c.service.Resolve(r.Context(), ismartID, channel, r.URL.Query().Get("token"))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, view)
+}
@@ -0,0 +1,58 @@
+package controllers
+
+// MediaMTXSyncController Methods:
+//0. NewMediaMTXSyncController(service *services.MediaMTXSyncService) -> 注入 MediaMTXSyncService
+//1. Preview(w http.ResponseWriter, r *http.Request) -> 预览楼栋期望路径配置与同步状态
+//2. Sync(w http.ResponseWriter, r *http.Request) -> 立即向楼栋设备下发路径配置
+
+import (
+	"net/http"
+
+	"icctv-http-service/services"
+)
+
+// MediaMTXSyncControllerInterface 定义 MediaMTX 路径同步接口能力
+type MediaMTXSyncControllerInterface interface {
+	Preview(w http.ResponseWriter, r *http.Request) //1.预览
+	Sync(w http.ResponseWriter, r *http.Request)    //2.立即同步
+}
+
+// MediaMTXSyncController MediaMTX 路径同步接口
+type MediaMTXSyncController struct {
+	service *services.MediaMTXSyncService
+}
+
+// 0. NewMediaMTXSyncController 构造函数
+func NewMediaMTXSyncController(service *services.MediaMTXSyncService) *MediaMTXSyncController {
+	return &MediaMTXSyncController{service: service}
+}
+
+// 1. Preview 预览楼栋 NVR 通道生成的 channelN 路径（密码脱敏）、通道冲突与各设备最近同步结果
+func (c *MediaMTXSyncController) Preview(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	preview, err := c.service.Preview(r.Context(), id)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, preview)
+}
+
+// 2. Sync 立即向楼栋绑定的所有设备下发路径配置，返回每台设备每个路径的结果
+func (c *MediaMTXSyncController) Sync(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	results, err := c.service.SyncBuilding(r.Context(), id, requestActor(r))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, results)
+}
@@ -1,8 +1,8 @@
 package controllers
 
 // NVRController Methods:
-//0. NewNVRController(service *services.NVRService) -> 注入 NVRService
-//1. List(w http.ResponseWriter, r *http.Request) -> 列出NVR
+//0. NewNVRController(service *services.NVRService, siteService *services.SiteService) -> 注入 NVRService 与 SiteService
+//1. List(w http.ResponseWriter, r *http.Request) -> 列出NVR（?site_id= 按站点子树筛选）
 //2. Create(w http.ResponseWriter, r *http.Request) -> 创建NVR
 //3. Update(w http.ResponseWriter, r *http.Request) -> 更新NVR
 //4. Delete(w http.ResponseWriter, r *http.Request) -> 删除NVR
@@ -25,16 +25,24 @@ type NVRControllerInterface interface {
 
 // NVRController NVR接口
 type NVRController struct {
-	service *services.NVRService
+	service     *services.NVRService
+	siteService *services.SiteService
 }
 
 // 0. NewNVRController 构造函数
-func NewNVRController(service *services.NVRService) *NVRController {
-	return &NVRController{service: service}
+func NewNVRController(service *services.NVRService, siteService *services.SiteService) *NVRController {
+	return &NVRController{service: service, siteService: siteService}
 }
 
 // 1. List 查询NVR列表或详情
 func (c *NVRController) List(w http.ResponseWriter, r *http.Request) {
+	// 受限管理员只能查看授权范围内建筑的NVR
+	scope, err := requestSiteScope(r, c.siteService)
+	if err != nil {
+		respondServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+
 	// 检查是否有id参数，如果有则返回详情
 	idStr := r.URL.Query().Get("id")
 	if idStr != "" {
@@ -44,16 +52,20 @@ func (c *NVRController) List(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		item, err := c.service.GetByID(r.Context(), id)
+		if err == nil && !scope.AllowsBuilding(item.BuildingID) {
+			err = services.ErrNVRNotFound
+		}
 		if err != nil {
 			respondError(w, http.StatusNotFound, err.Error())
 			return
 		}
+		scope.LimitChannels(item)
 		respondData(w, http.StatusOK, item)
 		return
 	}
 
 	// 否则返回列表
-	items, err := c.service.List(r.Context())
+	items, err := c.service.List(r.Context(), scope)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -68,7 +80,7 @@ func (c *NVRController) Create(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	item, err := c.service.Create(r.Context(), req)
+	item, err := c.service.Create(r.Context(), req, requestActor(r))
 	respondResult(w, err, http.StatusCreated, item)
 }
 
@@ -84,7 +96,7 @@ func (c *NVRController) Update(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	item, err := c.service.Update(r.Context(), id, req)
+	item, err := c.service.Update(r.Context(), id, req, requestActor(r))
 	respondResult(w, err, http.StatusOK, item)
 }
 
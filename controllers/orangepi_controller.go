@@ -1,15 +1,17 @@
 package controllers
 
 // OrangePiController Methods:
-//0. NewOrangePiController(service *services.OrangePiService) -> 注入 OrangePiService
-//1. List(w http.ResponseWriter, r *http.Request) -> 查询设备列表
+//0. NewOrangePiController(service *services.OrangePiService, portMigrationService *services.PortMigrationService, jobService *services.JobService, siteService *services.SiteService) -> 注入 OrangePiService、PortMigrationService、JobService 与 SiteService
+//1. List(w http.ResponseWriter, r *http.Request) -> 查询设备列表（?ismartid=、?site_id= 筛选）
 //2. Create(w http.ResponseWriter, r *http.Request) -> 创建设备
 //3. Update(w http.ResponseWriter, r *http.Request) -> 更新设备
 //4. Delete(w http.ResponseWriter, r *http.Request) -> 删除设备
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"icctv-http-service/models"
 	"icctv-http-service/services"
@@ -24,22 +26,34 @@ type OrangePiControllerInterface interface {
 	RemoteUpdatePorts(w http.ResponseWriter, r *http.Request) //5.远程更新端口
 	RemoteGetInfo(w http.ResponseWriter, r *http.Request)     //6.远程获取设备信息
 	RemoteHealthCheck(w http.ResponseWriter, r *http.Request) //7.远程健康检查
+	AcceptDeviceID(w http.ResponseWriter, r *http.Request)    //8.确认设备ID
+	PortPool(w http.ResponseWriter, r *http.Request)          //9.端口池使用情况
+	Heartbeat(w http.ResponseWriter, r *http.Request)         //10.设备心跳
+	Versions(w http.ResponseWriter, r *http.Request)          //11.版本分布
 }
 
 // OrangePiController 设备接口
 type OrangePiController struct {
-	service *services.OrangePiService
+	service              *services.OrangePiService
+	portMigrationService *services.PortMigrationService
+	jobService           *services.JobService
+	siteService          *services.SiteService
 }
 
 // 0. NewOrangePiController 构造函数
-func NewOrangePiController(service *services.OrangePiService) *OrangePiController {
-	return &OrangePiController{service: service}
+func NewOrangePiController(service *services.OrangePiService, portMigrationService *services.PortMigrationService, jobService *services.JobService, siteService *services.SiteService) *OrangePiController {
+	return &OrangePiController{service: service, portMigrationService: portMigrationService, jobService: jobService, siteService: siteService}
 }
 
 // 1. List 查询设备
 func (c *OrangePiController) List(w http.ResponseWriter, r *http.Request) {
+	scope, err := requestSiteScope(r, c.siteService)
+	if err != nil {
+		respondServiceError(w, err, http.StatusBadRequest)
+		return
+	}
 	ismartId := r.URL.Query().Get("ismartid")
-	devices, err := c.service.List(r.Context(), ismartId)
+	devices, err := c.service.List(r.Context(), ismartId, scope)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -48,11 +62,12 @@ func (c *OrangePiController) List(w http.ResponseWriter, r *http.Request) {
 }
 
 type orangePiPayload struct {
-	ISmartID                   string `json:"ismartid"`
+	BuildingID                 *int64 `json:"building_id"`
+	ISmartID                   string `json:"ismartid"` // 兼容旧请求：未指定 building_id 时按 ismartId 查找建筑
 	Name                       string `json:"name"`
 	ICCTVAuthServiceRemotePort int    `json:"icctv_auth_service_remote_port"`
 	SSHRemotePort              int    `json:"ssh_remote_port"`
-	IsActive                   *bool  `json:"is_active"`
+	LifecycleState             string `json:"lifecycle_state"` // 仅创建时可指定初始状态，之后通过生命周期接口变更
 }
 
 // 2. Create 创建设备
@@ -63,19 +78,23 @@ func (c *OrangePiController) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	buildingID, err := c.resolveBuildingID(r, req)
+	if err != nil {
+		respondServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+
 	device := models.OrangePi{
-		ISmartID:                   req.ISmartID,
+		BuildingID:                 buildingID,
 		Name:                       req.Name,
 		ICCTVAuthServiceRemotePort: req.ICCTVAuthServiceRemotePort,
 		SSHRemotePort:              req.SSHRemotePort,
-	}
-	if req.IsActive != nil {
-		device.IsActive = *req.IsActive
+		LifecycleState:             req.LifecycleState,
 	}
 
-	result, err := c.service.Create(r.Context(), device)
+	result, err := c.service.Create(r.Context(), device, requestActor(r))
 	if err != nil {
-		respondError(w, http.StatusBadRequest, err.Error())
+		respondServiceError(w, err, http.StatusBadRequest)
 		return
 	}
 	respondData(w, http.StatusCreated, result)
@@ -100,24 +119,43 @@ func (c *OrangePiController) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	buildingID, err := c.resolveBuildingID(r, req)
+	if err != nil {
+		respondServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+
 	device := models.OrangePi{
-		ISmartID:                   req.ISmartID,
+		BuildingID:                 buildingID,
 		Name:                       req.Name,
 		ICCTVAuthServiceRemotePort: req.ICCTVAuthServiceRemotePort,
 		SSHRemotePort:              req.SSHRemotePort,
 	}
-	if req.IsActive != nil {
-		device.IsActive = *req.IsActive
+	if req.LifecycleState != "" {
+		respondError(w, http.StatusBadRequest, "lifecycle_state cannot be updated here, use POST /api/orangepi/{id}/lifecycle")
+		return
 	}
 
-	result, err := c.service.Update(r.Context(), id, device)
+	result, err := c.service.Update(r.Context(), id, device, requestActor(r))
 	if err != nil {
-		respondError(w, http.StatusBadRequest, err.Error())
+		respondServiceError(w, err, http.StatusBadRequest)
 		return
 	}
 	respondData(w, http.StatusOK, result)
 }
 
+// resolveBuildingID 优先使用 building_id，否则按旧字段 ismartid 查找建筑
+func (c *OrangePiController) resolveBuildingID(r *http.Request, req orangePiPayload) (*int64, error) {
+	if req.BuildingID != nil || req.ISmartID == "" {
+		return req.BuildingID, nil
+	}
+	id, err := c.service.BuildingIDByISmartID(r.Context(), req.ISmartID)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
 type deleteOrangePiRequest struct {
 	ID int64 `json:"id"`
 }
@@ -146,7 +184,7 @@ type remoteUpdatePortsRequest struct {
 	AuthPort int   `json:"icctv_auth_service_remote_port"`
 }
 
-// 5. RemoteUpdatePorts 远程更新设备端口
+// 5. RemoteUpdatePorts 远程更新设备端口，以端口迁移执行（设备ID校验、新端口探测、失败回滚）；?async=true 时创建异步任务并返回 202
 func (c *OrangePiController) RemoteUpdatePorts(w http.ResponseWriter, r *http.Request) {
 	var req remoteUpdatePortsRequest
 	if err := decodeJSON(r, &req); err != nil {
@@ -157,16 +195,23 @@ func (c *OrangePiController) RemoteUpdatePorts(w http.ResponseWriter, r *http.Re
 		respondError(w, http.StatusBadRequest, "id, ssh_remote_port and icctv_auth_service_remote_port are required")
 		return
 	}
+	if isAsync(r) {
+		c.enqueue(w, r, models.JobTypeRemoteUpdatePorts, req.ID, map[string]interface{}{
+			"ssh_remote_port":                req.SSHPort,
+			"icctv_auth_service_remote_port": req.AuthPort,
+		})
+		return
+	}
 
-	result, err := c.service.RemoteUpdatePorts(r.Context(), req.ID, req.SSHPort, req.AuthPort)
+	result, err := c.portMigrationService.UpdatePorts(r.Context(), req.ID, req.SSHPort, req.AuthPort, adminUsername(r))
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, err.Error())
+		handleServiceError(w, err)
 		return
 	}
 	respondData(w, http.StatusOK, result)
 }
 
-// 6. RemoteGetInfo 远程获取设备信息
+// 6. RemoteGetInfo 远程获取设备信息（?async=true 时创建异步任务并返回 202）
 func (c *OrangePiController) RemoteGetInfo(w http.ResponseWriter, r *http.Request) {
 	idStr := r.URL.Query().Get("id")
 	if idStr == "" {
@@ -179,6 +224,11 @@ func (c *OrangePiController) RemoteGetInfo(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if isAsync(r) {
+		c.enqueue(w, r, models.JobTypeRemoteInfo, id, nil)
+		return
+	}
+
 	deviceInfo, err := c.service.RemoteGetInfo(r.Context(), id)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, err.Error())
@@ -187,7 +237,7 @@ func (c *OrangePiController) RemoteGetInfo(w http.ResponseWriter, r *http.Reques
 	respondData(w, http.StatusOK, deviceInfo)
 }
 
-// 7. RemoteHealthCheck 远程健康检查
+// 7. RemoteHealthCheck 远程健康检查（?async=true 时创建异步任务并返回 202）
 func (c *OrangePiController) RemoteHealthCheck(w http.ResponseWriter, r *http.Request) {
 	idStr := r.URL.Query().Get("id")
 	if idStr == "" {
@@ -200,6 +250,11 @@ func (c *OrangePiController) RemoteHealthCheck(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	if isAsync(r) {
+		c.enqueue(w, r, models.JobTypeHealthCheck, id, nil)
+		return
+	}
+
 	healthStatus, err := c.service.RemoteHealthCheck(r.Context(), id)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, err.Error())
@@ -207,3 +262,92 @@ func (c *OrangePiController) RemoteHealthCheck(w http.ResponseWriter, r *http.Re
 	}
 	respondData(w, http.StatusOK, healthStatus)
 }
+
+type acceptDeviceIDRequest struct {
+	ID int64 `json:"id"`
+}
+
+// 8. AcceptDeviceID 确认端口上实际应答的设备ID，清除不一致标记
+func (c *OrangePiController) AcceptDeviceID(w http.ResponseWriter, r *http.Request) {
+	var req acceptDeviceIDRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.ID <= 0 {
+		respondError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+
+	device, err := c.service.AcceptReportedDeviceID(r.Context(), req.ID)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, device)
+}
+
+// 9. PortPool 查询 FRP 端口池使用情况
+func (c *OrangePiController) PortPool(w http.ResponseWriter, r *http.Request) {
+	report, err := c.service.PortPoolReport(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondData(w, http.StatusOK, report)
+}
+
+// 10. Heartbeat 设备心跳，使用 X-Device-Key 认证，同时上报 agent 与 MediaMTX 版本
+func (c *OrangePiController) Heartbeat(w http.ResponseWriter, r *http.Request) {
+	var req services.DeviceHeartbeat
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	device, err := c.service.Heartbeat(r.Context(), r.Header.Get("X-Device-Key"), req)
+	if err != nil {
+		if errors.Is(err, services.ErrDeviceUnauthorized) {
+			respondError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, map[string]interface{}{
+		"orangepi_id": device.ID,
+		"server_time": time.Now(),
+	})
+}
+
+// 11. Versions 全部设备的 agent / MediaMTX 版本分布
+func (c *OrangePiController) Versions(w http.ResponseWriter, r *http.Request) {
+	report, err := c.service.VersionReport(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondData(w, http.StatusOK, report)
+}
+
+// isAsync 请求是否要求以异步任务方式执行
+func isAsync(r *http.Request) bool {
+	async, _ := strconv.ParseBool(r.URL.Query().Get("async"))
+	return async
+}
+
+// enqueue 为单个设备创建异步任务，返回 202 和任务记录（通过 GET /api/jobs/{id} 查询结果）
+func (c *OrangePiController) enqueue(w http.ResponseWriter, r *http.Request, jobType string, id int64, params map[string]interface{}) {
+	job, err := c.jobService.Enqueue(r.Context(), services.EnqueueJobRequest{
+		Type:        jobType,
+		OrangePiIDs: []int64{id},
+		Params:      params,
+	}, adminUsername(r))
+	if err != nil {
+		respondServiceError(w, err, http.StatusInternalServerError)
+		return
+	}
+	respondData(w, http.StatusAccepted, job)
+}
@@ -0,0 +1,104 @@
+package controllers
+
+// PortMigrationController Methods:
+//0. NewPortMigrationController(service *services.PortMigrationService) -> 注入 PortMigrationService
+//1. Migrate(w http.ResponseWriter, r *http.Request) -> 迁移设备 FRP 端口
+//2. List(w http.ResponseWriter, r *http.Request) -> 查询迁移记录
+//3. Resolve(w http.ResponseWriter, r *http.Request) -> 清除迁移失败/中断标记
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"icctv-http-service/services"
+)
+
+// PortMigrationControllerInterface 定义端口迁移接口能力
+type PortMigrationControllerInterface interface {
+	Migrate(w http.ResponseWriter, r *http.Request) //1.迁移端口
+	List(w http.ResponseWriter, r *http.Request)    //2.迁移记录
+	Resolve(w http.ResponseWriter, r *http.Request) //3.清除失败/中断标记
+}
+
+// PortMigrationController 端口迁移接口
+type PortMigrationController struct {
+	service *services.PortMigrationService
+}
+
+// 0. NewPortMigrationController 构造函数
+func NewPortMigrationController(service *services.PortMigrationService) *PortMigrationController {
+	return &PortMigrationController{service: service}
+}
+
+type migratePortsRequest struct {
+	ID              int64 `json:"id"`
+	SSHPort         int   `json:"ssh_remote_port"`
+	AuthPort        int   `json:"icctv_auth_service_remote_port"`
+	DeadlineSeconds int   `json:"deadline_seconds"`
+}
+
+// 1. Migrate 迁移设备 FRP 端口，返回包含每一步结果的迁移记录
+func (c *PortMigrationController) Migrate(w http.ResponseWriter, r *http.Request) {
+	var req migratePortsRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.ID == 0 || req.SSHPort == 0 || req.AuthPort == 0 {
+		respondError(w, http.StatusBadRequest, "id, ssh_remote_port and icctv_auth_service_remote_port are required")
+		return
+	}
+
+	migration, err := c.service.Migrate(r.Context(), req.ID, req.SSHPort, req.AuthPort,
+		time.Duration(req.DeadlineSeconds)*time.Second, adminUsername(r))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, migration)
+}
+
+// 2. List 查询迁移记录，可按 orangepi_id 筛选
+func (c *PortMigrationController) List(w http.ResponseWriter, r *http.Request) {
+	var orangePiID int64
+	if idStr := r.URL.Query().Get("orangepi_id"); idStr != "" {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid orangepi_id")
+			return
+		}
+		orangePiID = id
+	}
+
+	items, err := c.service.List(r.Context(), orangePiID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondData(w, http.StatusOK, items)
+}
+
+type resolveMigrationRequest struct {
+	OrangePiID int64 `json:"orangepi_id"`
+}
+
+// 3. Resolve 人工处理完成后清除设备的 port-migration-failed 标记或服务重启遗留的 port-migrating 标记
+func (c *PortMigrationController) Resolve(w http.ResponseWriter, r *http.Request) {
+	var req resolveMigrationRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.OrangePiID <= 0 {
+		respondError(w, http.StatusBadRequest, "orangepi_id must be a positive integer")
+		return
+	}
+
+	device, err := c.service.Resolve(r.Context(), req.OrangePiID)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, device)
+}
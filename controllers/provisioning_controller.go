@@ -0,0 +1,111 @@
+package controllers
+
+// ProvisioningController Methods:
+//0. NewProvisioningController(service *services.ProvisioningService) -> 注入 ProvisioningService
+//1. Bundle(w http.ResponseWriter, r *http.Request) -> 下载设备部署包(zip)
+//2. ListTemplates(w http.ResponseWriter, r *http.Request) -> 查询部署包模板
+//3. UpdateTemplate(w http.ResponseWriter, r *http.Request) -> 修改部署包模板
+//4. ResetTemplate(w http.ResponseWriter, r *http.Request) -> 恢复默认模板
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"icctv-http-service/services"
+)
+
+// ProvisioningControllerInterface 定义部署包接口能力
+type ProvisioningControllerInterface interface {
+	Bundle(w http.ResponseWriter, r *http.Request)         //1.下载部署包
+	ListTemplates(w http.ResponseWriter, r *http.Request)  //2.模板列表
+	UpdateTemplate(w http.ResponseWriter, r *http.Request) //3.修改模板
+	ResetTemplate(w http.ResponseWriter, r *http.Request)  //4.恢复默认
+}
+
+// ProvisioningController 部署包接口
+type ProvisioningController struct {
+	service *services.ProvisioningService
+}
+
+// 0. NewProvisioningController 构造函数
+func NewProvisioningController(service *services.ProvisioningService) *ProvisioningController {
+	return &ProvisioningController{service: service}
+}
+
+// 1. Bundle 下载设备部署包(frpc.toml、.env、docker-compose.yml)
+func (c *ProvisioningController) Bundle(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		respondError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	bundle, err := c.service.Bundle(r.Context(), id, requestActor(r))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", bundle.FileName))
+	w.Header().Set("Content-Length", strconv.Itoa(len(bundle.Content)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(bundle.Content)
+}
+
+// 2. ListTemplates 查询部署包模板
+func (c *ProvisioningController) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := c.service.ListTemplates(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondData(w, http.StatusOK, templates)
+}
+
+type provisioningTemplateRequest struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// 3. UpdateTemplate 修改模板内容，模板无法渲染时返回 400
+func (c *ProvisioningController) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	var req provisioningTemplateRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Name == "" || req.Content == "" {
+		respondError(w, http.StatusBadRequest, "name and content are required")
+		return
+	}
+
+	tpl, err := c.service.UpdateTemplate(r.Context(), req.Name, req.Content, requestActor(r))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, tpl)
+}
+
+// 4. ResetTemplate 恢复内置默认模板
+func (c *ProvisioningController) ResetTemplate(w http.ResponseWriter, r *http.Request) {
+	var req provisioningTemplateRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Name == "" {
+		respondError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	tpl, err := c.service.ResetTemplate(r.Context(), req.Name, requestActor(r))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, tpl)
+}
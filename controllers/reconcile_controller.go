@@ -0,0 +1,83 @@
+package controllers
+
+// ReconcileController Methods:
+//0. NewReconcileController(service *services.ReconcileService) -> 注入 ReconcileService
+//1. Drift(w http.ResponseWriter, r *http.Request) -> 查询最近一次对账偏差
+//2. Run(w http.ResponseWriter, r *http.Request) -> 立即对账(可选修复或预演)
+//3. SetDisabled(w http.ResponseWriter, r *http.Request) -> 设置设备是否参与对账
+
+import (
+	"net/http"
+
+	"icctv-http-service/services"
+)
+
+// ReconcileControllerInterface 定义期望状态对账接口能力
+type ReconcileControllerInterface interface {
+	Drift(w http.ResponseWriter, r *http.Request)       //1.偏差列表
+	Run(w http.ResponseWriter, r *http.Request)         //2.立即对账
+	SetDisabled(w http.ResponseWriter, r *http.Request) //3.对账开关
+}
+
+// ReconcileController 期望状态对账接口
+type ReconcileController struct {
+	service *services.ReconcileService
+}
+
+// 0. NewReconcileController 构造函数
+func NewReconcileController(service *services.ReconcileService) *ReconcileController {
+	return &ReconcileController{service: service}
+}
+
+// 1. Drift 查询每个设备最近一次对账结果，drifted=true 时只返回存在偏差的设备
+func (c *ReconcileController) Drift(w http.ResponseWriter, r *http.Request) {
+	items, err := c.service.List(r.Context(), r.URL.Query().Get("drifted") == "true")
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondData(w, http.StatusOK, items)
+}
+
+// 2. Run 立即对账，请求体可省略（检查全部设备，只报告不修复）
+func (c *ReconcileController) Run(w http.ResponseWriter, r *http.Request) {
+	var req services.ReconcileRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	results, err := c.service.Run(r.Context(), req)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, results)
+}
+
+type setReconcileDisabledRequest struct {
+	ID       int64 `json:"id"`
+	Disabled bool  `json:"disabled"`
+}
+
+// 3. SetDisabled 设置设备"不参与对账"标记
+func (c *ReconcileController) SetDisabled(w http.ResponseWriter, r *http.Request) {
+	var req setReconcileDisabledRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.ID <= 0 {
+		respondError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+
+	device, err := c.service.SetDisabled(r.Context(), req.ID, req.Disabled)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, device)
+}
@@ -0,0 +1,97 @@
+package controllers
+
+// RecordingController Methods:
+//0. NewRecordingController(service *services.RecordingService) -> 注入 RecordingService
+//1. Search(w http.ResponseWriter, r *http.Request) -> 查询录像片段（附带下载链接）
+//2. DownloadURL(w http.ResponseWriter, r *http.Request) -> 生成签名下载链接
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"icctv-http-service/middlewares"
+	"icctv-http-service/services"
+)
+
+// RecordingControllerInterface 定义录像接口能力
+type RecordingControllerInterface interface {
+	Search(w http.ResponseWriter, r *http.Request)      //1.查询录像
+	DownloadURL(w http.ResponseWriter, r *http.Request) //2.下载链接
+}
+
+// RecordingController 录像接口（管理员 JWT 或覆盖该楼栋频道的视频 Token 均可调用）
+type RecordingController struct {
+	service *services.RecordingService
+}
+
+// 0. NewRecordingController 构造函数
+func NewRecordingController(service *services.RecordingService) *RecordingController {
+	return &RecordingController{service: service}
+}
+
+// 1. Search 查询录像：?ismartid=&channel=&start=&end=(RFC3339)&orangepi_id=(可选)
+func (c *RecordingController) Search(w http.ResponseWriter, r *http.Request) {
+	query, ok := parseRecordingQuery(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := c.service.Search(r.Context(), query, middlewares.VideoTokenFromContext(r.Context()))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, result)
+}
+
+// 2. DownloadURL 生成下载链接：参数同 Search，链接经公网IP与设备认证端口访问，短时有效
+func (c *RecordingController) DownloadURL(w http.ResponseWriter, r *http.Request) {
+	query, ok := parseRecordingQuery(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := c.service.DownloadURL(r.Context(), query, middlewares.VideoTokenFromContext(r.Context()))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, result)
+}
+
+// parseRecordingQuery 解析录像查询参数，失败时直接返回 400
+func parseRecordingQuery(w http.ResponseWriter, r *http.Request) (services.RecordingQuery, bool) {
+	q := r.URL.Query()
+	query := services.RecordingQuery{ISmartID: q.Get("ismartid")}
+	if query.ISmartID == "" {
+		respondError(w, http.StatusBadRequest, "ismartid is required")
+		return query, false
+	}
+
+	channel, err := strconv.Atoi(q.Get("channel"))
+	if err != nil || channel <= 0 {
+		respondError(w, http.StatusBadRequest, "invalid channel")
+		return query, false
+	}
+	query.Channel = channel
+
+	if query.Start, err = time.Parse(time.RFC3339, q.Get("start")); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid start, expected RFC3339")
+		return query, false
+	}
+	if query.End, err = time.Parse(time.RFC3339, q.Get("end")); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid end, expected RFC3339")
+		return query, false
+	}
+
+	if val := q.Get("orangepi_id"); val != "" {
+		id, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || id <= 0 {
+			respondError(w, http.StatusBadRequest, "invalid orangepi_id")
+			return query, false
+		}
+		query.OrangePiID = id
+	}
+	return query, true
+}
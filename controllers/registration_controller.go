@@ -0,0 +1,165 @@
+package controllers
+
+// RegistrationController Methods:
+//0. NewRegistrationController(service *services.RegistrationService) -> 注入 RegistrationService
+//1. Register(w http.ResponseWriter, r *http.Request) -> OrangePi 提交注册申请
+//2. List(w http.ResponseWriter, r *http.Request) -> 查询注册申请
+//3. Approve(w http.ResponseWriter, r *http.Request) -> 审批通过
+//4. Reject(w http.ResponseWriter, r *http.Request) -> 拒绝申请
+//5. Expire(w http.ResponseWriter, r *http.Request) -> 过期陈旧申请
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"time"
+
+	"icctv-http-service/models"
+	"icctv-http-service/services"
+)
+
+// RegistrationControllerInterface 定义设备注册接口能力
+type RegistrationControllerInterface interface {
+	Register(w http.ResponseWriter, r *http.Request) //1.设备提交注册
+	List(w http.ResponseWriter, r *http.Request)     //2.查询注册申请
+	Approve(w http.ResponseWriter, r *http.Request)  //3.审批通过
+	Reject(w http.ResponseWriter, r *http.Request)   //4.拒绝申请
+	Expire(w http.ResponseWriter, r *http.Request)   //5.过期陈旧申请
+}
+
+// RegistrationController 设备注册接口
+type RegistrationController struct {
+	service       *services.RegistrationService
+	enrollmentKey string
+}
+
+// 0. NewRegistrationController 构造函数
+func NewRegistrationController(service *services.RegistrationService) *RegistrationController {
+	return &RegistrationController{
+		service:       service,
+		enrollmentKey: os.Getenv("DEVICE_ENROLLMENT_KEY"),
+	}
+}
+
+type registerDeviceRequest struct {
+	DeviceID                   string `json:"device_id"`
+	Name                       string `json:"name"`
+	ICCTVAuthServiceRemotePort int    `json:"icctv_auth_service_remote_port"`
+	SSHRemotePort              int    `json:"ssh_remote_port"`
+	AgentVersion               string `json:"agent_version"`
+	MediaMTXVersion            string `json:"mediamtx_version"`
+}
+
+// 1. Register OrangePi 提交注册申请，需携带与 DEVICE_ENROLLMENT_KEY 一致的 X-Enrollment-Key；未配置注册密钥时拒绝注册（503）
+func (c *RegistrationController) Register(w http.ResponseWriter, r *http.Request) {
+	if c.enrollmentKey == "" {
+		respondError(w, http.StatusServiceUnavailable, "device registration is disabled (DEVICE_ENROLLMENT_KEY not set)")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Enrollment-Key")), []byte(c.enrollmentKey)) != 1 {
+		respondError(w, http.StatusUnauthorized, "invalid enrollment key")
+		return
+	}
+
+	var req registerDeviceRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	item, err := c.service.Register(r.Context(), models.DeviceRegistration{
+		DeviceID:                   req.DeviceID,
+		Name:                       req.Name,
+		ICCTVAuthServiceRemotePort: req.ICCTVAuthServiceRemotePort,
+		SSHRemotePort:              req.SSHRemotePort,
+		AgentVersion:               req.AgentVersion,
+		MediaMTXVersion:            req.MediaMTXVersion,
+	})
+	respondResult(w, err, http.StatusAccepted, item)
+}
+
+// 2. List 查询注册申请，可按 status 筛选
+func (c *RegistrationController) List(w http.ResponseWriter, r *http.Request) {
+	items, err := c.service.List(r.Context(), r.URL.Query().Get("status"))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondData(w, http.StatusOK, items)
+}
+
+type approveRegistrationRequest struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	BuildingID int64  `json:"building_id"`
+}
+
+// 3. Approve 审批通过，返回设备记录及凭证
+func (c *RegistrationController) Approve(w http.ResponseWriter, r *http.Request) {
+	var req approveRegistrationRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.ID <= 0 {
+		respondError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+
+	result, err := c.service.Approve(r.Context(), req.ID, services.ApproveRegistrationRequest{
+		Name:       req.Name,
+		BuildingID: req.BuildingID,
+	}, adminUsername(r))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, result)
+}
+
+type rejectRegistrationRequest struct {
+	ID     int64  `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// 4. Reject 拒绝注册申请
+func (c *RegistrationController) Reject(w http.ResponseWriter, r *http.Request) {
+	var req rejectRegistrationRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.ID <= 0 {
+		respondError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+
+	item, err := c.service.Reject(r.Context(), req.ID, req.Reason, adminUsername(r))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, item)
+}
+
+type expireRegistrationRequest struct {
+	OlderThanHours int `json:"older_than_hours"`
+}
+
+// 5. Expire 将超过指定小时数（默认72小时）仍未审批的申请标记为过期
+func (c *RegistrationController) Expire(w http.ResponseWriter, r *http.Request) {
+	req := expireRegistrationRequest{OlderThanHours: 72}
+	if r.ContentLength > 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	count, err := c.service.ExpireStale(r.Context(), time.Duration(req.OlderThanHours)*time.Hour, adminUsername(r))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondData(w, http.StatusOK, map[string]int64{"expired": count})
+}
@@ -0,0 +1,146 @@
+package controllers
+
+// RolloutController Methods:
+//0. NewRolloutController(service *services.RolloutService) -> 注入 RolloutService
+//1. Create(w http.ResponseWriter, r *http.Request) -> 创建升级活动
+//2. List(w http.ResponseWriter, r *http.Request) -> 分页查询升级活动
+//3. Get(w http.ResponseWriter, r *http.Request) -> 查询活动及设备明细
+//4. Pause(w http.ResponseWriter, r *http.Request) -> 暂停
+//5. Resume(w http.ResponseWriter, r *http.Request) -> 继续
+//6. Cancel(w http.ResponseWriter, r *http.Request) -> 取消
+
+import (
+	"net/http"
+
+	"icctv-http-service/models"
+	"icctv-http-service/services"
+)
+
+// RolloutControllerInterface 定义升级活动接口能力
+type RolloutControllerInterface interface {
+	Create(w http.ResponseWriter, r *http.Request) //1.创建活动
+	List(w http.ResponseWriter, r *http.Request)   //2.活动列表
+	Get(w http.ResponseWriter, r *http.Request)    //3.活动详情
+	Pause(w http.ResponseWriter, r *http.Request)  //4.暂停
+	Resume(w http.ResponseWriter, r *http.Request) //5.继续
+	Cancel(w http.ResponseWriter, r *http.Request) //6.取消
+}
+
+// RolloutController 升级活动接口
+type RolloutController struct {
+	service *services.RolloutService
+}
+
+// 0. NewRolloutController 构造函数
+func NewRolloutController(service *services.RolloutService) *RolloutController {
+	return &RolloutController{service: service}
+}
+
+// 1. Create 创建升级活动并立即开始（有金丝雀比例时先升级金丝雀设备）
+func (c *RolloutController) Create(w http.ResponseWriter, r *http.Request) {
+	var req services.CreateRolloutRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	campaign, err := c.service.Create(r.Context(), req, requestActor(r))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusAccepted, campaign)
+}
+
+// 2. List 分页查询升级活动，可按 status 筛选
+func (c *RolloutController) List(w http.ResponseWriter, r *http.Request) {
+	query := services.RolloutListQuery{
+		PaginationQuery: models.PaginationQuery{
+			PageNum:  parseInt(r.URL.Query().Get("pageNum"), 1),
+			PageSize: parseInt(r.URL.Query().Get("pageSize"), 20),
+			Asc:      r.URL.Query().Get("asc") == "true",
+		},
+		Status: r.URL.Query().Get("status"),
+	}
+
+	campaigns, pageResult, err := c.service.List(r.Context(), query)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondData(w, http.StatusOK, map[string]interface{}{
+		"items": campaigns,
+		"page":  pageResult,
+	})
+}
+
+// 3. Get 查询活动及每台设备的升级结果
+func (c *RolloutController) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	campaign, err := c.service.Get(r.Context(), id)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, campaign)
+}
+
+// 4. Pause 暂停活动，正在升级的设备会继续完成
+func (c *RolloutController) Pause(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	campaign, err := c.service.Pause(r.Context(), id, requestActor(r))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, campaign)
+}
+
+type resumeRolloutRequest struct {
+	RetryFailed bool `json:"retry_failed"`
+}
+
+// 5. Resume 继续已暂停的活动，请求体可省略；retry_failed=true 时重新升级失败的设备
+func (c *RolloutController) Resume(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	var req resumeRolloutRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	campaign, err := c.service.Resume(r.Context(), id, req.RetryFailed, requestActor(r))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, campaign)
+}
+
+// 6. Cancel 取消活动，尚未升级的设备标记为跳过
+func (c *RolloutController) Cancel(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	campaign, err := c.service.Cancel(r.Context(), id, requestActor(r))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, campaign)
+}
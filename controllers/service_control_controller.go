@@ -0,0 +1,92 @@
+package controllers
+
+// ServiceControlController Methods:
+//0. NewServiceControlController(service *services.ServiceControlService) -> 注入 ServiceControlService
+//1. Control(w http.ResponseWriter, r *http.Request) -> 启停单个设备上的服务
+//2. ControlBuilding(w http.ResponseWriter, r *http.Request) -> 启停建筑下所有设备上的服务
+//3. Allowed(w http.ResponseWriter, r *http.Request) -> 允许控制的服务名
+
+import (
+	"net"
+	"net/http"
+
+	"icctv-http-service/services"
+)
+
+// ServiceControlControllerInterface 定义远程容器控制接口能力
+type ServiceControlControllerInterface interface {
+	Control(w http.ResponseWriter, r *http.Request)         //1.单设备
+	ControlBuilding(w http.ResponseWriter, r *http.Request) //2.整栋建筑
+	Allowed(w http.ResponseWriter, r *http.Request)         //3.允许的服务
+}
+
+// ServiceControlController 远程容器控制接口
+type ServiceControlController struct {
+	service *services.ServiceControlService
+}
+
+// 0. NewServiceControlController 构造函数
+func NewServiceControlController(service *services.ServiceControlService) *ServiceControlController {
+	return &ServiceControlController{service: service}
+}
+
+type serviceControlRequest struct {
+	ID         int64  `json:"id"`
+	BuildingID int64  `json:"building_id"`
+	Service    string `json:"service"`
+	Action     string `json:"action"`
+}
+
+// 1. Control 启停单个设备上的服务，返回设备执行结果
+func (c *ServiceControlController) Control(w http.ResponseWriter, r *http.Request) {
+	var req serviceControlRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.ID <= 0 || req.Service == "" || req.Action == "" {
+		respondError(w, http.StatusBadRequest, "id, service and action are required")
+		return
+	}
+
+	result, err := c.service.Control(r.Context(), req.ID, req.Service, req.Action, requestActor(r))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, result)
+}
+
+// 2. ControlBuilding 对建筑下所有设备执行同一操作，返回每个设备的结果
+func (c *ServiceControlController) ControlBuilding(w http.ResponseWriter, r *http.Request) {
+	var req serviceControlRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.BuildingID <= 0 || req.Service == "" || req.Action == "" {
+		respondError(w, http.StatusBadRequest, "building_id, service and action are required")
+		return
+	}
+
+	results, err := c.service.ControlBuilding(r.Context(), req.BuildingID, req.Service, req.Action, requestActor(r))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, results)
+}
+
+// 3. Allowed 允许控制的服务名（REMOTE_SERVICE_ALLOWLIST）
+func (c *ServiceControlController) Allowed(w http.ResponseWriter, r *http.Request) {
+	respondData(w, http.StatusOK, c.service.AllowedServices())
+}
+
+// requestActor 当前管理员及请求来源IP，用于审计日志
+func requestActor(r *http.Request) services.Actor {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return services.Actor{Username: adminUsername(r), ClientIP: ip}
+}
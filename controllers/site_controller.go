@@ -0,0 +1,161 @@
+package controllers
+
+// SiteController Methods:
+//0. NewSiteController(service *services.SiteService) -> 注入 SiteService
+//1. List(w http.ResponseWriter, r *http.Request) -> 查询站点节点
+//2. Create(w http.ResponseWriter, r *http.Request) -> 创建站点节点
+//3. Update(w http.ResponseWriter, r *http.Request) -> 更新站点节点
+//4. Delete(w http.ResponseWriter, r *http.Request) -> 删除站点节点
+//5. Tree(w http.ResponseWriter, r *http.Request) -> 查询节点子树
+//6. ListGrants(w http.ResponseWriter, r *http.Request) -> 查询站点授权
+//7. CreateGrant(w http.ResponseWriter, r *http.Request) -> 新增站点授权
+//8. DeleteGrant(w http.ResponseWriter, r *http.Request) -> 撤销站点授权
+
+import (
+	"net/http"
+	"strconv"
+
+	"icctv-http-service/middlewares"
+	"icctv-http-service/models"
+	"icctv-http-service/services"
+)
+
+// SiteControllerInterface 定义站点接口能力
+type SiteControllerInterface interface {
+	List(w http.ResponseWriter, r *http.Request)        //1.查询站点节点
+	Create(w http.ResponseWriter, r *http.Request)      //2.创建站点节点
+	Update(w http.ResponseWriter, r *http.Request)      //3.更新站点节点
+	Delete(w http.ResponseWriter, r *http.Request)      //4.删除站点节点
+	Tree(w http.ResponseWriter, r *http.Request)        //5.查询节点子树
+	ListGrants(w http.ResponseWriter, r *http.Request)  //6.查询站点授权
+	CreateGrant(w http.ResponseWriter, r *http.Request) //7.新增站点授权
+	DeleteGrant(w http.ResponseWriter, r *http.Request) //8.撤销站点授权
+}
+
+// SiteController 站点接口
+type SiteController struct {
+	service *services.SiteService
+}
+
+// 0. NewSiteController 构造函数
+func NewSiteController(service *services.SiteService) *SiteController {
+	return &SiteController{service: service}
+}
+
+// 1. List 查询站点节点：?kind=site|floor|zone&building_id=（受限管理员只返回授权范围内的节点）
+func (c *SiteController) List(w http.ResponseWriter, r *http.Request) {
+	query := services.SiteListQuery{Kind: r.URL.Query().Get("kind")}
+	if val := r.URL.Query().Get("building_id"); val != "" {
+		id, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid building_id parameter")
+			return
+		}
+		query.BuildingID = id
+	}
+	items, err := c.service.List(r.Context(), query, middlewares.SiteScopeFromContext(r.Context()))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, items)
+}
+
+// 2. Create 创建站点节点
+func (c *SiteController) Create(w http.ResponseWriter, r *http.Request) {
+	var req models.Site
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	item, err := c.service.Create(r.Context(), req, requestActor(r))
+	if err != nil {
+		respondServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	respondData(w, http.StatusCreated, item)
+}
+
+// 3. Update 更新站点节点：/api/site/{id}
+func (c *SiteController) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	var req models.Site
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	item, err := c.service.Update(r.Context(), id, req, requestActor(r))
+	if err != nil {
+		respondServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	respondData(w, http.StatusOK, item)
+}
+
+// 4. Delete 删除站点节点，仍有下级节点、建筑或摄像头时返回 409
+func (c *SiteController) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if err := c.service.Delete(r.Context(), id, requestActor(r)); err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, map[string]bool{"deleted": true})
+}
+
+// 5. Tree 查询节点子树：/api/site/{id}/tree
+func (c *SiteController) Tree(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	tree, err := c.service.Tree(r.Context(), id, middlewares.SiteScopeFromContext(r.Context()))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, tree)
+}
+
+// 6. ListGrants 查询站点授权：?username=
+func (c *SiteController) ListGrants(w http.ResponseWriter, r *http.Request) {
+	items, err := c.service.ListGrants(r.Context(), r.URL.Query().Get("username"))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, items)
+}
+
+// 7. CreateGrant 新增站点授权
+func (c *SiteController) CreateGrant(w http.ResponseWriter, r *http.Request) {
+	var req models.SiteGrant
+	if err := decodeJSON(r, &req); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	item, err := c.service.CreateGrant(r.Context(), req, requestActor(r))
+	if err != nil {
+		respondServiceError(w, err, http.StatusBadRequest)
+		return
+	}
+	respondData(w, http.StatusCreated, item)
+}
+
+// 8. DeleteGrant 撤销站点授权：/api/site/grants/{id}
+func (c *SiteController) DeleteGrant(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if err := c.service.DeleteGrant(r.Context(), id, requestActor(r)); err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, map[string]bool{"deleted": true})
+}
@@ -0,0 +1,83 @@
+package controllers
+
+// StreamController Methods:
+//0. NewStreamController(service *services.StreamService) -> 注入 StreamService
+//1. Paths(w http.ResponseWriter, r *http.Request) -> 实时获取设备 MediaMTX 路径状态
+//2. Samples(w http.ResponseWriter, r *http.Request) -> 查询历史采样
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"icctv-http-service/services"
+)
+
+// StreamControllerInterface 定义推流状态接口能力
+type StreamControllerInterface interface {
+	Paths(w http.ResponseWriter, r *http.Request)   //1.实时路径状态
+	Samples(w http.ResponseWriter, r *http.Request) //2.历史采样
+}
+
+// StreamController 推流状态接口
+type StreamController struct {
+	service *services.StreamService
+}
+
+// 0. NewStreamController 构造函数
+func NewStreamController(service *services.StreamService) *StreamController {
+	return &StreamController{service: service}
+}
+
+// 1. Paths 实时获取设备上 MediaMTX 路径的就绪状态、源类型、读取数与流量（?id=）
+func (c *StreamController) Paths(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDFromQuery(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	paths, err := c.service.Paths(r.Context(), id)
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, paths)
+}
+
+// 2. Samples 查询历史采样：?id=&path=&since=(RFC3339，默认24小时内)&limit=(默认500)
+func (c *StreamController) Samples(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIDFromQuery(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	q := r.URL.Query()
+	query := services.StreamSampleQuery{
+		OrangePiID: id,
+		PathName:   q.Get("path"),
+	}
+	if since := q.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid since, expected RFC3339")
+			return
+		}
+		query.Since = t
+	}
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		query.Limit = n
+	}
+
+	items, err := c.service.Samples(r.Context(), query)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondData(w, http.StatusOK, items)
+}
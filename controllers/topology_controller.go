@@ -0,0 +1,42 @@
+package controllers
+
+// TopologyController Methods:
+//0. NewTopologyController(service *services.TopologyService) -> 注入 TopologyService
+//1. Building(w http.ResponseWriter, r *http.Request) -> 查询楼栋拓扑
+
+import (
+	"net/http"
+
+	"icctv-http-service/middlewares"
+	"icctv-http-service/services"
+)
+
+// TopologyControllerInterface 定义楼栋拓扑接口能力
+type TopologyControllerInterface interface {
+	Building(w http.ResponseWriter, r *http.Request) //1.楼栋拓扑
+}
+
+// TopologyController 楼栋拓扑接口
+type TopologyController struct {
+	service *services.TopologyService
+}
+
+// 0. NewTopologyController 构造函数
+func NewTopologyController(service *services.TopologyService) *TopologyController {
+	return &TopologyController{service: service}
+}
+
+// 1. Building 查询楼栋拓扑：/api/building/{id}/topology，?refresh=true 时忽略缓存重新检查设备健康
+func (c *TopologyController) Building(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	refresh := r.URL.Query().Get("refresh") == "true"
+	topology, err := c.service.Building(r.Context(), id, refresh, middlewares.SiteScopeFromContext(r.Context()))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, topology)
+}
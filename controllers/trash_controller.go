@@ -0,0 +1,67 @@
+package controllers
+
+// TrashController Methods:
+//0. NewTrashController(service *services.TrashService) -> 注入 TrashService
+//1. List(w http.ResponseWriter, r *http.Request) -> 查询回收站中的已删除记录
+//2. Restore(w http.ResponseWriter, r *http.Request) -> 恢复已删除记录
+//3. Purge(w http.ResponseWriter, r *http.Request) -> 彻底删除回收站中的记录
+
+import (
+	"net/http"
+
+	"icctv-http-service/services"
+)
+
+// TrashControllerInterface 定义回收站接口能力
+type TrashControllerInterface interface {
+	List(w http.ResponseWriter, r *http.Request)    //1.回收站列表
+	Restore(w http.ResponseWriter, r *http.Request) //2.恢复记录
+	Purge(w http.ResponseWriter, r *http.Request)   //3.彻底删除
+}
+
+// TrashController 回收站接口
+type TrashController struct {
+	service *services.TrashService
+}
+
+// 0. NewTrashController 构造函数
+func NewTrashController(service *services.TrashService) *TrashController {
+	return &TrashController{service: service}
+}
+
+// 1. List 查询回收站中的已删除记录：/api/trash/{resource}
+func (c *TrashController) List(w http.ResponseWriter, r *http.Request) {
+	items, err := c.service.List(r.Context(), r.PathValue("resource"))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, items)
+}
+
+// 2. Restore 恢复已删除记录，唯一字段冲突时返回 409
+func (c *TrashController) Restore(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	item, err := c.service.Restore(r.Context(), r.PathValue("resource"), id, requestActor(r))
+	if err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, item)
+}
+
+// 3. Purge 彻底删除回收站中的记录（未删除的记录返回 404）
+func (c *TrashController) Purge(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if err := c.service.Purge(r.Context(), r.PathValue("resource"), id, requestActor(r)); err != nil {
+		handleServiceError(w, err)
+		return
+	}
+	respondData(w, http.StatusOK, map[string]bool{"purged": true})
+}
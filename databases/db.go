@@ -75,11 +75,41 @@ func Init() (*gorm.DB, error) {
 			&models.Building{}, // 必须在 OrangePi 前面，因为 OrangePi 有外键关联
 			&models.OrangePi{},
 			&models.NVR{}, // NVR 依赖 Building
+			&models.DeviceRegistration{},
+			&models.PortMigration{},
+			&models.Job{},
+			&models.JobTarget{},
+			&models.DeviceDrift{},
+			&models.AuditLog{},
+			&models.ProvisioningTemplate{},
+			&models.ConfigSnapshot{},
+			&models.RolloutCampaign{},
+			&models.RolloutDevice{},
+			&models.StreamSample{},
+			&models.MediaMTXSyncState{},
+			&models.DeviceLifecycleEvent{},
+			&models.BindingEvent{},
+			&models.BuildingAlias{},
+			&models.Site{},
+			&models.SiteGrant{},
+			&models.ISmartSyncRun{},
 		); err != nil {
 			initErr = err
 			return
 		}
 
+		// 旧版本的 is_active 字段迁移为生命周期状态
+		if err := migrateIsActive(conn); err != nil {
+			initErr = fmt.Errorf("failed to migrate orangepis.is_active: %w", err)
+			return
+		}
+
+		// 旧版本按 ismart_id 关联建筑，迁移为 building_id
+		if err := migrateOrangePiISmartID(conn); err != nil {
+			initErr = fmt.Errorf("failed to migrate orangepis.ismart_id: %w", err)
+			return
+		}
+
 		// 初始化默认数据
 		if err := initDefaultData(conn); err != nil {
 			log.Printf("Warning: failed to initialize default data: %v", err)
@@ -113,6 +143,86 @@ func getenvDefault(key, defVal string) string {
 	return defVal
 }
 
+// migrateIsActive 将 is_active 迁移为 lifecycle_state 后删除旧字段
+// 新字段默认 deployed，停用(is_active=false)的设备视为库存中(in_stock)；每台设备记录一条初始生命周期事件
+func migrateIsActive(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasColumn(&models.OrangePi{}, "is_active") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.OrangePi{}).
+			Where("is_active = ?", false).
+			Updates(map[string]interface{}{
+				"lifecycle_state":  models.LifecycleInStock,
+				"lifecycle_reason": "migrated from is_active=false",
+			}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.OrangePi{}).
+			Where("is_active = ?", true).
+			Update("lifecycle_reason", "migrated from is_active=true").Error; err != nil {
+			return err
+		}
+
+		// 只为还没有生命周期事件的设备补记，迁移中断后重新执行不会重复
+		var devices []models.OrangePi
+		if err := tx.Unscoped().Select("id", "lifecycle_state", "lifecycle_reason").
+			Where("NOT EXISTS (SELECT 1 FROM device_lifecycle_events WHERE device_lifecycle_events.orangepi_id = orangepis.id)").
+			Find(&devices).Error; err != nil {
+			return err
+		}
+		events := make([]models.DeviceLifecycleEvent, 0, len(devices))
+		for _, device := range devices {
+			events = append(events, models.DeviceLifecycleEvent{
+				OrangePiID: device.ID,
+				ToState:    device.LifecycleState,
+				Reason:     device.LifecycleReason,
+				Actor:      "system",
+			})
+		}
+		if len(events) > 0 {
+			if err := tx.CreateInBatches(&events, 100).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Migrator().DropColumn(&models.OrangePi{}, "is_active")
+	})
+}
+
+// migrateOrangePiISmartID 按 ismart_id 解析出 building_id 后删除旧字段
+// 找不到对应建筑的设备记录警告并视为未绑定
+func migrateOrangePiISmartID(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasColumn(&models.OrangePi{}, "ismart_id") {
+		return nil
+	}
+	if err := db.Exec(`UPDATE orangepis SET building_id = (
+		SELECT buildings.id FROM buildings WHERE buildings.ismart_id = orangepis.ismart_id
+	) WHERE building_id IS NULL AND ismart_id <> ''`).Error; err != nil {
+		return err
+	}
+
+	var orphans []struct {
+		ID       int64
+		ISmartID string `gorm:"column:ismart_id"`
+	}
+	if err := db.Raw("SELECT id, ismart_id FROM orangepis WHERE building_id IS NULL AND ismart_id <> ''").
+		Scan(&orphans).Error; err != nil {
+		return err
+	}
+	for _, orphan := range orphans {
+		log.Printf("Warning: orangepi %d references unknown ismart_id %q, left unbound", orphan.ID, orphan.ISmartID)
+	}
+
+	if migrator.HasIndex(&models.OrangePi{}, "idx_orangepis_ismart_id") {
+		if err := migrator.DropIndex(&models.OrangePi{}, "idx_orangepis_ismart_id"); err != nil {
+			return err
+		}
+	}
+	return migrator.DropColumn(&models.OrangePi{}, "ismart_id")
+}
+
 // initDefaultData 初始化默认数据（如默认管理员账户）
 func initDefaultData(db *gorm.DB) error {
 	// 检查是否已有管理员账户
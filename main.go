@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
@@ -30,6 +31,11 @@ func main() {
 		log.Fatalf("failed to build container: %v", err)
 	}
 
+	// 后台任务（异步任务调度器等）
+	if err := container.StartBackground(context.Background()); err != nil {
+		log.Fatalf("failed to start background workers: %v", err)
+	}
+
 	mux := http.NewServeMux()
 
 	// Health check
@@ -6,24 +6,30 @@ import (
 	"net/http"
 	"strings"
 
+	"icctv-http-service/models"
 	"icctv-http-service/services"
 )
 
 type contextKey string
 
-const adminClaimsKey contextKey = "adminClaims"
+const (
+	adminClaimsKey  contextKey = "adminClaims"
+	videoPayloadKey contextKey = "videoTokenPayload"
+	siteScopeKey    contextKey = "siteScope"
+)
 
 // AuthMiddleware JWT 鉴权
 type AuthMiddleware struct {
 	authService *services.AuthService
+	siteService *services.SiteService
 }
 
 // NewAuthMiddleware 构造函数
-func NewAuthMiddleware(authService *services.AuthService) *AuthMiddleware {
-	return &AuthMiddleware{authService: authService}
+func NewAuthMiddleware(authService *services.AuthService, siteService *services.SiteService) *AuthMiddleware {
+	return &AuthMiddleware{authService: authService, siteService: siteService}
 }
 
-// RequireAdmin 鉴权
+// RequireAdmin 鉴权（有站点授权的受限管理员返回 403）
 func (m *AuthMiddleware) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		token := extractBearerToken(r.Header.Get("Authorization"))
@@ -37,6 +43,9 @@ func (m *AuthMiddleware) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
 			respondUnauthorized(w, err.Error())
 			return
 		}
+		if !m.allowUnscoped(w, r, claims) {
+			return
+		}
 
 		ctx := r.Context()
 		ctx = contextWithClaims(ctx, claims)
@@ -44,6 +53,81 @@ func (m *AuthMiddleware) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// RequireSiteAdmin 管理员鉴权，受限管理员也可访问：按 view 权限计算的授权范围写入 context，由接口按范围过滤数据
+func (m *AuthMiddleware) RequireSiteAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := extractBearerToken(r.Header.Get("Authorization"))
+		if token == "" {
+			respondUnauthorized(w, "missing bearer token")
+			return
+		}
+
+		claims, err := m.authService.ValidateToken(token)
+		if err != nil {
+			respondUnauthorized(w, err.Error())
+			return
+		}
+
+		ctx := contextWithClaims(r.Context(), claims)
+		if m.siteService != nil {
+			scope, err := m.siteService.Scope(ctx, claims.Username, models.SitePermissionView)
+			if err != nil {
+				respondJSONError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			ctx = context.WithValue(ctx, siteScopeKey, scope)
+		}
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// RequireAdminOrVideoToken 管理员 JWT 或视频 Token（?token= 或 Bearer）均可访问
+// 使用视频 Token 时将 Payload 写入 context，由业务层校验楼栋与频道范围
+func (m *AuthMiddleware) RequireAdminOrVideoToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := extractBearerToken(r.Header.Get("Authorization"))
+		if token != "" {
+			if claims, err := m.authService.ValidateToken(token); err == nil {
+				if m.allowUnscoped(w, r, claims) {
+					next(w, r.WithContext(contextWithClaims(r.Context(), claims)))
+				}
+				return
+			}
+		}
+		if val := r.URL.Query().Get("token"); val != "" {
+			token = val
+		}
+		if token == "" {
+			respondUnauthorized(w, "missing token")
+			return
+		}
+
+		payload, err := m.authService.ValidateVideoToken(token)
+		if err != nil {
+			respondUnauthorized(w, err.Error())
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), videoPayloadKey, payload)))
+	}
+}
+
+// allowUnscoped 受限管理员只能访问 RequireSiteAdmin 保护的接口，其他管理接口返回 403
+func (m *AuthMiddleware) allowUnscoped(w http.ResponseWriter, r *http.Request, claims *services.AdminClaims) bool {
+	if m.siteService == nil {
+		return true
+	}
+	scoped, err := m.siteService.IsScoped(r.Context(), claims.Username)
+	if err != nil {
+		respondJSONError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if scoped {
+		respondJSONError(w, http.StatusForbidden, "site-scoped admin cannot access this endpoint")
+		return false
+	}
+	return true
+}
+
 func extractBearerToken(header string) string {
 	if header == "" {
 		return ""
@@ -56,8 +140,12 @@ func extractBearerToken(header string) string {
 }
 
 func respondUnauthorized(w http.ResponseWriter, message string) {
+	respondJSONError(w, http.StatusUnauthorized, message)
+}
+
+func respondJSONError(w http.ResponseWriter, status int, message string) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusUnauthorized)
+	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": false,
 		"error":   message,
@@ -75,3 +163,19 @@ func AdminClaimsFromContext(ctx context.Context) *services.AdminClaims {
 	}
 	return nil
 }
+
+// VideoTokenFromContext 读取视频 Token Payload（管理员请求返回 nil）
+func VideoTokenFromContext(ctx context.Context) *services.VideoTokenPayload {
+	if val, ok := ctx.Value(videoPayloadKey).(*services.VideoTokenPayload); ok {
+		return val
+	}
+	return nil
+}
+
+// SiteScopeFromContext 读取受限管理员的授权范围（不受限的管理员返回 nil）
+func SiteScopeFromContext(ctx context.Context) *services.SiteScope {
+	if val, ok := ctx.Value(siteScopeKey).(*services.SiteScope); ok {
+		return val
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package models
+
+// AuditLog 管理操作审计日志
+type AuditLog struct {
+	ModelFields
+
+	Actor      string                 `gorm:"type:varchar(100);index" json:"actor"`           // 操作管理员
+	ClientIP   string                 `gorm:"type:varchar(64)" json:"client_ip"`              // 请求来源IP
+	Action     string                 `gorm:"type:varchar(100);not null;index" json:"action"` // 操作，如 service.restart
+	TargetType string                 `gorm:"type:varchar(50);index" json:"target_type"`      // 操作对象类型，如 orangepi
+	TargetID   int64                  `gorm:"index" json:"target_id"`                         // 操作对象ID
+	Detail     map[string]interface{} `gorm:"type:json;serializer:json" json:"detail"`        // 操作参数与结果(JSON存储)
+	Success    bool                   `json:"success"`                                        // 是否成功
+	Error      string                 `gorm:"type:text" json:"error"`                         // 失败原因
+}
+
+// TableName 指定表名
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
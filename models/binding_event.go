@@ -0,0 +1,26 @@
+package models
+
+// 绑定事件的设备类型
+const (
+	BindingDeviceOrangePi = "orangepi"
+	BindingDeviceNVR      = "nvr"
+)
+
+// BindingEvent 设备与建筑绑定关系的变更记录（建筑ID为 0 表示未绑定）
+type BindingEvent struct {
+	ModelFields
+
+	DeviceType     string `gorm:"type:varchar(20);not null;index:idx_binding_events_device,priority:1" json:"device_type"` // 设备类型 orangepi / nvr
+	DeviceID       int64  `gorm:"not null;index:idx_binding_events_device,priority:2" json:"device_id"`                    // 设备ID
+	FromBuildingID int64  `gorm:"index" json:"from_building_id"`                                                           // 变更前建筑ID
+	ToBuildingID   int64  `gorm:"index" json:"to_building_id"`                                                             // 变更后建筑ID
+	FromISmartID   string `gorm:"type:varchar(100);column:from_ismart_id" json:"from_ismartid"`                            // 变更前建筑 ismartId（记录时的值）
+	ToISmartID     string `gorm:"type:varchar(100);column:to_ismart_id" json:"to_ismartid"`                                // 变更后建筑 ismartId（记录时的值）
+	Reason         string `gorm:"type:varchar(255)" json:"reason"`                                                         // 变更原因
+	Actor          string `gorm:"type:varchar(100)" json:"actor"`                                                          // 操作管理员
+}
+
+// TableName 指定表名
+func (BindingEvent) TableName() string {
+	return "binding_events"
+}
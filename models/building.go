@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // Building 建筑信息模型
 type Building struct {
 	ModelFields
@@ -7,8 +9,11 @@ type Building struct {
 	ISmartID string `gorm:"type:varchar(100);not null;uniqueIndex;column:ismart_id" json:"ismartid"` // ismart 系统ID，唯一标识
 	Name     string `gorm:"type:varchar(255);not null" json:"name"`                                  // 楼栋名称
 	Remark   string `gorm:"type:text" json:"remark"`                                                 // 备注信息
+	SiteID   *int64 `gorm:"index" json:"site_id"`                                                    // 所属站点ID（site 类型节点，为空表示未归属）
+
+	ISmartMissingAt *time.Time `gorm:"index;column:ismart_missing_at" json:"ismart_missing_at"` // iSmart 同步发现上游已不存在该楼栋的时间（为空表示正常）
 
 	// 关联关系
-	OrangePis []OrangePi `gorm:"foreignKey:ISmartID;references:ISmartID" json:"orangepis,omitempty"`              // 关联的OrangePi设备列表
-	NVRs      []NVR      `gorm:"foreignKey:BuildingISmartID;references:ISmartID" json:"nvrs,omitempty"`          // 关联的NVR设备列表
+	OrangePis []OrangePi `gorm:"foreignKey:BuildingID;references:ID" json:"orangepis,omitempty"` // 关联的OrangePi设备列表
+	NVRs      []NVR      `gorm:"foreignKey:BuildingID;references:ID" json:"nvrs,omitempty"`      // 关联的NVR设备列表
 }
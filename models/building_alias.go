@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// BuildingAlias 建筑 ismartId 变更前的旧值，宽限期内仍可按旧值签发视频 Token、查询直播与录像
+type BuildingAlias struct {
+	ModelFields
+
+	BuildingID int64     `gorm:"not null;index" json:"building_id"`                                 // 建筑ID
+	ISmartID   string    `gorm:"type:varchar(100);not null;index;column:ismart_id" json:"ismartid"` // 旧的 ismartId
+	ExpiresAt  time.Time `gorm:"index" json:"expires_at"`                                           // 宽限期截止时间
+	Actor      string    `gorm:"type:varchar(100)" json:"actor"`                                    // 操作管理员
+}
+
+// TableName 指定表名
+func (BuildingAlias) TableName() string {
+	return "building_aliases"
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// 配置快照触发方式
+const (
+	ConfigSnapshotTriggerScheduled = "scheduled" // 定时采集
+	ConfigSnapshotTriggerManual    = "manual"    // 管理员手动采集
+	ConfigSnapshotTriggerJob       = "job"       // 异步任务批量采集
+)
+
+// ConfigSnapshot OrangePi 上配置文件的一个版本（内容不变时只更新 LastSeenAt）
+type ConfigSnapshot struct {
+	ModelFields
+
+	OrangePiID int64     `gorm:"not null;index:idx_config_snapshot_file;column:orangepi_id" json:"orangepi_id"` // 设备ID
+	FileName   string    `gorm:"type:varchar(100);not null;index:idx_config_snapshot_file" json:"file_name"`    // 文件名，如 frpc.toml
+	Hash       string    `gorm:"type:char(64);not null" json:"hash"`                                            // 内容 SHA-256
+	Size       int       `json:"size"`                                                                          // 内容字节数
+	Content    string    `gorm:"type:mediumtext" json:"content,omitempty"`                                      // 文件内容（列表中不返回）
+	Trigger    string    `gorm:"type:varchar(20)" json:"trigger"`                                               // 首次采集方式
+	CapturedBy string    `gorm:"type:varchar(100)" json:"captured_by"`                                          // 首次采集的管理员
+	LastSeenAt time.Time `json:"last_seen_at"`                                                                  // 最近一次采集到相同内容的时间
+}
+
+// TableName 指定表名
+func (ConfigSnapshot) TableName() string {
+	return "config_snapshots"
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// DriftField 单个字段的期望值与实际值
+type DriftField struct {
+	Field   string `json:"field"`   // 字段名
+	Desired string `json:"desired"` // 数据库中的期望值
+	Actual  string `json:"actual"`  // 设备上报的实际值
+}
+
+// DeviceDrift OrangePi 期望状态(数据库)与实际状态(设备上报)的最近一次对账结果
+type DeviceDrift struct {
+	ModelFields
+
+	OrangePiID    int64        `gorm:"not null;uniqueIndex;column:orangepi_id" json:"orangepi_id"` // 设备ID
+	CheckedAt     time.Time    `json:"checked_at"`                                                 // 检查时间
+	Reachable     bool         `json:"reachable"`                                                  // 设备是否可达
+	Error         string       `gorm:"type:text" json:"error"`                                     // 检查失败原因
+	Drifted       bool         `gorm:"index" json:"drifted"`                                       // 是否存在偏差
+	Fields        []DriftField `gorm:"type:json;serializer:json" json:"fields"`                    // 偏差字段(JSON存储)
+	LastFixAt     *time.Time   `json:"last_fix_at"`                                                // 最近一次修复时间
+	LastFixResult string       `gorm:"type:text" json:"last_fix_result"`                           // 最近一次修复结果
+}
+
+// TableName 指定表名
+func (DeviceDrift) TableName() string {
+	return "device_drifts"
+}
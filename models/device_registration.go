@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// 设备注册申请状态
+const (
+	RegistrationStatusPending  = "pending"  // 待审批
+	RegistrationStatusApproved = "approved" // 已通过
+	RegistrationStatusRejected = "rejected" // 已拒绝
+	RegistrationStatusExpired  = "expired"  // 已过期
+)
+
+// DeviceRegistration OrangePi 自注册申请模型
+type DeviceRegistration struct {
+	ModelFields
+
+	DeviceID                   string     `gorm:"type:varchar(100);not null;index" json:"device_id"`                // 硬件指纹设备ID
+	Name                       string     `gorm:"type:varchar(255)" json:"name"`                                    // 设备上报的名称
+	ICCTVAuthServiceRemotePort int        `gorm:"not null" json:"icctv_auth_service_remote_port"`                   // 上报的认证服务远程端口
+	SSHRemotePort              int        `gorm:"not null" json:"ssh_remote_port"`                                  // 上报的 SSH 远程端口
+	AgentVersion               string     `gorm:"type:varchar(50)" json:"agent_version"`                            // 认证服务版本
+	MediaMTXVersion            string     `gorm:"type:varchar(50);column:mediamtx_version" json:"mediamtx_version"` // MediaMTX 版本
+	Status                     string     `gorm:"type:varchar(20);not null;default:pending;index" json:"status"`    // 审批状态
+	Reason                     string     `gorm:"type:varchar(255)" json:"reason"`                                  // 拒绝/过期原因
+	OrangePiID                 *int64     `gorm:"index;column:orangepi_id" json:"orangepi_id"`                      // 审批通过后创建的设备ID
+	ReviewedBy                 string     `gorm:"type:varchar(100)" json:"reviewed_by"`                             // 审批管理员
+	ReviewedAt                 *time.Time `json:"reviewed_at"`                                                      // 审批时间
+}
+
+// TableName 指定表名
+func (DeviceRegistration) TableName() string {
+	return "device_registrations"
+}
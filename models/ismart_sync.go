@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// iSmart 同步变更类型
+const (
+	ISmartChangeCreate     = "create"     // 上游新增的楼栋，本地创建建筑
+	ISmartChangeUpdate     = "update"     // 上游楼栋名称变化，本地更新
+	ISmartChangeMissing    = "missing"    // 本地建筑在上游已不存在，标记而不删除
+	ISmartChangeReappeared = "reappeared" // 之前标记为缺失的建筑重新出现在上游
+	ISmartChangeConflict   = "conflict"   // 无法同步（ismartId 被回收站中的建筑占用等），需人工处理
+)
+
+// iSmart 同步触发方式
+const (
+	ISmartSyncTriggerSchedule = "schedule" // 定时同步
+	ISmartSyncTriggerManual   = "manual"   // 管理员手动触发
+)
+
+// ISmartSyncChange 一次同步中单个楼栋的变更
+type ISmartSyncChange struct {
+	Action     string `json:"action"`                // 变更类型
+	ISmartID   string `json:"ismartid"`              // ismart 系统ID
+	BuildingID int64  `json:"building_id,omitempty"` // 本地建筑ID（新建且为 dry-run 时为空）
+	Name       string `json:"name"`                  // 同步后的名称
+	OldName    string `json:"old_name,omitempty"`    // 更新前的名称
+	Message    string `json:"message,omitempty"`     // 冲突原因
+}
+
+// ISmartSyncRun iSmart 楼栋同步记录
+type ISmartSyncRun struct {
+	ModelFields
+
+	Trigger    string             `gorm:"type:varchar(20);not null" json:"trigger"` // 触发方式：schedule / manual
+	Actor      string             `gorm:"type:varchar(100)" json:"actor"`           // 触发的管理员（定时同步为 system）
+	DryRun     bool               `gorm:"default:false" json:"dry_run"`             // 只计算差异，不修改建筑
+	StartedAt  time.Time          `json:"started_at"`                               // 开始时间
+	FinishedAt *time.Time         `json:"finished_at"`                              // 结束时间
+	Success    bool               `gorm:"index" json:"success"`                     // 是否成功
+	Error      string             `gorm:"type:text" json:"error"`                   // 失败原因
+	Upstream   int                `json:"upstream"`                                 // 上游楼栋数量
+	Unchanged  int                `json:"unchanged"`                                // 无变化的建筑数量
+	Changes    []ISmartSyncChange `gorm:"type:json;serializer:json" json:"changes"` // 差异(JSON存储)
+}
+
+// TableName 指定表名
+func (ISmartSyncRun) TableName() string {
+	return "ismart_sync_runs"
+}
@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// 任务及任务目标状态
+const (
+	JobStatusPending         = "pending"          // 等待执行
+	JobStatusRunning         = "running"          // 执行中
+	JobStatusSucceeded       = "succeeded"        // 全部成功
+	JobStatusPartiallyFailed = "partially_failed" // 部分失败
+	JobStatusFailed          = "failed"           // 全部失败
+	JobStatusCancelled       = "cancelled"        // 已取消
+)
+
+// 任务类型
+const (
+	JobTypeRemoteUpdatePorts = "remote_update_ports" // 远程更新 FRP 端口
+	JobTypeRemoteInfo        = "remote_info"         // 远程获取设备信息
+	JobTypeHealthCheck       = "health_check"        // 远程健康检查
+	JobTypePortMigration     = "port_migration"      // 端口迁移(带验证与回滚)
+	JobTypeServiceControl    = "service_control"     // 远程启停 docker 服务
+	JobTypeConfigSnapshot    = "config_snapshot"     // 采集设备配置文件
+	JobTypeRolloutUpdate     = "rollout_update"      // 升级活动中的单台设备升级
+)
+
+// Job 异步任务模型（对一批 OrangePi 执行同一种远程操作）
+type Job struct {
+	ModelFields
+
+	Type       string                 `gorm:"type:varchar(50);not null;index" json:"type"`   // 任务类型
+	Status     string                 `gorm:"type:varchar(20);not null;index" json:"status"` // 任务状态
+	Params     map[string]interface{} `gorm:"type:json;serializer:json" json:"params"`       // 任务参数(JSON存储)
+	MaxRetries int                    `gorm:"not null;default:0" json:"max_retries"`         // 单个目标最大重试次数
+	Total      int                    `gorm:"not null;default:0" json:"total"`               // 目标总数
+	Succeeded  int                    `gorm:"not null;default:0" json:"succeeded"`           // 成功数
+	Failed     int                    `gorm:"not null;default:0" json:"failed"`              // 失败数
+	Cancelled  int                    `gorm:"not null;default:0" json:"cancelled"`           // 取消数
+	CreatedBy  string                 `gorm:"type:varchar(100)" json:"created_by"`           // 创建管理员
+	StartedAt  *time.Time             `json:"started_at"`                                    // 开始时间
+	FinishedAt *time.Time             `json:"finished_at"`                                   // 结束时间
+
+	// 关联关系
+	Targets []JobTarget `gorm:"foreignKey:JobID" json:"targets,omitempty"` // 任务目标
+}
+
+// TableName 指定表名
+func (Job) TableName() string {
+	return "jobs"
+}
+
+// JobTarget 任务中单个 OrangePi 的执行记录
+type JobTarget struct {
+	ModelFields
+
+	JobID      int64                  `gorm:"not null;index" json:"job_id"`                         // 所属任务
+	OrangePiID int64                  `gorm:"not null;index;column:orangepi_id" json:"orangepi_id"` // 目标设备
+	Status     string                 `gorm:"type:varchar(20);not null;index" json:"status"`        // 执行状态
+	Params     map[string]interface{} `gorm:"type:json;serializer:json" json:"params,omitempty"`    // 目标级参数(覆盖任务参数)
+	Attempts   int                    `gorm:"not null;default:0" json:"attempts"`                   // 已尝试次数
+	NextRunAt  *time.Time             `gorm:"index" json:"next_run_at"`                             // 下次执行时间(重试退避)
+	Result     interface{}            `gorm:"type:json;serializer:json" json:"result"`              // 执行结果(JSON存储)
+	Error      string                 `gorm:"type:text" json:"error"`                               // 最近一次错误
+	StartedAt  *time.Time             `json:"started_at"`                                           // 最近一次开始时间
+	FinishedAt *time.Time             `json:"finished_at"`                                          // 结束时间
+}
+
+// TableName 指定表名
+func (JobTarget) TableName() string {
+	return "job_targets"
+}
@@ -0,0 +1,56 @@
+package models
+
+import "slices"
+
+// OrangePi 生命周期状态
+const (
+	LifecycleInStock      = "in_stock"     // 库存中，未分配楼栋
+	LifecycleProvisioning = "provisioning" // 已下发部署包，等待设备上线
+	LifecycleDeployed     = "deployed"     // 已部署，正常提供视频服务
+	LifecycleMaintenance  = "maintenance"  // 维护中，暂停巡检与告警
+	LifecycleFaulty       = "faulty"       // 故障，等待维修或更换
+	LifecycleRetired      = "retired"      // 已报废，不再接受心跳与操作
+)
+
+// LifecycleTransitions 允许的生命周期状态转换
+var LifecycleTransitions = map[string][]string{
+	LifecycleInStock:      {LifecycleProvisioning, LifecycleRetired},
+	LifecycleProvisioning: {LifecycleDeployed, LifecycleInStock, LifecycleFaulty},
+	LifecycleDeployed:     {LifecycleMaintenance, LifecycleFaulty, LifecycleInStock},
+	LifecycleMaintenance:  {LifecycleDeployed, LifecycleFaulty, LifecycleInStock, LifecycleRetired},
+	LifecycleFaulty:       {LifecycleMaintenance, LifecycleInStock, LifecycleRetired},
+	LifecycleRetired:      {},
+}
+
+// LifecycleServingStates 对外提供服务的状态：签发视频 Token、直播/录像选择设备、健康巡检与告警、仪表板在用数量
+var LifecycleServingStates = []string{LifecycleDeployed}
+
+// LifecycleManagedStates 接受远程运维的状态：批量任务、升级活动、MediaMTX 路径同步
+var LifecycleManagedStates = []string{LifecycleDeployed, LifecycleMaintenance}
+
+// IsLifecycleState 是否为已定义的生命周期状态
+func IsLifecycleState(state string) bool {
+	_, ok := LifecycleTransitions[state]
+	return ok
+}
+
+// CanTransitionLifecycle 是否允许从 from 转换到 to
+func CanTransitionLifecycle(from, to string) bool {
+	return slices.Contains(LifecycleTransitions[from], to)
+}
+
+// DeviceLifecycleEvent OrangePi 生命周期状态变更记录
+type DeviceLifecycleEvent struct {
+	ModelFields
+
+	OrangePiID int64  `gorm:"not null;index;column:orangepi_id" json:"orangepi_id"` // 设备ID
+	FromState  string `gorm:"type:varchar(20)" json:"from_state"`                   // 变更前状态
+	ToState    string `gorm:"type:varchar(20);not null" json:"to_state"`            // 变更后状态
+	Reason     string `gorm:"type:varchar(255)" json:"reason"`                      // 变更原因
+	Actor      string `gorm:"type:varchar(100)" json:"actor"`                       // 操作管理员(设备自动变更为 device)
+}
+
+// TableName 指定表名
+func (DeviceLifecycleEvent) TableName() string {
+	return "device_lifecycle_events"
+}
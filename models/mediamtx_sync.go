@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// PathSyncResult 单个 MediaMTX 路径的下发结果
+type PathSyncResult struct {
+	Name    string `json:"name"`            // 路径名(channelN)
+	Success bool   `json:"success"`         // 是否成功
+	Error   string `json:"error,omitempty"` // 失败原因
+}
+
+// MediaMTXSyncState 每台 OrangePi 最近一次 MediaMTX 路径同步结果
+type MediaMTXSyncState struct {
+	ModelFields
+
+	OrangePiID int64            `gorm:"not null;uniqueIndex;column:orangepi_id" json:"orangepi_id"` // 设备ID
+	BuildingID int64            `gorm:"index" json:"building_id"`                                   // 下发时所属楼栋
+	Hash       string           `gorm:"type:char(64)" json:"hash"`                                  // 下发的路径配置哈希
+	Paths      int              `json:"paths"`                                                      // 下发的路径数量
+	Success    bool             `gorm:"default:false" json:"success"`                               // 是否全部成功
+	Results    []PathSyncResult `gorm:"type:json;serializer:json" json:"results"`                   // 每个路径的结果
+	Error      string           `gorm:"type:text" json:"error,omitempty"`                           // 调用失败原因
+	SyncedAt   time.Time        `json:"synced_at"`                                                  // 同步时间
+}
+
+// TableName 指定表名
+func (MediaMTXSyncState) TableName() string {
+	return "mediamtx_sync_states"
+}
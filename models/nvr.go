@@ -13,9 +13,11 @@ type User struct {
 }
 
 // ChannelURL RTSP频道地址
+// SiteID 为摄像头所在的楼层/区域（所属建筑的 floor/zone 节点），NVR 迁移到其他建筑后原有位置不再生效
 type ChannelURL struct {
-	Channel int    `json:"channel"` // 通道号
-	URL     string `json:"url"`     // RTSP 地址
+	Channel int    `json:"channel"`           // 通道号
+	URL     string `json:"url"`               // RTSP 地址
+	SiteID  *int64 `json:"site_id,omitempty"` // 摄像头位置（楼层/区域节点ID，可选）
 }
 
 // NVR 网络硬盘录像机模型
@@ -1,20 +1,49 @@
 package models
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
 // OrangePi OrangePi设备模型
 type OrangePi struct {
 	ModelFields
 
-	ISmartID                   string `gorm:"type:varchar(100);not null;column:ismart_id;index" json:"ismartid"` // 关联楼栋 ismartId
-	Name                       string `gorm:"type:varchar(255);not null" json:"name"`                            // Orangepi 名称
-	ICCTVAuthServiceRemotePort int    `gorm:"not null" json:"icctv_auth_service_remote_port"`                    // 远程认证服务端口
-	SSHRemotePort              int    `gorm:"not null" json:"ssh_remote_port"`                                   // SSH 远程端口
-	IsActive                   bool   `gorm:"default:true" json:"is_active"`                                     // 是否在用
+	BuildingID                 *int64     `gorm:"index" json:"building_id"`                                                // 关联建筑ID(为空表示未绑定)
+	ISmartID                   string     `gorm:"-" json:"ismartid,omitempty"`                                             // 关联建筑 ismartId(只读，由预加载的建筑派生)
+	Name                       string     `gorm:"type:varchar(255);not null" json:"name"`                                  // Orangepi 名称
+	ICCTVAuthServiceRemotePort int        `gorm:"not null" json:"icctv_auth_service_remote_port"`                          // 远程认证服务端口
+	SSHRemotePort              int        `gorm:"not null" json:"ssh_remote_port"`                                         // SSH 远程端口
+	LifecycleState             string     `gorm:"type:varchar(20);not null;default:deployed;index" json:"lifecycle_state"` // 生命周期状态
+	LifecycleReason            string     `gorm:"type:varchar(255)" json:"lifecycle_reason"`                               // 最近一次状态变更原因
+	LifecycleChangedAt         *time.Time `json:"lifecycle_changed_at"`                                                    // 最近一次状态变更时间
+	DeviceKey                  string     `gorm:"type:varchar(64)" json:"-"`                                               // 设备凭证，不返回给前端
+	DeviceID                   *string    `gorm:"type:varchar(100);uniqueIndex" json:"device_id"`                          // 硬件指纹设备ID(首次获取设备信息时写入)
+	ReportedDeviceID           string     `gorm:"type:varchar(100)" json:"reported_device_id,omitempty"`                   // 端口上实际应答的设备ID(不一致时记录)
+	DeviceIDMismatch           bool       `gorm:"default:false" json:"device_id_mismatch"`                                 // 设备ID不一致标记(FRP 端口可能被调换)
+	PortMigrationState         string     `gorm:"type:varchar(30)" json:"port_migration_state"`                            // 端口迁移状态(空表示正常)
+	ReconcileDisabled          bool       `gorm:"default:false" json:"reconcile_disabled"`                                 // 不参与期望状态对账(不检测也不修复)
+	AgentVersion               string     `gorm:"type:varchar(50);index" json:"agent_version"`                             // 认证服务(agent)版本
+	MediaMTXVersion            string     `gorm:"type:varchar(50);column:mediamtx_version;index" json:"mediamtx_version"`  // MediaMTX 版本
+	VersionReportedAt          *time.Time `json:"version_reported_at"`                                                     // 最近一次上报版本的时间
+	LastHeartbeatAt            *time.Time `json:"last_heartbeat_at"`                                                       // 最近一次心跳时间
+	ReplacesID                 *int64     `gorm:"index" json:"replaces_id"`                                                // 被本设备替换的旧设备ID
+	ReplacedByID               *int64     `gorm:"index" json:"replaced_by_id"`                                             // 替换本设备的新设备ID(已报废)
 
 	// 关联关系
-	Building *Building `gorm:"foreignKey:ISmartID;references:ISmartID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"building,omitempty"` // 关联建筑
+	Building *Building `gorm:"foreignKey:BuildingID;references:ID" json:"building,omitempty"` // 关联建筑
 }
 
 // TableName 指定表名
 func (OrangePi) TableName() string {
 	return "orangepis"
 }
+
+// AfterFind 预加载了建筑时回填只读的 ismartid，兼容改为 building_id 之前的输出
+func (o *OrangePi) AfterFind(tx *gorm.DB) error {
+	if o.Building != nil {
+		o.ISmartID = o.Building.ISmartID
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// OrangePi 端口迁移状态
+const (
+	PortMigrationStateMigrating = "port-migrating"        // 迁移进行中
+	PortMigrationStateFailed    = "port-migration-failed" // 迁移失败且未能回滚，需人工处理
+)
+
+// 端口迁移记录状态
+const (
+	PortMigrationStatusRunning    = "running"     // 执行中
+	PortMigrationStatusSucceeded  = "succeeded"   // 新端口验证通过并已写入数据库
+	PortMigrationStatusRolledBack = "rolled_back" // 新端口不可达，已回滚到旧端口
+	PortMigrationStatusFailed     = "failed"      // 失败（未下发或回滚失败）
+)
+
+// PortMigrationStep 端口迁移步骤记录
+type PortMigrationStep struct {
+	Name    string    `json:"name"`    // 步骤名称
+	Success bool      `json:"success"` // 是否成功
+	Message string    `json:"message"` // 结果说明
+	At      time.Time `json:"at"`      // 执行时间
+}
+
+// PortMigration OrangePi FRP 端口迁移记录
+type PortMigration struct {
+	ModelFields
+
+	OrangePiID  int64               `gorm:"not null;index;column:orangepi_id" json:"orangepi_id"` // 设备ID
+	OldAuthPort int                 `gorm:"not null" json:"old_auth_port"`                        // 迁移前认证端口
+	OldSSHPort  int                 `gorm:"not null" json:"old_ssh_port"`                         // 迁移前 SSH 端口
+	NewAuthPort int                 `gorm:"not null" json:"new_auth_port"`                        // 目标认证端口
+	NewSSHPort  int                 `gorm:"not null" json:"new_ssh_port"`                         // 目标 SSH 端口
+	Status      string              `gorm:"type:varchar(20);not null;index" json:"status"`        // 迁移状态
+	Steps       []PortMigrationStep `gorm:"type:json;serializer:json" json:"steps"`               // 步骤明细(JSON存储)
+	Operator    string              `gorm:"type:varchar(100)" json:"operator"`                    // 操作管理员
+	FinishedAt  *time.Time          `json:"finished_at"`                                          // 结束时间
+}
+
+// TableName 指定表名
+func (PortMigration) TableName() string {
+	return "port_migrations"
+}
@@ -0,0 +1,16 @@
+package models
+
+// ProvisioningTemplate OrangePi 部署包模板（text/template 语法，管理员可编辑）
+type ProvisioningTemplate struct {
+	ModelFields
+
+	Name      string `gorm:"type:varchar(100);not null;uniqueIndex" json:"name"` // 模板名称，如 frpc.toml
+	Path      string `gorm:"type:varchar(255);not null" json:"path"`             // 在部署包中的路径，如 config/frpc.toml
+	Content   string `gorm:"type:text;not null" json:"content"`                  // 模板内容
+	UpdatedBy string `gorm:"type:varchar(100)" json:"updated_by"`                // 最后修改的管理员
+}
+
+// TableName 指定表名
+func (ProvisioningTemplate) TableName() string {
+	return "provisioning_templates"
+}
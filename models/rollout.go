@@ -0,0 +1,86 @@
+package models
+
+import "time"
+
+// 升级组件
+const (
+	RolloutComponentAgent    = "agent"    // 认证服务(agent)
+	RolloutComponentMediaMTX = "mediamtx" // MediaMTX
+)
+
+// 升级活动状态
+const (
+	RolloutStatusRunning   = "running"   // 执行中
+	RolloutStatusPaused    = "paused"    // 已暂停(手动暂停或金丝雀失败)
+	RolloutStatusCompleted = "completed" // 已完成
+	RolloutStatusCancelled = "cancelled" // 已取消
+)
+
+// 升级活动阶段
+const (
+	RolloutPhaseCanary = "canary" // 金丝雀阶段，只升级部分设备
+	RolloutPhaseFull   = "full"   // 全量阶段
+)
+
+// 单台设备的升级状态
+const (
+	RolloutDevicePending   = "pending"   // 等待升级
+	RolloutDeviceUpdating  = "updating"  // 升级中
+	RolloutDeviceSucceeded = "succeeded" // 升级成功(已确认上报目标版本)
+	RolloutDeviceFailed    = "failed"    // 升级失败
+	RolloutDeviceSkipped   = "skipped"   // 已是目标版本或活动取消
+)
+
+// RolloutSelector 升级目标设备筛选条件（条件之间为"且"，为空表示不限制）
+type RolloutSelector struct {
+	OrangePiIDs  []int64  `json:"orangepi_ids,omitempty"`  // 指定设备
+	ISmartIDs    []string `json:"ismartids,omitempty"`     // 指定楼栋
+	FromVersions []string `json:"from_versions,omitempty"` // 当前版本
+}
+
+// RolloutCampaign 升级活动：按金丝雀比例分阶段把一批设备的组件升级到目标版本
+type RolloutCampaign struct {
+	ModelFields
+
+	Name          string          `gorm:"type:varchar(255)" json:"name"`                   // 活动名称
+	Component     string          `gorm:"type:varchar(20);not null" json:"component"`      // 升级组件
+	TargetVersion string          `gorm:"type:varchar(50);not null" json:"target_version"` // 目标版本
+	Selector      RolloutSelector `gorm:"type:json;serializer:json" json:"selector"`       // 设备筛选条件
+	CanaryPercent int             `gorm:"default:0" json:"canary_percent"`                 // 金丝雀比例(0-100)
+	Phase         string          `gorm:"type:varchar(20);not null" json:"phase"`          // 当前阶段
+	Status        string          `gorm:"type:varchar(20);not null;index" json:"status"`   // 活动状态
+	PauseReason   string          `gorm:"type:varchar(255)" json:"pause_reason,omitempty"` // 暂停原因
+	JobID         *int64          `json:"job_id"`                                          // 当前阶段的异步任务
+	Total         int             `json:"total"`                                           // 设备总数
+	Succeeded     int             `json:"succeeded"`                                       // 成功数
+	Failed        int             `json:"failed"`                                          // 失败数
+	Skipped       int             `json:"skipped"`                                         // 跳过数
+	CreatedBy     string          `gorm:"type:varchar(100)" json:"created_by"`             // 创建人
+	FinishedAt    *time.Time      `json:"finished_at"`                                     // 结束时间
+	Devices       []RolloutDevice `gorm:"foreignKey:CampaignID" json:"devices,omitempty"`  // 设备升级明细
+}
+
+// TableName 指定表名
+func (RolloutCampaign) TableName() string {
+	return "rollout_campaigns"
+}
+
+// RolloutDevice 升级活动中单台设备的升级结果
+type RolloutDevice struct {
+	ModelFields
+
+	CampaignID  int64      `gorm:"not null;index" json:"campaign_id"`                    // 所属活动
+	OrangePiID  int64      `gorm:"not null;index;column:orangepi_id" json:"orangepi_id"` // 设备ID
+	Canary      bool       `gorm:"default:false" json:"canary"`                          // 是否为金丝雀设备
+	Status      string     `gorm:"type:varchar(20);not null;index" json:"status"`        // 升级状态
+	FromVersion string     `gorm:"type:varchar(50)" json:"from_version"`                 // 升级前版本
+	Version     string     `gorm:"type:varchar(50)" json:"version"`                      // 升级后确认的版本
+	Error       string     `gorm:"type:text" json:"error,omitempty"`                     // 失败原因
+	StartedAt   *time.Time `json:"started_at"`                                           // 开始时间
+	FinishedAt  *time.Time `json:"finished_at"`                                          // 结束时间
+}
+
+// TableName 指定表名
+func (RolloutDevice) TableName() string {
+	return "rollout_devices"
+}
@@ -0,0 +1,49 @@
+package models
+
+// 站点节点类型
+const (
+	SiteKindSite  = "site"  // 园区/小区等分组，可多级嵌套，建筑归属于此类节点
+	SiteKindFloor = "floor" // 建筑内的楼层
+	SiteKindZone  = "zone"  // 建筑或楼层内的区域，可多级嵌套
+)
+
+// 站点授权权限
+const (
+	SitePermissionView  = "view"  // 查看范围内的建筑、设备与 NVR
+	SitePermissionVideo = "video" // 在 view 基础上可签发范围内摄像头的视频 Token
+)
+
+// Site 站点层级节点
+// site 节点通过 parent_id 组成树，建筑通过 buildings.site_id 归属于 site 节点；
+// floor/zone 节点属于某个建筑（building_id），摄像头通道可以放置到 floor/zone 节点（ChannelURL.SiteID）
+type Site struct {
+	ModelFields
+
+	ParentID   *int64 `gorm:"index" json:"parent_id"`                      // 上级节点ID（为空表示顶层节点或直属建筑的楼层/区域）
+	BuildingID *int64 `gorm:"index" json:"building_id"`                    // 所属建筑ID（仅 floor/zone）
+	Kind       string `gorm:"type:varchar(20);not null;index" json:"kind"` // 节点类型：site / floor / zone
+	Name       string `gorm:"type:varchar(255);not null" json:"name"`      // 名称
+	Remark     string `gorm:"type:text" json:"remark"`                     // 备注信息
+}
+
+// TableName 指定表名
+func (Site) TableName() string {
+	return "sites"
+}
+
+// SiteGrant 管理员的站点授权：授予某个节点（site/floor/zone）或建筑及其下级的权限
+// 有授权记录的管理员为受限管理员，只能访问授权范围内的数据；没有任何授权记录的管理员不受限制
+type SiteGrant struct {
+	ModelFields
+
+	Username   string `gorm:"type:varchar(100);not null;index" json:"username"` // 被授权的管理员用户名
+	SiteID     *int64 `gorm:"index" json:"site_id,omitempty"`                   // 授权的站点节点（与 building_id 二选一）
+	BuildingID *int64 `gorm:"index" json:"building_id,omitempty"`               // 授权的建筑（与 site_id 二选一）
+	Permission string `gorm:"type:varchar(20);not null" json:"permission"`      // 权限：view / video
+	CreatedBy  string `gorm:"type:varchar(100)" json:"created_by"`              // 授权的管理员
+}
+
+// TableName 指定表名
+func (SiteGrant) TableName() string {
+	return "site_grants"
+}
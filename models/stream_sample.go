@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// 通道推流状态
+const (
+	StreamStatusUp      = "up"      // 路径就绪(摄像头在推流)
+	StreamStatusDown    = "down"    // 路径存在但未就绪
+	StreamStatusUnknown = "unknown" // 没有最近的采样(设备不可达或路径未配置)
+)
+
+// StreamSample OrangePi 上一个 MediaMTX 路径的状态采样
+type StreamSample struct {
+	ModelFields
+
+	OrangePiID    int64      `gorm:"not null;index:idx_stream_sample_path;column:orangepi_id" json:"orangepi_id"` // 设备ID
+	PathName      string     `gorm:"type:varchar(255);not null;index:idx_stream_sample_path" json:"path_name"`    // MediaMTX 路径名
+	SourceType    string     `gorm:"type:varchar(50)" json:"source_type"`                                         // 源类型(rtspSource 等)
+	Ready         bool       `gorm:"default:false" json:"ready"`                                                  // 是否就绪
+	ReadyTime     *time.Time `json:"ready_time"`                                                                  // 就绪时间
+	Readers       int        `json:"readers"`                                                                     // 观看/读取数
+	BytesReceived int64      `json:"bytes_received"`                                                              // 累计接收字节
+	BytesSent     int64      `json:"bytes_sent"`                                                                  // 累计发送字节
+	SampledAt     time.Time  `gorm:"not null;index:idx_stream_sample_path" json:"sampled_at"`                     // 采样时间
+}
+
+// TableName 指定表名
+func (StreamSample) TableName() string {
+	return "stream_samples"
+}
@@ -9,13 +9,32 @@ import (
 
 // ControllerSet 聚合所有控制器
 type ControllerSet struct {
-	Auth      *controllers.AuthController
-	Admin     *controllers.AdminController
-	OrangePi  *controllers.OrangePiController
-	Building  *controllers.BuildingController
-	Device    *controllers.DeviceController
-	PublicNet *controllers.PublicNetController
-	NVR       *controllers.NVRController
+	Auth           *controllers.AuthController
+	Admin          *controllers.AdminController
+	OrangePi       *controllers.OrangePiController
+	Building       *controllers.BuildingController
+	Device         *controllers.DeviceController
+	PublicNet      *controllers.PublicNetController
+	NVR            *controllers.NVRController
+	Registration   *controllers.RegistrationController
+	PortMigration  *controllers.PortMigrationController
+	Job            *controllers.JobController
+	Reconcile      *controllers.ReconcileController
+	ServiceControl *controllers.ServiceControlController
+	Audit          *controllers.AuditController
+	Provisioning   *controllers.ProvisioningController
+	ConfigSnapshot *controllers.ConfigSnapshotController
+	Rollout        *controllers.RolloutController
+	Stream         *controllers.StreamController
+	MediaMTXSync   *controllers.MediaMTXSyncController
+	Recording      *controllers.RecordingController
+	Live           *controllers.LiveController
+	Lifecycle      *controllers.LifecycleController
+	BindingHistory *controllers.BindingHistoryController
+	Trash          *controllers.TrashController
+	Site           *controllers.SiteController
+	Topology       *controllers.TopologyController
+	ISmartSync     *controllers.ISmartSyncController
 }
 
 // MiddlewareSet 聚合所有中间件
@@ -31,10 +50,31 @@ func SetupRoutes(mux *http.ServeMux, ctrl ControllerSet, mw MiddlewareSet) {
 		}
 		return handler
 	}
+	// 受限管理员（有站点授权）也可访问，数据按授权范围过滤
+	requireSiteAdmin := func(handler http.HandlerFunc) http.HandlerFunc {
+		if mw.Auth != nil {
+			return mw.Auth.RequireSiteAdmin(handler)
+		}
+		return handler
+	}
+	requireAdminOrVideoToken := func(handler http.HandlerFunc) http.HandlerFunc {
+		if mw.Auth != nil {
+			return mw.Auth.RequireAdminOrVideoToken(handler)
+		}
+		return handler
+	}
 
 	// Auth
-	mux.HandleFunc("POST /api/auth/public", ctrl.Auth.PublicToken) // 生成视频访问 Token
-	mux.HandleFunc("POST /api/auth/login", ctrl.Auth.Login)        // 管理员登录
+	mux.HandleFunc("POST /api/auth/public", ctrl.Auth.PublicToken)                       // 生成视频访问 Token
+	mux.HandleFunc("POST /api/auth/login", ctrl.Auth.Login)                              // 管理员登录
+	mux.HandleFunc("POST /api/auth/video-token", requireSiteAdmin(ctrl.Auth.VideoToken)) // 管理员按站点授权签发视频 Token
+
+	// 直播播放地址（无 token 时签发新的视频 Token，与 /api/auth/public 一致无需登录）
+	mux.HandleFunc("GET /api/live/{ismartid}/{channel}", ctrl.Live.Resolve)
+
+	// 录像查询与下载链接（管理员或视频 Token）
+	mux.HandleFunc("GET /api/recordings", requireAdminOrVideoToken(ctrl.Recording.Search))
+	mux.HandleFunc("GET /api/recordings/download-url", requireAdminOrVideoToken(ctrl.Recording.DownloadURL))
 
 	// Admin
 	mux.HandleFunc("GET /api/admin", requireAdmin(ctrl.Admin.List))
@@ -43,21 +83,89 @@ func SetupRoutes(mux *http.ServeMux, ctrl ControllerSet, mw MiddlewareSet) {
 	mux.HandleFunc("DELETE /api/admin", requireAdmin(ctrl.Admin.Delete))
 
 	// Device (OrangePi)
-	mux.HandleFunc("GET /api/device", requireAdmin(ctrl.OrangePi.List))
+	mux.HandleFunc("GET /api/device", requireSiteAdmin(ctrl.OrangePi.List))
 	mux.HandleFunc("POST /api/device", requireAdmin(ctrl.OrangePi.Create))
 	mux.HandleFunc("PUT /api/device", requireAdmin(ctrl.OrangePi.Update))
 	mux.HandleFunc("DELETE /api/device", requireAdmin(ctrl.OrangePi.Delete))
 
+	// Device 自注册与审批
+	mux.HandleFunc("POST /api/device/register", ctrl.Registration.Register) // OrangePi 提交注册申请(需 X-Enrollment-Key，未配置注册密钥时关闭)
+	mux.HandleFunc("POST /api/device/heartbeat", ctrl.OrangePi.Heartbeat)   // OrangePi 心跳(X-Device-Key 认证)
+	mux.HandleFunc("GET /api/device/registrations", requireAdmin(ctrl.Registration.List))
+	mux.HandleFunc("POST /api/device/registrations/approve", requireAdmin(ctrl.Registration.Approve))
+	mux.HandleFunc("POST /api/device/registrations/reject", requireAdmin(ctrl.Registration.Reject))
+	mux.HandleFunc("POST /api/device/registrations/expire", requireAdmin(ctrl.Registration.Expire))
+
+	// OrangePi 部署包（frpc.toml、.env、docker-compose.yml）
+	mux.HandleFunc("GET /api/device/{id}/provisioning-bundle", requireAdmin(ctrl.Provisioning.Bundle))
+	mux.HandleFunc("GET /api/provisioning/templates", requireAdmin(ctrl.Provisioning.ListTemplates))
+	mux.HandleFunc("PUT /api/provisioning/templates", requireAdmin(ctrl.Provisioning.UpdateTemplate))
+	mux.HandleFunc("POST /api/provisioning/templates/reset", requireAdmin(ctrl.Provisioning.ResetTemplate))
+
+	// OrangePi 配置快照（实际配置文件版本及与期望配置的差异）
+	mux.HandleFunc("POST /api/device/{id}/config/snapshots", requireAdmin(ctrl.ConfigSnapshot.Capture))
+	mux.HandleFunc("GET /api/device/{id}/config/snapshots", requireAdmin(ctrl.ConfigSnapshot.List))
+	mux.HandleFunc("GET /api/device/{id}/config/snapshots/{snapshot_id}", requireAdmin(ctrl.ConfigSnapshot.Get))
+	mux.HandleFunc("GET /api/device/{id}/config/diff", requireAdmin(ctrl.ConfigSnapshot.Diff))
+
 	// OrangePi 远程管理
 	mux.HandleFunc("POST /api/orangepi/remote/ports", requireAdmin(ctrl.OrangePi.RemoteUpdatePorts))
 	mux.HandleFunc("GET /api/orangepi/remote/info", requireAdmin(ctrl.OrangePi.RemoteGetInfo))
 	mux.HandleFunc("GET /api/orangepi/remote/health", requireAdmin(ctrl.OrangePi.RemoteHealthCheck))
+	mux.HandleFunc("POST /api/orangepi/remote/identity/accept", requireAdmin(ctrl.OrangePi.AcceptDeviceID))
+	mux.HandleFunc("GET /api/orangepi/ports/pool", requireAdmin(ctrl.OrangePi.PortPool))
+
+	// 设备生命周期
+	mux.HandleFunc("POST /api/orangepi/{id}/lifecycle", requireAdmin(ctrl.Lifecycle.Transition))
+	mux.HandleFunc("GET /api/orangepi/{id}/lifecycle", requireAdmin(ctrl.Lifecycle.History))
+	mux.HandleFunc("POST /api/orangepi/{id}/replace", requireAdmin(ctrl.Lifecycle.Replace))
+
+	// OrangePi MediaMTX 路径状态与推流采样
+	mux.HandleFunc("GET /api/orangepi/remote/paths", requireAdmin(ctrl.Stream.Paths))
+	mux.HandleFunc("GET /api/orangepi/streams/samples", requireAdmin(ctrl.Stream.Samples))
+
+	// OrangePi 版本与升级活动（金丝雀、暂停/继续）
+	mux.HandleFunc("GET /api/orangepi/versions", requireAdmin(ctrl.OrangePi.Versions))
+	mux.HandleFunc("POST /api/rollouts", requireAdmin(ctrl.Rollout.Create))
+	mux.HandleFunc("GET /api/rollouts", requireAdmin(ctrl.Rollout.List))
+	mux.HandleFunc("GET /api/rollouts/{id}", requireAdmin(ctrl.Rollout.Get))
+	mux.HandleFunc("POST /api/rollouts/{id}/pause", requireAdmin(ctrl.Rollout.Pause))
+	mux.HandleFunc("POST /api/rollouts/{id}/resume", requireAdmin(ctrl.Rollout.Resume))
+	mux.HandleFunc("POST /api/rollouts/{id}/cancel", requireAdmin(ctrl.Rollout.Cancel))
+
+	// OrangePi 端口迁移（验证新端口可达，失败回滚）
+	mux.HandleFunc("POST /api/orangepi/remote/ports/migrate", requireAdmin(ctrl.PortMigration.Migrate))
+	mux.HandleFunc("GET /api/orangepi/remote/ports/migrations", requireAdmin(ctrl.PortMigration.List))
+	mux.HandleFunc("POST /api/orangepi/remote/ports/migrations/resolve", requireAdmin(ctrl.PortMigration.Resolve))
+
+	// OrangePi 期望状态对账（数据库端口/FRP 服务端 vs 设备实际配置）
+	mux.HandleFunc("GET /api/orangepi/reconcile/drift", requireAdmin(ctrl.Reconcile.Drift))
+	mux.HandleFunc("POST /api/orangepi/reconcile", requireAdmin(ctrl.Reconcile.Run))
+	mux.HandleFunc("PUT /api/orangepi/reconcile/disabled", requireAdmin(ctrl.Reconcile.SetDisabled))
+
+	// OrangePi 远程容器控制（start/stop/restart，写审计日志）
+	mux.HandleFunc("GET /api/orangepi/remote/services", requireAdmin(ctrl.ServiceControl.Allowed))
+	mux.HandleFunc("POST /api/orangepi/remote/services", requireAdmin(ctrl.ServiceControl.Control))
+	mux.HandleFunc("POST /api/building/remote/services", requireAdmin(ctrl.ServiceControl.ControlBuilding))
+
+	// 审计日志
+	mux.HandleFunc("GET /api/audit-logs", requireAdmin(ctrl.Audit.List))
+
+	// 异步任务（批量远程操作）
+	mux.HandleFunc("POST /api/jobs", requireAdmin(ctrl.Job.Create))
+	mux.HandleFunc("GET /api/jobs", requireAdmin(ctrl.Job.List))
+	mux.HandleFunc("GET /api/jobs/{id}", requireAdmin(ctrl.Job.Get))
+	mux.HandleFunc("POST /api/jobs/{id}/cancel", requireAdmin(ctrl.Job.Cancel))
 
 	// Building
-	mux.HandleFunc("GET /api/building", requireAdmin(ctrl.Building.List))
+	mux.HandleFunc("GET /api/building", requireSiteAdmin(ctrl.Building.List))
 	mux.HandleFunc("POST /api/building", requireAdmin(ctrl.Building.Create))
 	mux.HandleFunc("PUT /api/building", requireAdmin(ctrl.Building.Update))
 	mux.HandleFunc("DELETE /api/building", requireAdmin(ctrl.Building.Delete))
+	mux.HandleFunc("GET /api/building/{id}", requireAdmin(ctrl.Building.Detail)) // 详情(含通道推流状态)
+	mux.HandleFunc("GET /api/building/{id}/topology", requireSiteAdmin(ctrl.Topology.Building))
+	mux.HandleFunc("GET /api/building/{id}/mediamtx/paths", requireAdmin(ctrl.MediaMTXSync.Preview))
+	mux.HandleFunc("POST /api/building/{id}/mediamtx/sync", requireAdmin(ctrl.MediaMTXSync.Sync))
 
 	// Building-OrangePi 绑定管理
 	mux.HandleFunc("POST /api/building/bind", requireAdmin(ctrl.Building.BindOrangePi))
@@ -73,16 +181,44 @@ func SetupRoutes(mux *http.ServeMux, ctrl ControllerSet, mw MiddlewareSet) {
 	// Building-OrangePi 绑定管理 (新路由路径)
 	mux.HandleFunc("POST /api/bind/building-orangepi", requireAdmin(ctrl.Building.BindOrangePi))
 	mux.HandleFunc("DELETE /api/bind/building-orangepi", requireAdmin(ctrl.Building.UnbindOrangePi))
+	mux.HandleFunc("PUT /api/bind/building-orangepi", requireAdmin(ctrl.Building.UpdateBind))
 	mux.HandleFunc("GET /api/bind/building-orangepi/{building_id}", requireAdmin(ctrl.Building.GetBuildingOrangePis))
 
 	// Building-NVR 绑定管理
 	mux.HandleFunc("POST /api/bind/building-nvr", requireAdmin(ctrl.Building.BindNVR))
 	mux.HandleFunc("DELETE /api/bind/building-nvr", requireAdmin(ctrl.Building.UnbindNVR))
+	mux.HandleFunc("PUT /api/bind/building-nvr", requireAdmin(ctrl.Building.MoveNVR))
 	mux.HandleFunc("GET /api/bind/building-nvr/{building_id}", requireAdmin(ctrl.Building.GetBuildingNVRs))
 
+	// 绑定历史
+	mux.HandleFunc("GET /api/orangepi/{id}/bindings", requireAdmin(ctrl.BindingHistory.OrangePi))
+	mux.HandleFunc("GET /api/nvr/{id}/bindings", requireAdmin(ctrl.BindingHistory.NVR))
+	mux.HandleFunc("GET /api/building/{id}/bindings", requireAdmin(ctrl.BindingHistory.Building))
+
 	// NVR
-	mux.HandleFunc("GET /api/nvr", requireAdmin(ctrl.NVR.List))
+	mux.HandleFunc("GET /api/nvr", requireSiteAdmin(ctrl.NVR.List))
 	mux.HandleFunc("POST /api/nvr", requireAdmin(ctrl.NVR.Create))
 	mux.HandleFunc("PUT /api/nvr", requireAdmin(ctrl.NVR.Update))
 	mux.HandleFunc("DELETE /api/nvr", requireAdmin(ctrl.NVR.Delete))
+
+	// 站点层级（site / floor / zone）与站点授权
+	mux.HandleFunc("GET /api/site", requireSiteAdmin(ctrl.Site.List))
+	mux.HandleFunc("POST /api/site", requireAdmin(ctrl.Site.Create))
+	mux.HandleFunc("PUT /api/site/{id}", requireAdmin(ctrl.Site.Update))
+	mux.HandleFunc("DELETE /api/site/{id}", requireAdmin(ctrl.Site.Delete))
+	mux.HandleFunc("GET /api/site/{id}/tree", requireSiteAdmin(ctrl.Site.Tree))
+	mux.HandleFunc("GET /api/site/grants", requireAdmin(ctrl.Site.ListGrants))
+	mux.HandleFunc("POST /api/site/grants", requireAdmin(ctrl.Site.CreateGrant))
+	mux.HandleFunc("DELETE /api/site/grants/{id}", requireAdmin(ctrl.Site.DeleteGrant))
+
+	// iSmart 楼栋同步
+	mux.HandleFunc("GET /api/ismart/sync", requireAdmin(ctrl.ISmartSync.Status))
+	mux.HandleFunc("POST /api/ismart/sync", requireAdmin(ctrl.ISmartSync.Sync))
+	mux.HandleFunc("GET /api/ismart/sync/runs", requireAdmin(ctrl.ISmartSync.Runs))
+	mux.HandleFunc("GET /api/ismart/sync/runs/{id}", requireAdmin(ctrl.ISmartSync.GetRun))
+
+	// 回收站（resource: building / orangepi / nvr / admin）
+	mux.HandleFunc("GET /api/trash/{resource}", requireAdmin(ctrl.Trash.List))
+	mux.HandleFunc("POST /api/trash/{resource}/{id}/restore", requireAdmin(ctrl.Trash.Restore))
+	mux.HandleFunc("DELETE /api/trash/{resource}/{id}", requireAdmin(ctrl.Trash.Purge))
 }
@@ -97,6 +97,11 @@ func (s *AdminService) Create(ctx context.Context, username, password string) (*
 		return nil, errors.New("username and password are required")
 	}
 
+	// 用户名被回收站中的管理员占用时需先恢复或彻底删除
+	if err := checkNotInTrash(s.db.WithContext(ctx), &models.Adminer{}, "username", username); err != nil {
+		return nil, err
+	}
+
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
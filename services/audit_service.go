@@ -0,0 +1,104 @@
+package services
+
+// AuditService Methods:
+//0. NewAuditService(db *gorm.DB) -> 初始化审计日志服务
+//1. Record(ctx context.Context, actor Actor, entry models.AuditLog) -> 写入审计日志
+//2. List(ctx context.Context, query AuditListQuery) -> 分页查询审计日志
+
+import (
+	"context"
+	"log"
+
+	"icctv-http-service/models"
+
+	"gorm.io/gorm"
+)
+
+// AuditServiceInterface 定义审计日志能力
+type AuditServiceInterface interface {
+	Record(ctx context.Context, actor Actor, entry models.AuditLog)                                     //1.写入日志
+	List(ctx context.Context, query AuditListQuery) ([]models.AuditLog, models.PaginationResult, error) //2.查询日志
+}
+
+// Actor 操作发起人
+type Actor struct {
+	Username string
+	ClientIP string
+}
+
+// AuditListQuery 审计日志查询条件
+type AuditListQuery struct {
+	models.PaginationQuery
+	Action     string
+	TargetType string
+	TargetID   int64
+	Actor      string
+}
+
+// AuditService 审计日志
+type AuditService struct {
+	db *gorm.DB
+}
+
+// 0. NewAuditService 构造函数
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+// 1. Record 写入审计日志，写入失败只记录到进程日志，不影响业务操作
+func (s *AuditService) Record(ctx context.Context, actor Actor, entry models.AuditLog) {
+	entry.Actor = actor.Username
+	entry.ClientIP = actor.ClientIP
+	if err := s.db.WithContext(context.WithoutCancel(ctx)).Create(&entry).Error; err != nil {
+		log.Printf("audit: failed to record %s on %s %d by %s: %v",
+			entry.Action, entry.TargetType, entry.TargetID, entry.Actor, err)
+	}
+}
+
+// 2. List 分页查询审计日志（默认按时间倒序）
+func (s *AuditService) List(ctx context.Context, query AuditListQuery) ([]models.AuditLog, models.PaginationResult, error) {
+	if query.PageNum <= 0 {
+		query.PageNum = 1
+	}
+	if query.PageSize <= 0 || query.PageSize > 100 {
+		query.PageSize = 20
+	}
+
+	var (
+		logs  []models.AuditLog
+		total int64
+	)
+	tx := s.db.WithContext(ctx).Model(&models.AuditLog{})
+	if query.Action != "" {
+		tx = tx.Where("action = ?", query.Action)
+	}
+	if query.TargetType != "" {
+		tx = tx.Where("target_type = ?", query.TargetType)
+	}
+	if query.TargetID > 0 {
+		tx = tx.Where("target_id = ?", query.TargetID)
+	}
+	if query.Actor != "" {
+		tx = tx.Where("actor = ?", query.Actor)
+	}
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, models.PaginationResult{}, err
+	}
+
+	order := "id desc"
+	if query.Asc {
+		order = "id asc"
+	}
+	if err := tx.Order(order).
+		Offset((query.PageNum - 1) * query.PageSize).
+		Limit(query.PageSize).
+		Find(&logs).Error; err != nil {
+		return nil, models.PaginationResult{}, err
+	}
+
+	return logs, models.PaginationResult{
+		Total:    int(total),
+		PageNum:  query.PageNum,
+		PageSize: query.PageSize,
+	}, nil
+}
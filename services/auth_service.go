@@ -5,6 +5,9 @@ package services
 //1. PublicToken() -> 返回公开访问 Token
 //2. Login(ctx context.Context, username, password string) -> 校验管理员并签发 JWT
 //3. ValidateToken(tokenStr string) -> 解析并验证 JWT
+//4. VideoTokenSecret() -> 返回视频 Token 签名秘钥
+//5. GenerateRecordingToken(buildingID, channel, purpose string, start, end time.Time, ttl time.Duration) -> 生成限定频道与时间范围的录像 Token
+//6. ValidateVideoToken(token string) -> 校验视频 Token 签名与有效期
 
 import (
 	"context"
@@ -16,6 +19,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"icctv-http-service/models"
@@ -26,11 +30,23 @@ import (
 
 // AuthServiceInterface 定义认证业务能力
 type AuthServiceInterface interface {
-	GenerateVideoToken(ctx context.Context, buildingID string, channels []string) (string, error) //1.生成视频访问Token
-	Login(ctx context.Context, username, password string) (*AuthToken, error)                     //2.校验账号密码并签发JWT
-	ValidateToken(tokenStr string) (*AdminClaims, error)                                          //3.验证JWT并返回Claims
+	GenerateVideoToken(ctx context.Context, buildingID string, channels []string) (string, error)                                   //1.生成视频访问Token
+	Login(ctx context.Context, username, password string) (*AuthToken, error)                                                       //2.校验账号密码并签发JWT
+	ValidateToken(tokenStr string) (*AdminClaims, error)                                                                            //3.验证JWT并返回Claims
+	VideoTokenSecret() string                                                                                                       //4.视频Token签名秘钥
+	GenerateRecordingToken(buildingID, channel, purpose string, start, end time.Time, ttl time.Duration) (string, time.Time, error) //5.生成录像Token
+	ValidateVideoToken(token string) (*VideoTokenPayload, error)                                                                    //6.校验视频Token
 }
 
+// 视频 Token 用途（为空表示普通观看 Token）
+const (
+	VideoTokenPurposeView     = "view"     // 观看直播/查询录像
+	VideoTokenPurposeDownload = "download" // 下载录像
+)
+
+// ErrInvalidVideoToken 视频 Token 格式、签名错误或已过期
+var ErrInvalidVideoToken = errors.New("invalid video token")
+
 // AuthService 认证相关逻辑
 type AuthService struct {
 	db                  *gorm.DB
@@ -56,11 +72,15 @@ type AdminClaims struct {
 }
 
 // VideoTokenPayload 视频Token的Payload结构
+// Purpose、Start、End 仅录像 Token 使用：限定用途与可访问的录像时间范围(Unix秒)
 type VideoTokenPayload struct {
 	Channels   []string `json:"channels"`
 	BuildingID string   `json:"building_id"`
 	Exp        int64    `json:"exp"`
 	Iat        int64    `json:"iat"`
+	Purpose    string   `json:"purpose,omitempty"`
+	Start      int64    `json:"start,omitempty"`
+	End        int64    `json:"end,omitempty"`
 }
 
 // 0. NewAuthService 构造函数，加载基础配置
@@ -98,22 +118,24 @@ func NewAuthService(db *gorm.DB, adminService *AdminService, orangePiService *Or
 
 // 1. GenerateVideoToken 生成视频访问 Token
 // 参数：buildingID - 建筑ISmartID, channels - 频道列表
-// 逻辑：验证 buildingID 是否存在且关联的 OrangePi 设备存在
+// 逻辑：验证 buildingID 是否存在（宽限期内的旧 ismartId 也可用，Token 中保留请求的值）且关联的 OrangePi 设备存在
 func (s *AuthService) GenerateVideoToken(ctx context.Context, buildingID string, channels []string) (string, error) {
 	// 检查建筑是否存在
-	var building models.Building
-	if err := s.db.WithContext(ctx).Where("ismart_id = ?", buildingID).First(&building).Error; err != nil {
+	building, err := findBuildingByISmartID(ctx, s.db, buildingID)
+	if err != nil {
 		return "", fmt.Errorf("building not found: %s", buildingID)
 	}
 
-	// 检查该建筑是否关联的 OrangePi 设备存在
+	// 检查该建筑是否关联了已部署的 OrangePi 设备（维护、故障等状态不签发）
 	var deviceCount int64
-	if err := s.db.WithContext(ctx).Model(&models.OrangePi{}).Where("ismart_id = ?", buildingID).Count(&deviceCount).Error; err != nil {
+	if err := s.db.WithContext(ctx).Model(&models.OrangePi{}).
+		Where("building_id = ? AND lifecycle_state IN ?", building.ID, models.LifecycleServingStates).
+		Count(&deviceCount).Error; err != nil {
 		return "", fmt.Errorf("failed to check devices: %w", err)
 	}
 
 	if deviceCount == 0 {
-		return "", fmt.Errorf("no devices associated with building: %s", buildingID)
+		return "", fmt.Errorf("no deployed devices associated with building: %s", buildingID)
 	}
 
 	// 生成视频 Token (HMAC-SHA256 签名格式)
@@ -133,7 +155,11 @@ func (s *AuthService) generateHMACToken(buildingID string, channels []string) (s
 		Exp:        expiry,
 		Iat:        now,
 	}
+	return s.signVideoToken(payload)
+}
 
+// signVideoToken 签名视频 Token Payload
+func (s *AuthService) signVideoToken(payload VideoTokenPayload) (string, error) {
 	// JSON 序列化（键排序保证一致性）
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
@@ -204,3 +230,54 @@ func (s *AuthService) ValidateToken(tokenStr string) (*AdminClaims, error) {
 
 	return claims, nil
 }
+
+// 4. VideoTokenSecret 返回视频 Token 签名秘钥（下发给 OrangePi 用于校验 Token）
+func (s *AuthService) VideoTokenSecret() string {
+	return s.videoTokenSecretKey
+}
+
+// 5. GenerateRecordingToken 生成录像 Token：只包含一个频道，限定用途(view/download)与录像时间范围，ttl 后过期
+func (s *AuthService) GenerateRecordingToken(buildingID, channel, purpose string, start, end time.Time, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	token, err := s.signVideoToken(VideoTokenPayload{
+		Channels:   []string{channel},
+		BuildingID: buildingID,
+		Exp:        expiresAt.Unix(),
+		Iat:        now.Unix(),
+		Purpose:    purpose,
+		Start:      start.Unix(),
+		End:        end.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// 6. ValidateVideoToken 校验视频 Token 的签名与有效期，返回 Payload
+func (s *AuthService) ValidateVideoToken(token string) (*VideoTokenPayload, error) {
+	payloadB64, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidVideoToken
+	}
+	payloadBytes, err := base64.URLEncoding.DecodeString(payloadB64)
+	if err != nil {
+		return nil, ErrInvalidVideoToken
+	}
+
+	h := hmac.New(sha256.New, []byte(s.videoTokenSecretKey))
+	h.Write(payloadBytes)
+	if !hmac.Equal([]byte(signature), []byte(fmt.Sprintf("%x", h.Sum(nil)))) {
+		return nil, ErrInvalidVideoToken
+	}
+
+	var payload VideoTokenPayload
+	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
+		return nil, ErrInvalidVideoToken
+	}
+	if payload.Exp < time.Now().Unix() {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidVideoToken)
+	}
+	return &payload, nil
+}
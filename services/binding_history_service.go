@@ -0,0 +1,307 @@
+package services
+
+// BindingHistoryService Methods:
+//0. NewBindingHistoryService(db *gorm.DB) -> 初始化绑定历史服务
+//1. DeviceTimeline(ctx context.Context, deviceType string, id int64, at *time.Time) -> 查询设备的绑定时间线（可指定时间点）
+//2. BuildingDevices(ctx context.Context, buildingID int64, from, to time.Time) -> 查询时间段内服务过建筑的设备
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"icctv-http-service/models"
+
+	"gorm.io/gorm"
+)
+
+// BindingHistoryServiceInterface 定义绑定历史查询能力
+type BindingHistoryServiceInterface interface {
+	DeviceTimeline(ctx context.Context, deviceType string, id int64, at *time.Time) (*DeviceBindingTimeline, error) //1.设备绑定时间线
+	BuildingDevices(ctx context.Context, buildingID int64, from, to time.Time) ([]BindingPeriod, error)             //2.建筑设备历史
+}
+
+// BindingPeriod 设备绑定在某个建筑的时间段（Until 为空表示至今仍绑定）
+type BindingPeriod struct {
+	DeviceType string     `json:"device_type"`
+	DeviceID   int64      `json:"device_id"`
+	DeviceName string     `json:"device_name"`
+	BuildingID int64      `json:"building_id"`
+	ISmartID   string     `json:"ismartid"`
+	From       time.Time  `json:"from"`
+	Until      *time.Time `json:"until"`
+}
+
+// DeviceBindingTimeline 设备的绑定时间段及原始变更记录
+type DeviceBindingTimeline struct {
+	DeviceType string                `json:"device_type"`
+	DeviceID   int64                 `json:"device_id"`
+	DeviceName string                `json:"device_name"`
+	At         *time.Time            `json:"at,omitempty"`
+	Periods    []BindingPeriod       `json:"periods"` // 指定 at 时只包含覆盖该时间点的时间段
+	Events     []models.BindingEvent `json:"events"`
+}
+
+// BindingHistoryService 设备绑定历史：绑定变更由 BuildingService 等在同一事务内写入 binding_events，
+// 记录之前已存在的绑定视为从设备创建时开始
+type BindingHistoryService struct {
+	db *gorm.DB
+}
+
+// 0. NewBindingHistoryService 构造函数
+func NewBindingHistoryService(db *gorm.DB) *BindingHistoryService {
+	return &BindingHistoryService{db: db}
+}
+
+// 1. DeviceTimeline 查询设备的绑定时间线（包含已删除的设备），at 不为空时只返回该时间点所在的时间段
+func (s *BindingHistoryService) DeviceTimeline(ctx context.Context, deviceType string, id int64, at *time.Time) (*DeviceBindingTimeline, error) {
+	timeline, err := s.deviceTimeline(ctx, deviceType, id)
+	if err != nil {
+		return nil, err
+	}
+	if at != nil {
+		timeline.At = at
+		periods := make([]BindingPeriod, 0, 1)
+		for _, period := range timeline.Periods {
+			if period.covers(*at, *at) {
+				periods = append(periods, period)
+			}
+		}
+		timeline.Periods = periods
+	}
+	return timeline, nil
+}
+
+// 2. BuildingDevices 查询 [from, to] 内绑定过建筑的设备及各自的绑定时间段（按开始时间排序）
+func (s *BindingHistoryService) BuildingDevices(ctx context.Context, buildingID int64, from, to time.Time) ([]BindingPeriod, error) {
+	var building models.Building
+	if err := s.db.WithContext(ctx).Unscoped().First(&building, buildingID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBuildingNotFound
+		}
+		return nil, err
+	}
+
+	// 候选设备：变更记录涉及该建筑的设备，以及当前绑定该建筑的设备
+	type deviceRef struct {
+		DeviceType string
+		DeviceID   int64
+	}
+	var refs []deviceRef
+	if err := s.db.WithContext(ctx).Model(&models.BindingEvent{}).
+		Distinct("device_type", "device_id").
+		Where("from_building_id = ? OR to_building_id = ?", buildingID, buildingID).
+		Scan(&refs).Error; err != nil {
+		return nil, err
+	}
+	var orangePiIDs []int64
+	if err := s.db.WithContext(ctx).Unscoped().Model(&models.OrangePi{}).
+		Where("building_id = ?", buildingID).
+		Pluck("id", &orangePiIDs).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range orangePiIDs {
+		refs = append(refs, deviceRef{models.BindingDeviceOrangePi, id})
+	}
+	var nvrIDs []int64
+	if err := s.db.WithContext(ctx).Unscoped().Model(&models.NVR{}).
+		Where("building_id = ?", buildingID).
+		Pluck("id", &nvrIDs).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range nvrIDs {
+		refs = append(refs, deviceRef{models.BindingDeviceNVR, id})
+	}
+
+	seen := make(map[deviceRef]bool, len(refs))
+	periods := make([]BindingPeriod, 0)
+	for _, ref := range refs {
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		timeline, err := s.deviceTimeline(ctx, ref.DeviceType, ref.DeviceID)
+		if errors.Is(err, ErrOrangePiNotFound) || errors.Is(err, ErrNVRNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, period := range timeline.Periods {
+			if period.BuildingID == buildingID && period.covers(from, to) {
+				periods = append(periods, period)
+			}
+		}
+	}
+	sort.Slice(periods, func(i, j int) bool {
+		if !periods[i].From.Equal(periods[j].From) {
+			return periods[i].From.Before(periods[j].From)
+		}
+		return periods[i].DeviceID < periods[j].DeviceID
+	})
+	return periods, nil
+}
+
+// covers 时间段是否与 [from, to] 有交集
+func (p BindingPeriod) covers(from, to time.Time) bool {
+	if p.From.After(to) {
+		return false
+	}
+	return p.Until == nil || p.Until.After(from)
+}
+
+// deviceTimeline 根据设备当前绑定与变更记录还原绑定时间段
+func (s *BindingHistoryService) deviceTimeline(ctx context.Context, deviceType string, id int64) (*DeviceBindingTimeline, error) {
+	var (
+		name            string
+		createdAt       time.Time
+		deletedAt       gorm.DeletedAt
+		currentBuilding int64
+	)
+	switch deviceType {
+	case models.BindingDeviceOrangePi:
+		var device models.OrangePi
+		if err := s.db.WithContext(ctx).Unscoped().First(&device, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrOrangePiNotFound
+			}
+			return nil, err
+		}
+		name, createdAt, deletedAt = device.Name, device.CreatedAt, device.DeletedAt
+		currentBuilding = buildingIDValue(device.BuildingID)
+	case models.BindingDeviceNVR:
+		var nvr models.NVR
+		if err := s.db.WithContext(ctx).Unscoped().First(&nvr, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrNVRNotFound
+			}
+			return nil, err
+		}
+		name, createdAt, deletedAt = nvr.Name, nvr.CreatedAt, nvr.DeletedAt
+		currentBuilding = nvr.BuildingID
+	default:
+		return nil, fmt.Errorf("unknown device type: %s", deviceType)
+	}
+	currentISmartID, err := buildingISmartID(s.db.WithContext(ctx), currentBuilding)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []models.BindingEvent
+	if err := s.db.WithContext(ctx).
+		Where("device_type = ? AND device_id = ?", deviceType, id).
+		Order("created_at asc, id asc").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	timeline := &DeviceBindingTimeline{
+		DeviceType: deviceType,
+		DeviceID:   id,
+		DeviceName: name,
+		Periods:    make([]BindingPeriod, 0, len(events)+1),
+		Events:     events,
+	}
+	// 第一条记录之前的绑定视为从设备创建时开始
+	open := BindingPeriod{BuildingID: currentBuilding, ISmartID: currentISmartID, From: createdAt}
+	if len(events) > 0 {
+		open.BuildingID, open.ISmartID = events[0].FromBuildingID, events[0].FromISmartID
+	}
+	for _, event := range events {
+		if open.BuildingID != 0 {
+			until := event.CreatedAt
+			open.Until = &until
+			timeline.Periods = append(timeline.Periods, open)
+		}
+		open = BindingPeriod{BuildingID: event.ToBuildingID, ISmartID: event.ToISmartID, From: event.CreatedAt}
+	}
+	if open.BuildingID != 0 {
+		// 已删除的设备在删除时结束绑定
+		if deletedAt.Valid {
+			until := deletedAt.Time
+			open.Until = &until
+		}
+		timeline.Periods = append(timeline.Periods, open)
+	}
+	for i := range timeline.Periods {
+		timeline.Periods[i].DeviceType = deviceType
+		timeline.Periods[i].DeviceID = id
+		timeline.Periods[i].DeviceName = name
+	}
+	return timeline, nil
+}
+
+// recordOrangePiBinding 在事务内记录 OrangePi 的绑定变更（建筑未变化时不记录）
+func recordOrangePiBinding(tx *gorm.DB, orangePiID int64, fromBuildingID, toBuildingID *int64, reason, actor string) error {
+	return recordBinding(tx, models.BindingDeviceOrangePi, orangePiID, buildingIDValue(fromBuildingID), buildingIDValue(toBuildingID), reason, actor)
+}
+
+// recordNVRBinding 在事务内记录 NVR 的绑定变更（建筑未变化时不记录）
+func recordNVRBinding(tx *gorm.DB, nvrID int64, fromBuildingID, toBuildingID int64, reason, actor string) error {
+	return recordBinding(tx, models.BindingDeviceNVR, nvrID, fromBuildingID, toBuildingID, reason, actor)
+}
+
+// recordRestoredBinding 记录恢复设备时的绑定变更：从最近一条事件的目标建筑（没有事件时为删除前的建筑）变为 buildingID，
+// 避免随建筑级联删除已记录解绑的设备重复记录
+func recordRestoredBinding(tx *gorm.DB, deviceType string, deviceID, previousBuildingID, buildingID int64, reason, actor string) error {
+	from := previousBuildingID
+	var last models.BindingEvent
+	err := tx.Where("device_type = ? AND device_id = ?", deviceType, deviceID).Order("id desc").First(&last).Error
+	switch {
+	case err == nil:
+		from = last.ToBuildingID
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return err
+	}
+	return recordBinding(tx, deviceType, deviceID, from, buildingID, reason, actor)
+}
+
+// recordBinding 写入绑定事件，同时记录当时的建筑 ismartId
+func recordBinding(tx *gorm.DB, deviceType string, deviceID int64, fromBuildingID, toBuildingID int64, reason, actor string) error {
+	if fromBuildingID == toBuildingID {
+		return nil
+	}
+	fromISmartID, err := buildingISmartID(tx, fromBuildingID)
+	if err != nil {
+		return err
+	}
+	toISmartID, err := buildingISmartID(tx, toBuildingID)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.BindingEvent{
+		DeviceType:     deviceType,
+		DeviceID:       deviceID,
+		FromBuildingID: fromBuildingID,
+		ToBuildingID:   toBuildingID,
+		FromISmartID:   fromISmartID,
+		ToISmartID:     toISmartID,
+		Reason:         reason,
+		Actor:          actor,
+	}).Error
+}
+
+// buildingIDValue OrangePi 的建筑ID，未绑定时为 0
+func buildingIDValue(id *int64) int64 {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
+// buildingISmartID 按建筑ID查询 ismartId，为 0 或不存在时返回空字符串
+func buildingISmartID(tx *gorm.DB, buildingID int64) (string, error) {
+	if buildingID == 0 {
+		return "", nil
+	}
+	var building models.Building
+	if err := tx.Unscoped().First(&building, buildingID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	return building.ISmartID, nil
+}
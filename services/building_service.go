@@ -1,15 +1,21 @@
 package services
 
 // BuildingService Methods:
-//0. NewBuildingService(db *gorm.DB) -> 初始化建筑服务
-//1. List(ctx context.Context) -> 查询所有建筑及其设备
+//0. NewBuildingService(db *gorm.DB, orangePiService *OrangePiService, auditService *AuditService) -> 初始化建筑服务
+//1. List(ctx context.Context, scope *SiteScope) -> 查询建筑及其 OrangePi 与 NVR（scope 不为空时只返回范围内的建筑）
 //2. Create(ctx context.Context, payload models.Building) -> 创建建筑
-//3. Update(ctx context.Context, id int64, payload models.Building) -> 更新建筑信息
-//4. Delete(ctx context.Context, id int64) -> 删除建筑
+//3. Update(ctx context.Context, id int64, payload models.Building, actor Actor) -> 更新建筑信息（ismartId 变更时保留旧值别名）
+//4. Delete(ctx context.Context, id int64, cascade string, actor Actor) -> 删除建筑（有关联设备时拒绝，或按 cascade 解绑/删除）
 
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"time"
 
 	"icctv-http-service/models"
 
@@ -18,85 +24,357 @@ import (
 
 // 错误定义
 var (
-	ErrBuildingNotFound = errors.New("building not found")
-	ErrOrangePiNotFound = errors.New("orangepi not found")
-	ErrNVRNotFound      = errors.New("nvr not found")
-	ErrAlreadyBound     = errors.New("already bound to another building")
-	ErrNotBound         = errors.New("not bound to any building")
+	ErrBuildingNotFound   = errors.New("building not found")
+	ErrOrangePiNotFound   = errors.New("orangepi not found")
+	ErrNVRNotFound        = errors.New("nvr not found")
+	ErrAlreadyBound       = errors.New("already bound to another building")
+	ErrNotBound           = errors.New("not bound to any building")
+	ErrBuildingNoISmartID = errors.New("building has no ismart_id")
+	ErrDeviceNotMovable   = errors.New("device must be healthy or in maintenance to move")
+	ErrBindingViaUpdate   = errors.New("building binding cannot be changed by update")
+	ErrISmartIDConflict   = errors.New("ismart_id already in use")
+	ErrBuildingInUse      = errors.New("building still has orangepis or nvrs")
+	ErrCascadeInvalid     = errors.New("invalid cascade mode, expected unbind or delete")
 )
 
+// 删除建筑时对关联设备的处理方式
+const (
+	BuildingCascadeUnbind = "unbind" // 解绑关联的 OrangePi 与 NVR
+	BuildingCascadeDelete = "delete" // 删除关联的 OrangePi 与 NVR
+)
+
+// moveHealthTimeout 迁移前健康检查的超时时间
+const moveHealthTimeout = 5 * time.Second
+
+// defaultAliasGraceHours ismartId 变更后旧值别名的默认有效期（小时）
+const defaultAliasGraceHours = 72
+
 // BuildingServiceInterface 定义建筑业务能力
 type BuildingServiceInterface interface {
-	List(ctx context.Context) ([]models.Building, error)                                     //1.查询建筑列表
-	Create(ctx context.Context, payload models.Building) (*models.Building, error)           //2.创建建筑
-	Update(ctx context.Context, id int64, payload models.Building) (*models.Building, error) //3.更新建筑
-	Delete(ctx context.Context, id int64) error                                              //4.删除建筑
-	BindOrangePi(ctx context.Context, buildingId int64, orangePiId int64) error              //5.绑定OrangePi到建筑
-	UnbindOrangePi(ctx context.Context, orangePiId int64) error                              //6.解绑OrangePi
-	UpdateBind(ctx context.Context, orangePiId int64, newBuildingId int64) error             //7.更新绑定关系
-	GetOrangePisByBuildingID(ctx context.Context, buildingId int64) ([]models.OrangePi, error) //8.查询Building关联的OrangePi
-	BindNVR(ctx context.Context, buildingId int64, nvrId int64) error                        //9.绑定NVR到建筑
-	UnbindNVR(ctx context.Context, nvrId int64) error                                        //10.解绑NVR
-	GetNVRsByBuildingID(ctx context.Context, buildingId int64) ([]models.NVR, error)         //11.查询Building关联的NVR
+	List(ctx context.Context, scope *SiteScope) ([]models.Building, error)                                        //1.查询建筑列表
+	Create(ctx context.Context, payload models.Building) (*models.Building, error)                                //2.创建建筑
+	Update(ctx context.Context, id int64, payload models.Building, actor Actor) (*models.Building, error)         //3.更新建筑
+	Delete(ctx context.Context, id int64, cascade string, actor Actor) (*BuildingDeleteResult, error)             //4.删除建筑
+	BindOrangePi(ctx context.Context, buildingId int64, orangePiId int64, reason string, actor Actor) error       //5.绑定OrangePi到建筑
+	UnbindOrangePi(ctx context.Context, orangePiId int64, reason string, actor Actor) error                       //6.解绑OrangePi
+	MoveOrangePi(ctx context.Context, orangePiId int64, newBuildingId int64, opts MoveOptions, actor Actor) error //7.迁移OrangePi到其他建筑
+	GetOrangePisByBuildingID(ctx context.Context, buildingId int64) ([]models.OrangePi, error)                    //8.查询Building关联的OrangePi
+	BindNVR(ctx context.Context, buildingId int64, nvrId int64, reason string, actor Actor) error                 //9.绑定NVR到建筑
+	UnbindNVR(ctx context.Context, nvrId int64, reason string, actor Actor) error                                 //10.解绑NVR
+	GetNVRsByBuildingID(ctx context.Context, buildingId int64) ([]models.NVR, error)                              //11.查询Building关联的NVR
+	Get(ctx context.Context, id int64) (*models.Building, error)                                                  //12.查询建筑详情
+	MoveNVR(ctx context.Context, nvrId int64, newBuildingId int64, reason string, actor Actor) error              //13.迁移NVR到其他建筑
+}
+
+// MoveOptions 迁移OrangePi的选项
+type MoveOptions struct {
+	Reason         string // 迁移原因(记录到绑定历史)
+	RequireHealthy bool   // 要求设备健康检查通过或处于维护状态
+}
+
+// BuildingDependent 建筑关联的设备
+type BuildingDependent struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// BuildingDeleteResult 删除建筑的结果：拒绝删除时为仍关联的设备，级联时为被解绑或删除的设备
+type BuildingDeleteResult struct {
+	BuildingID int64               `json:"building_id"`
+	Cascade    string              `json:"cascade,omitempty"`
+	Deleted    bool                `json:"deleted"`
+	OrangePis  []BuildingDependent `json:"orangepis"`
+	NVRs       []BuildingDependent `json:"nvrs"`
 }
 
 // BuildingService 建筑业务逻辑
 type BuildingService struct {
-	db *gorm.DB
+	db              *gorm.DB
+	orangePiService *OrangePiService
+	auditService    *AuditService
+	aliasGrace      time.Duration
 }
 
-// 0. NewBuildingService 构造函数
-func NewBuildingService(db *gorm.DB) *BuildingService {
-	return &BuildingService{db: db}
+// 0. NewBuildingService 构造函数，BUILDING_ALIAS_GRACE_HOURS 为 ismartId 变更后旧值的有效期
+func NewBuildingService(db *gorm.DB, orangePiService *OrangePiService, auditService *AuditService) *BuildingService {
+	hours := defaultAliasGraceHours
+	if val := os.Getenv("BUILDING_ALIAS_GRACE_HOURS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			hours = parsed
+		} else {
+			log.Printf("Warning: invalid BUILDING_ALIAS_GRACE_HOURS=%q, using %d", val, hours)
+		}
+	}
+	return &BuildingService{
+		db:              db,
+		orangePiService: orangePiService,
+		auditService:    auditService,
+		aliasGrace:      time.Duration(hours) * time.Hour,
+	}
 }
 
-// 1. List 建筑列表
-func (s *BuildingService) List(ctx context.Context) ([]models.Building, error) {
+// 1. List 建筑列表（含 OrangePi 与 NVR），scope 不为空时只返回范围内的建筑（站点子树筛选或受限管理员的授权范围）
+func (s *BuildingService) List(ctx context.Context, scope *SiteScope) ([]models.Building, error) {
 	var items []models.Building
-	if err := s.db.WithContext(ctx).Preload("OrangePis").Find(&items).Error; err != nil {
+	tx := s.db.WithContext(ctx).Preload("OrangePis").Preload("NVRs", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id asc")
+	})
+	if scope != nil {
+		tx = tx.Where("id IN ?", scope.BuildingIDs)
+	}
+	if err := tx.Find(&items).Error; err != nil {
 		return nil, err
 	}
+	for i := range items {
+		for j := range items[i].NVRs {
+			scope.LimitChannels(&items[i].NVRs[j])
+		}
+	}
 	return items, nil
 }
 
-// 2. Create 创建建筑
+// 2. Create 创建建筑（ismartId 被回收站中的建筑占用时返回 ErrInTrash；site_id 必须是 site 类型的站点节点）
 func (s *BuildingService) Create(ctx context.Context, payload models.Building) (*models.Building, error) {
+	if err := checkNotInTrash(s.db.WithContext(ctx), &models.Building{}, "ismart_id", payload.ISmartID); err != nil {
+		return nil, err
+	}
+	if payload.SiteID != nil && *payload.SiteID == 0 {
+		payload.SiteID = nil
+	}
+	if err := checkSiteGroup(s.db.WithContext(ctx), payload.SiteID); err != nil {
+		return nil, err
+	}
 	if err := s.db.WithContext(ctx).Create(&payload).Error; err != nil {
 		return nil, err
 	}
 	return &payload, nil
 }
 
-// 3. Update 更新建筑
-func (s *BuildingService) Update(ctx context.Context, id int64, payload models.Building) (*models.Building, error) {
-	var item models.Building
-	if err := s.db.WithContext(ctx).First(&item, id).Error; err != nil {
+// 3. Update 更新建筑：ismartId 变更在同一事务内校验唯一性（包括已删除的建筑）、更新引用旧值的记录并保留旧值别名
+func (s *BuildingService) Update(ctx context.Context, id int64, payload models.Building, actor Actor) (*models.Building, error) {
+	var (
+		item        models.Building
+		oldISmartID string
+	)
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&item, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBuildingNotFound
+			}
+			return err
+		}
+
+		if payload.ISmartID != "" && payload.ISmartID != item.ISmartID {
+			oldISmartID = item.ISmartID
+			if err := s.renameISmartID(tx, &item, payload.ISmartID, actor); err != nil {
+				return err
+			}
+		}
+		if payload.Name != "" {
+			item.Name = payload.Name
+		}
+		if payload.Remark != "" {
+			item.Remark = payload.Remark
+		}
+		// site_id 为 0 时移出站点
+		if payload.SiteID != nil {
+			item.SiteID = payload.SiteID
+			if *payload.SiteID == 0 {
+				item.SiteID = nil
+			}
+			if err := checkSiteGroup(tx, item.SiteID); err != nil {
+				return err
+			}
+		}
+		return tx.Save(&item).Error
+	})
+
+	if oldISmartID != "" || errors.Is(err, ErrISmartIDConflict) {
+		entry := models.AuditLog{
+			Action:     "building.rename",
+			TargetType: "building",
+			TargetID:   id,
+			Detail:     map[string]interface{}{"from": oldISmartID, "to": payload.ISmartID},
+			Success:    err == nil,
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		} else {
+			entry.Detail["alias_expires_at"] = time.Now().Add(s.aliasGrace)
+		}
+		s.auditService.Record(ctx, actor, entry)
+	}
+	if err != nil {
 		return nil, err
 	}
+	return &item, nil
+}
 
-	if payload.ISmartID != "" {
-		item.ISmartID = payload.ISmartID
+// renameISmartID 在事务内变更建筑的 ismartId：
+// 新值不能被其他建筑（包括已删除的）或其他建筑未过期的别名占用；旧值记录为别名，
+// 未结束的升级活动中按旧值筛选的条件同步更新（设备与 NVR 通过 building_id 关联，无需更新）
+func (s *BuildingService) renameISmartID(tx *gorm.DB, item *models.Building, newISmartID string, actor Actor) error {
+	now := time.Now()
+	var count int64
+	if err := tx.Unscoped().Model(&models.Building{}).
+		Where("ismart_id = ? AND id <> ?", newISmartID, item.ID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %s", ErrISmartIDConflict, newISmartID)
 	}
-	if payload.Name != "" {
-		item.Name = payload.Name
+	if err := tx.Model(&models.BuildingAlias{}).
+		Where("ismart_id = ? AND building_id <> ? AND expires_at > ?", newISmartID, item.ID, now).
+		Count(&count).Error; err != nil {
+		return err
 	}
-	if payload.Remark != "" {
-		item.Remark = payload.Remark
+	if count > 0 {
+		return fmt.Errorf("%w: %s is an alias of another building", ErrISmartIDConflict, newISmartID)
 	}
 
-	if err := s.db.WithContext(ctx).Save(&item).Error; err != nil {
-		return nil, err
+	// 改回本建筑以前使用过的值时，该值不再作为别名
+	if err := tx.Where("ismart_id = ? AND building_id = ?", newISmartID, item.ID).
+		Delete(&models.BuildingAlias{}).Error; err != nil {
+		return err
 	}
-	return &item, nil
+	if s.aliasGrace > 0 && item.ISmartID != "" {
+		if err := tx.Create(&models.BuildingAlias{
+			BuildingID: item.ID,
+			ISmartID:   item.ISmartID,
+			ExpiresAt:  now.Add(s.aliasGrace),
+			Actor:      actor.Username,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	var campaigns []models.RolloutCampaign
+	if err := tx.Where("status IN ?", []string{models.RolloutStatusRunning, models.RolloutStatusPaused}).
+		Find(&campaigns).Error; err != nil {
+		return err
+	}
+	for _, campaign := range campaigns {
+		idx := slices.Index(campaign.Selector.ISmartIDs, item.ISmartID)
+		if idx < 0 {
+			continue
+		}
+		campaign.Selector.ISmartIDs[idx] = newISmartID
+		if err := tx.Model(&campaign).Select("selector").Updates(&models.RolloutCampaign{Selector: campaign.Selector}).Error; err != nil {
+			return err
+		}
+	}
+
+	item.ISmartID = newISmartID
+	return nil
 }
 
-// 4. Delete 删除建筑
-func (s *BuildingService) Delete(ctx context.Context, id int64) error {
-	return s.db.WithContext(ctx).Delete(&models.Building{}, id).Error
+// 4. Delete 删除建筑：仍有关联的 OrangePi 或 NVR 时返回 ErrBuildingInUse 及关联列表；
+// cascade 为 unbind 时解绑、为 delete 时删除这些设备，与删除建筑在同一事务内完成
+func (s *BuildingService) Delete(ctx context.Context, id int64, cascade string, actor Actor) (*BuildingDeleteResult, error) {
+	if cascade != "" && cascade != BuildingCascadeUnbind && cascade != BuildingCascadeDelete {
+		return nil, fmt.Errorf("%w: %s", ErrCascadeInvalid, cascade)
+	}
+
+	result := &BuildingDeleteResult{BuildingID: id, Cascade: cascade}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var building models.Building
+		if err := tx.First(&building, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBuildingNotFound
+			}
+			return err
+		}
+
+		var devices []models.OrangePi
+		if err := tx.Where("building_id = ?", id).Order("id asc").Find(&devices).Error; err != nil {
+			return err
+		}
+		var nvrs []models.NVR
+		if err := tx.Where("building_id = ?", id).Order("id asc").Find(&nvrs).Error; err != nil {
+			return err
+		}
+		result.OrangePis = make([]BuildingDependent, 0, len(devices))
+		for _, device := range devices {
+			result.OrangePis = append(result.OrangePis, BuildingDependent{ID: device.ID, Name: device.Name})
+		}
+		result.NVRs = make([]BuildingDependent, 0, len(nvrs))
+		for _, nvr := range nvrs {
+			result.NVRs = append(result.NVRs, BuildingDependent{ID: nvr.ID, Name: nvr.Name})
+		}
+		if (len(devices) > 0 || len(nvrs) > 0) && cascade == "" {
+			return fmt.Errorf("%w: %d orangepis, %d nvrs", ErrBuildingInUse, len(devices), len(nvrs))
+		}
+
+		// 两种级联方式都记录绑定事件，事件需在建筑删除前写入以取得其 ismartId
+		reason := "building deleted"
+		if cascade == BuildingCascadeDelete {
+			reason = "building deleted (cascade delete)"
+		}
+		for _, device := range devices {
+			if err := recordOrangePiBinding(tx, device.ID, &building.ID, nil, reason, actor.Username); err != nil {
+				return err
+			}
+			if cascade == BuildingCascadeDelete {
+				if err := tx.Delete(&device).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if err := tx.Model(&device).Update("building_id", nil).Error; err != nil {
+				return err
+			}
+		}
+		for _, nvr := range nvrs {
+			if err := recordNVRBinding(tx, nvr.ID, id, 0, reason, actor.Username); err != nil {
+				return err
+			}
+			if cascade == BuildingCascadeDelete {
+				if err := tx.Delete(&nvr).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if err := tx.Model(&nvr).Update("building_id", 0).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("building_id = ?", id).Delete(&models.BuildingAlias{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&building).Error; err != nil {
+			return err
+		}
+		result.Deleted = true
+		return nil
+	})
+	if errors.Is(err, ErrBuildingNotFound) {
+		return nil, err
+	}
+
+	entry := models.AuditLog{
+		Action:     "building.delete",
+		TargetType: "building",
+		TargetID:   id,
+		Detail:     map[string]interface{}{"cascade": cascade, "orangepis": result.OrangePis, "nvrs": result.NVRs},
+		Success:    err == nil,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	s.auditService.Record(ctx, actor, entry)
+	if errors.Is(err, ErrBuildingInUse) {
+		return result, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
-// 5. BindOrangePi 绑定OrangePi到建筑
-func (s *BuildingService) BindOrangePi(ctx context.Context, buildingId int64, orangePiId int64) error {
+// 5. BindOrangePi 绑定OrangePi到建筑（记录绑定变更）
+func (s *BuildingService) BindOrangePi(ctx context.Context, buildingId int64, orangePiId int64, reason string, actor Actor) error {
 	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// 检查建筑是否存在
 		var building models.Building
@@ -109,7 +387,7 @@ func (s *BuildingService) BindOrangePi(ctx context.Context, buildingId int64, or
 
 		// 检查建筑是否有有效的 ISmartID
 		if building.ISmartID == "" {
-			return errors.New("building has no ismart_id")
+			return ErrBuildingNoISmartID
 		}
 
 		// 检查OrangePi是否存在
@@ -122,23 +400,26 @@ func (s *BuildingService) BindOrangePi(ctx context.Context, buildingId int64, or
 		}
 
 		// 检查OrangePi是否已绑定到其他建筑
-		if orangePi.ISmartID != "" && orangePi.ISmartID != building.ISmartID {
+		if orangePi.BuildingID != nil && *orangePi.BuildingID != building.ID {
 			return ErrAlreadyBound
 		}
 
 		// 如果已经绑定到当前建筑，直接返回成功
-		if orangePi.ISmartID == building.ISmartID {
+		if orangePi.BuildingID != nil {
 			return nil
 		}
 
-		// 更新OrangePi的ISmartID
-		orangePi.ISmartID = building.ISmartID
-		return tx.Save(&orangePi).Error
+		// 更新OrangePi的BuildingID
+		orangePi.BuildingID = &building.ID
+		if err := tx.Save(&orangePi).Error; err != nil {
+			return err
+		}
+		return recordOrangePiBinding(tx, orangePi.ID, nil, orangePi.BuildingID, reason, actor.Username)
 	})
 }
 
-// 6. UnbindOrangePi 解绑OrangePi
-func (s *BuildingService) UnbindOrangePi(ctx context.Context, orangePiId int64) error {
+// 6. UnbindOrangePi 解绑OrangePi（记录绑定变更）
+func (s *BuildingService) UnbindOrangePi(ctx context.Context, orangePiId int64, reason string, actor Actor) error {
 	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// 检查OrangePi是否存在
 		var orangePi models.OrangePi
@@ -150,33 +431,92 @@ func (s *BuildingService) UnbindOrangePi(ctx context.Context, orangePiId int64)
 		}
 
 		// 检查OrangePi是否已绑定
-		if orangePi.ISmartID == "" {
+		if orangePi.BuildingID == nil {
 			return ErrNotBound
 		}
 
-		// 清空ISmartID（设为空字符串）
-		orangePi.ISmartID = ""
-		return tx.Save(&orangePi).Error
+		// 清空BuildingID（设为NULL）
+		previous := orangePi.BuildingID
+		orangePi.BuildingID = nil
+		if err := tx.Save(&orangePi).Error; err != nil {
+			return err
+		}
+		return recordOrangePiBinding(tx, orangePi.ID, previous, nil, reason, actor.Username)
 	})
 }
 
-// 7. UpdateBind 更新绑定关系
-func (s *BuildingService) UpdateBind(ctx context.Context, orangePiId int64, newBuildingId int64) error {
-	// 检查新建筑是否存在
-	var newBuilding models.Building
-	if err := s.db.WithContext(ctx).First(&newBuilding, newBuildingId).Error; err != nil {
-		return err
+// 7. MoveOrangePi 将已绑定的OrangePi迁移到其他建筑（记录绑定变更）
+// RequireHealthy 时设备必须处于维护状态或健康检查通过，避免迁移无法确认状态的设备；
+// 健康检查在事务外进行，保存时要求生命周期状态与端口仍与检查时一致
+func (s *BuildingService) MoveOrangePi(ctx context.Context, orangePiId int64, newBuildingId int64, opts MoveOptions, actor Actor) error {
+	var checked *models.OrangePi
+	if opts.RequireHealthy {
+		var orangePi models.OrangePi
+		if err := s.db.WithContext(ctx).First(&orangePi, orangePiId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrangePiNotFound
+			}
+			return err
+		}
+		checked = &orangePi
+		if orangePi.LifecycleState != models.LifecycleMaintenance {
+			status, err := s.orangePiService.fetchHealth(ctx, orangePi.ICCTVAuthServiceRemotePort, moveHealthTimeout)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrDeviceNotMovable, err)
+			}
+			if status.Status != "healthy" {
+				return fmt.Errorf("%w: status %s", ErrDeviceNotMovable, status.Status)
+			}
+		}
 	}
 
-	// 检查OrangePi是否存在
-	var orangePi models.OrangePi
-	if err := s.db.WithContext(ctx).First(&orangePi, orangePiId).Error; err != nil {
-		return err
-	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 检查新建筑是否存在且有有效的 ISmartID
+		var building models.Building
+		if err := tx.First(&building, newBuildingId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBuildingNotFound
+			}
+			return err
+		}
+		if building.ISmartID == "" {
+			return ErrBuildingNoISmartID
+		}
+
+		// 检查OrangePi是否存在且已绑定（未绑定的设备使用绑定接口）
+		var orangePi models.OrangePi
+		if err := tx.First(&orangePi, orangePiId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrangePiNotFound
+			}
+			return err
+		}
+		if orangePi.BuildingID == nil {
+			return ErrNotBound
+		}
+
+		// 已经绑定到目标建筑，直接返回成功
+		if *orangePi.BuildingID == building.ID {
+			return nil
+		}
 
-	// 更新OrangePi的ISmartID
-	orangePi.ISmartID = newBuilding.ISmartID
-	return s.db.WithContext(ctx).Save(&orangePi).Error
+		// 条件更新：设备在健康检查后变更了状态或端口时，检查结果已不能代表当前设备
+		previous := orangePi.BuildingID
+		update := tx.Model(&models.OrangePi{}).Where("id = ? AND building_id = ?", orangePi.ID, *previous)
+		if checked != nil {
+			update = update.Where("lifecycle_state = ? AND icctv_auth_service_remote_port = ? AND ssh_remote_port = ?",
+				checked.LifecycleState, checked.ICCTVAuthServiceRemotePort, checked.SSHRemotePort)
+		}
+		result := update.Update("building_id", building.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: orangepi changed during the health check, retry", ErrDeviceNotMovable)
+		}
+		orangePi.BuildingID = &building.ID
+		return recordOrangePiBinding(tx, orangePi.ID, previous, orangePi.BuildingID, opts.Reason, actor.Username)
+	})
 }
 
 // 8. GetOrangePisByBuildingID 查询Building关联的所有OrangePi
@@ -188,8 +528,8 @@ func (s *BuildingService) GetOrangePisByBuildingID(ctx context.Context, building
 	return building.OrangePis, nil
 }
 
-// 9. BindNVR 绑定NVR到建筑
-func (s *BuildingService) BindNVR(ctx context.Context, buildingId int64, nvrId int64) error {
+// 9. BindNVR 绑定NVR到建筑（记录绑定变更）
+func (s *BuildingService) BindNVR(ctx context.Context, buildingId int64, nvrId int64, reason string, actor Actor) error {
 	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// 检查建筑是否存在
 		var building models.Building
@@ -221,12 +561,15 @@ func (s *BuildingService) BindNVR(ctx context.Context, buildingId int64, nvrId i
 
 		// 更新NVR的BuildingID
 		nvr.BuildingID = buildingId
-		return tx.Save(&nvr).Error
+		if err := tx.Save(&nvr).Error; err != nil {
+			return err
+		}
+		return recordNVRBinding(tx, nvr.ID, 0, buildingId, reason, actor.Username)
 	})
 }
 
-// 10. UnbindNVR 解绑NVR
-func (s *BuildingService) UnbindNVR(ctx context.Context, nvrId int64) error {
+// 10. UnbindNVR 解绑NVR（记录绑定变更）
+func (s *BuildingService) UnbindNVR(ctx context.Context, nvrId int64, reason string, actor Actor) error {
 	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// 检查NVR是否存在
 		var nvr models.NVR
@@ -243,8 +586,12 @@ func (s *BuildingService) UnbindNVR(ctx context.Context, nvrId int64) error {
 		}
 
 		// 清空BuildingID（设为0）
+		previous := nvr.BuildingID
 		nvr.BuildingID = 0
-		return tx.Save(&nvr).Error
+		if err := tx.Save(&nvr).Error; err != nil {
+			return err
+		}
+		return recordNVRBinding(tx, nvr.ID, previous, 0, reason, actor.Username)
 	})
 }
 
@@ -256,3 +603,85 @@ func (s *BuildingService) GetNVRsByBuildingID(ctx context.Context, buildingId in
 	}
 	return building.NVRs, nil
 }
+
+// 12. Get 查询建筑详情（含关联的 OrangePi 与 NVR）
+func (s *BuildingService) Get(ctx context.Context, id int64) (*models.Building, error) {
+	var building models.Building
+	if err := s.db.WithContext(ctx).Preload("OrangePis").Preload("NVRs", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id asc")
+	}).First(&building, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBuildingNotFound
+		}
+		return nil, err
+	}
+	return &building, nil
+}
+
+// findBuildingByISmartID 按 ismartId 查找建筑，找不到时按宽限期内的旧值别名查找
+func findBuildingByISmartID(ctx context.Context, db *gorm.DB, ismartID string) (*models.Building, error) {
+	var building models.Building
+	err := db.WithContext(ctx).Where("ismart_id = ?", ismartID).First(&building).Error
+	if err == nil {
+		return &building, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var alias models.BuildingAlias
+	if err := db.WithContext(ctx).
+		Where("ismart_id = ? AND expires_at > ?", ismartID, time.Now()).
+		Order("expires_at desc").
+		First(&alias).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBuildingNotFound
+		}
+		return nil, err
+	}
+	if err := db.WithContext(ctx).First(&building, alias.BuildingID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBuildingNotFound
+		}
+		return nil, err
+	}
+	return &building, nil
+}
+
+// 13. MoveNVR 将已绑定的NVR迁移到其他建筑（记录绑定变更）
+func (s *BuildingService) MoveNVR(ctx context.Context, nvrId int64, newBuildingId int64, reason string, actor Actor) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 检查新建筑是否存在
+		var building models.Building
+		if err := tx.First(&building, newBuildingId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBuildingNotFound
+			}
+			return err
+		}
+
+		// 检查NVR是否存在且已绑定（未绑定的NVR使用绑定接口）
+		var nvr models.NVR
+		if err := tx.First(&nvr, nvrId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNVRNotFound
+			}
+			return err
+		}
+		if nvr.BuildingID == 0 {
+			return ErrNotBound
+		}
+
+		// 已经绑定到目标建筑，直接返回成功
+		if nvr.BuildingID == newBuildingId {
+			return nil
+		}
+
+		previous := nvr.BuildingID
+		nvr.BuildingID = newBuildingId
+		if err := tx.Save(&nvr).Error; err != nil {
+			return err
+		}
+		return recordNVRBinding(tx, nvr.ID, previous, newBuildingId, reason, actor.Username)
+	})
+}
@@ -0,0 +1,367 @@
+package services
+
+// ConfigSnapshotService Methods:
+//0. NewConfigSnapshotService(db *gorm.DB, orangePiService *OrangePiService, provisioningService *ProvisioningService) -> 初始化配置快照服务（从环境变量读取采集周期）
+//1. Start(ctx context.Context) -> 启动定时采集
+//2. Capture(ctx context.Context, id int64, trigger string, actor string) -> 立即采集设备配置文件
+//3. List(ctx context.Context, id int64, fileName string) -> 查询配置版本（不含内容）
+//4. Get(ctx context.Context, id int64, snapshotID int64) -> 查询单个版本（含内容）
+//5. Diff(ctx context.Context, id int64, fileName, from, to string) -> 比较两个版本或版本与期望配置
+//6. RegisterJobHandlers(jobs *JobService) -> 注册批量采集异步任务
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"icctv-http-service/models"
+
+	"gorm.io/gorm"
+)
+
+// 错误定义
+var (
+	ErrSnapshotNotFound   = errors.New("config snapshot not found")
+	ErrConfigFileUnknown  = errors.New("unknown config file")
+	ErrInvalidSnapshotRef = errors.New("invalid snapshot reference")
+)
+
+// SnapshotRefDesired 表示由部署包模板渲染的期望配置
+const SnapshotRefDesired = "desired"
+
+// configSnapshotFiles 需要采集的配置文件（文件名同时对应同名部署包模板）
+var configSnapshotFiles = []string{"frpc.toml", "mediamtx.yml"}
+
+// ConfigSnapshotServiceInterface 定义配置快照能力
+type ConfigSnapshotServiceInterface interface {
+	Start(ctx context.Context)                                                                          //1.定时采集
+	Capture(ctx context.Context, id int64, trigger string, actor string) ([]ConfigCaptureResult, error) //2.立即采集
+	List(ctx context.Context, id int64, fileName string) ([]models.ConfigSnapshot, error)               //3.版本列表
+	Get(ctx context.Context, id int64, snapshotID int64) (*models.ConfigSnapshot, error)                //4.版本详情
+	Diff(ctx context.Context, id int64, fileName, from, to string) (*ConfigDiff, error)                 //5.版本对比
+	RegisterJobHandlers(jobs *JobService)                                                               //6.注册异步任务
+}
+
+// ConfigCaptureResult 单个文件的采集结果
+type ConfigCaptureResult struct {
+	FileName string                 `json:"file_name"`
+	Changed  bool                   `json:"changed"` // 是否产生了新版本
+	Snapshot *models.ConfigSnapshot `json:"snapshot,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+// ConfigDiffSide 对比的一侧
+type ConfigDiffSide struct {
+	Ref        string     `json:"ref"` // 版本ID或 desired
+	Hash       string     `json:"hash"`
+	CapturedAt *time.Time `json:"captured_at,omitempty"`
+}
+
+// ConfigDiff 对比结果
+type ConfigDiff struct {
+	FileName  string         `json:"file_name"`
+	From      ConfigDiffSide `json:"from"`
+	To        ConfigDiffSide `json:"to"`
+	Identical bool           `json:"identical"`
+	Diff      string         `json:"diff"` // unified diff
+}
+
+// ConfigSnapshotService 采集 OrangePi 上的实际配置文件并按内容哈希保存版本
+type ConfigSnapshotService struct {
+	db                  *gorm.DB
+	orangePiService     *OrangePiService
+	provisioningService *ProvisioningService
+	interval            time.Duration
+}
+
+// 0. NewConfigSnapshotService 构造函数，CONFIG_SNAPSHOT_INTERVAL_HOURS 控制定时采集周期（默认 24，0 表示关闭）
+func NewConfigSnapshotService(db *gorm.DB, orangePiService *OrangePiService, provisioningService *ProvisioningService) *ConfigSnapshotService {
+	hours := 24
+	if val := os.Getenv("CONFIG_SNAPSHOT_INTERVAL_HOURS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			hours = parsed
+		} else {
+			log.Printf("Warning: invalid CONFIG_SNAPSHOT_INTERVAL_HOURS=%q, using %d", val, hours)
+		}
+	}
+
+	return &ConfigSnapshotService{
+		db:                  db,
+		orangePiService:     orangePiService,
+		provisioningService: provisioningService,
+		interval:            time.Duration(hours) * time.Hour,
+	}
+}
+
+// 1. Start 启动定时采集（所有已部署设备），ctx 结束时停止
+func (s *ConfigSnapshotService) Start(ctx context.Context) {
+	if s.interval <= 0 {
+		log.Println("config snapshots disabled (CONFIG_SNAPSHOT_INTERVAL_HOURS=0)")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.captureAll(ctx)
+			}
+		}
+	}()
+}
+
+// 2. Capture 立即采集设备的全部配置文件，单个文件失败不影响其他文件
+func (s *ConfigSnapshotService) Capture(ctx context.Context, id int64, trigger string, actor string) ([]ConfigCaptureResult, error) {
+	var device models.OrangePi
+	if err := s.db.WithContext(ctx).First(&device, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrangePiNotFound
+		}
+		return nil, err
+	}
+	// 端口上应答的不是该设备时采集到的是别的设备的配置
+	if device.DeviceIDMismatch {
+		return nil, ErrDeviceIDMismatch
+	}
+
+	results := make([]ConfigCaptureResult, 0, len(configSnapshotFiles))
+	for _, name := range configSnapshotFiles {
+		result := ConfigCaptureResult{FileName: name}
+		content, err := s.fetchFile(ctx, device, name)
+		if err != nil {
+			result.Error = err.Error()
+		} else if snapshot, changed, err := s.save(ctx, device.ID, name, content, trigger, actor); err != nil {
+			result.Error = err.Error()
+		} else {
+			snapshot.Content = ""
+			result.Snapshot, result.Changed = snapshot, changed
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// 3. List 查询设备的配置版本（新的在前，不含内容），fileName 为空时返回全部文件
+func (s *ConfigSnapshotService) List(ctx context.Context, id int64, fileName string) ([]models.ConfigSnapshot, error) {
+	var items []models.ConfigSnapshot
+	tx := s.db.WithContext(ctx).Model(&models.ConfigSnapshot{}).
+		Omit("content").
+		Where("orangepi_id = ?", id)
+	if fileName != "" {
+		tx = tx.Where("file_name = ?", fileName)
+	}
+	if err := tx.Order("id desc").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// 4. Get 查询单个版本（含内容）
+func (s *ConfigSnapshotService) Get(ctx context.Context, id int64, snapshotID int64) (*models.ConfigSnapshot, error) {
+	var snapshot models.ConfigSnapshot
+	if err := s.db.WithContext(ctx).
+		Where("id = ? AND orangepi_id = ?", snapshotID, id).
+		First(&snapshot).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSnapshotNotFound
+		}
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// 5. Diff 比较两个版本；from/to 为版本ID、latest（最新版本）或 desired（按部署包模板渲染的期望配置）
+func (s *ConfigSnapshotService) Diff(ctx context.Context, id int64, fileName, from, to string) (*ConfigDiff, error) {
+	if !isConfigSnapshotFile(fileName) {
+		return nil, fmt.Errorf("%w: %q", ErrConfigFileUnknown, fileName)
+	}
+
+	fromSide, fromContent, err := s.resolveRef(ctx, id, fileName, from)
+	if err != nil {
+		return nil, err
+	}
+	toSide, toContent, err := s.resolveRef(ctx, id, fileName, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := unifiedDiff(fileName+"@"+fromSide.Ref, fileName+"@"+toSide.Ref, fromContent, toContent)
+	return &ConfigDiff{
+		FileName:  fileName,
+		From:      fromSide,
+		To:        toSide,
+		Identical: diff == "",
+		Diff:      diff,
+	}, nil
+}
+
+// 6. RegisterJobHandlers 注册 config_snapshot 异步任务（批量采集）
+func (s *ConfigSnapshotService) RegisterJobHandlers(jobs *JobService) {
+	jobs.RegisterHandler(models.JobTypeConfigSnapshot, func(ctx context.Context, task JobTask) (interface{}, error) {
+		results, err := s.Capture(ctx, task.OrangePiID, models.ConfigSnapshotTriggerJob, task.CreatedBy)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range results {
+			if r.Error != "" {
+				return results, fmt.Errorf("%s: %s", r.FileName, r.Error)
+			}
+		}
+		return results, nil
+	})
+}
+
+// captureAll 定时采集所有已部署设备（维护、故障等状态不巡检）
+func (s *ConfigSnapshotService) captureAll(ctx context.Context) {
+	var ids []int64
+	if err := s.db.WithContext(ctx).Model(&models.OrangePi{}).
+		Where("lifecycle_state IN ?", models.LifecycleServingStates).Pluck("id", &ids).Error; err != nil {
+		log.Printf("config snapshots: failed to list devices: %v", err)
+		return
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed int
+	)
+	sem := make(chan struct{}, reconcileConcurrency)
+	for _, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(id int64) {
+			defer func() { <-sem; wg.Done() }()
+			results, err := s.Capture(ctx, id, models.ConfigSnapshotTriggerScheduled, "")
+			ok := err == nil
+			for _, r := range results {
+				ok = ok && r.Error == ""
+			}
+			if !ok {
+				mu.Lock()
+				failed++
+				mu.Unlock()
+			}
+		}(id)
+	}
+	wg.Wait()
+	log.Printf("config snapshots: captured %d devices, %d with errors", len(ids), failed)
+}
+
+// fetchFile 通过 agent 读取配置文件
+func (s *ConfigSnapshotService) fetchFile(ctx context.Context, device models.OrangePi, name string) (string, error) {
+	var out struct {
+		Name    string `json:"name"`
+		Content string `json:"content"`
+		Error   string `json:"error"`
+	}
+	path := "/api/device/config/files/" + url.PathEscape(name)
+	if err := s.orangePiService.callRemote(ctx, device.ICCTVAuthServiceRemotePort, http.MethodGet, path, nil, 15*time.Second, &out); err != nil {
+		return "", err
+	}
+	if out.Error != "" {
+		return "", errors.New(out.Error)
+	}
+	return out.Content, nil
+}
+
+// save 内容与最新版本相同时只刷新 LastSeenAt，否则新建版本
+func (s *ConfigSnapshotService) save(ctx context.Context, id int64, name, content, trigger, actor string) (*models.ConfigSnapshot, bool, error) {
+	sum := sha256.Sum256([]byte(content))
+	hash := hex.EncodeToString(sum[:])
+	now := time.Now()
+
+	var snapshot models.ConfigSnapshot
+	changed := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("orangepi_id = ? AND file_name = ?", id, name).
+			Order("id desc").First(&snapshot).Error
+		if err == nil && snapshot.Hash == hash {
+			snapshot.LastSeenAt = now
+			return tx.Model(&snapshot).Update("last_seen_at", now).Error
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		changed = true
+		snapshot = models.ConfigSnapshot{
+			OrangePiID: id,
+			FileName:   name,
+			Hash:       hash,
+			Size:       len(content),
+			Content:    content,
+			Trigger:    trigger,
+			CapturedBy: actor,
+			LastSeenAt: now,
+		}
+		return tx.Create(&snapshot).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &snapshot, changed, nil
+}
+
+// resolveRef 解析对比对象
+func (s *ConfigSnapshotService) resolveRef(ctx context.Context, id int64, fileName, ref string) (ConfigDiffSide, string, error) {
+	switch ref {
+	case SnapshotRefDesired:
+		content, err := s.provisioningService.Render(ctx, id, fileName)
+		if err != nil {
+			return ConfigDiffSide{}, "", err
+		}
+		sum := sha256.Sum256([]byte(content))
+		return ConfigDiffSide{Ref: ref, Hash: hex.EncodeToString(sum[:])}, content, nil
+	case "", "latest":
+		var snapshot models.ConfigSnapshot
+		if err := s.db.WithContext(ctx).
+			Where("orangepi_id = ? AND file_name = ?", id, fileName).
+			Order("id desc").First(&snapshot).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ConfigDiffSide{}, "", ErrSnapshotNotFound
+			}
+			return ConfigDiffSide{}, "", err
+		}
+		return snapshotSide(snapshot), snapshot.Content, nil
+	}
+
+	snapshotID, err := strconv.ParseInt(ref, 10, 64)
+	if err != nil {
+		return ConfigDiffSide{}, "", fmt.Errorf("%w: %q (expected snapshot id, latest or desired)", ErrInvalidSnapshotRef, ref)
+	}
+	snapshot, err := s.Get(ctx, id, snapshotID)
+	if err != nil {
+		return ConfigDiffSide{}, "", err
+	}
+	if snapshot.FileName != fileName {
+		return ConfigDiffSide{}, "", fmt.Errorf("%w: snapshot %d is %s", ErrInvalidSnapshotRef, snapshot.ID, snapshot.FileName)
+	}
+	return snapshotSide(*snapshot), snapshot.Content, nil
+}
+
+func snapshotSide(snapshot models.ConfigSnapshot) ConfigDiffSide {
+	capturedAt := snapshot.CreatedAt
+	return ConfigDiffSide{Ref: strconv.FormatInt(snapshot.ID, 10), Hash: snapshot.Hash, CapturedAt: &capturedAt}
+}
+
+func isConfigSnapshotFile(name string) bool {
+	for _, f := range configSnapshotFiles {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
@@ -8,6 +8,8 @@ import (
 	"context"
 	"time"
 
+	"icctv-http-service/models"
+
 	"gorm.io/gorm"
 )
 
@@ -23,10 +25,11 @@ type DeviceService struct {
 
 // DeviceInfoSummary 设备概览
 type DeviceInfoSummary struct {
-	TotalDevices    int       `json:"totalDevices"`
-	ActiveDevices   int       `json:"activeDevices"`
-	BuildingBounded int       `json:"buildingBounded"`
-	LastSync        time.Time `json:"lastSync"`
+	TotalDevices    int            `json:"totalDevices"`
+	ActiveDevices   int            `json:"activeDevices"`   // 已部署(deployed)设备数量
+	LifecycleStates map[string]int `json:"lifecycleStates"` // 各生命周期状态的设备数量
+	BuildingBounded int            `json:"buildingBounded"`
+	LastSync        time.Time      `json:"lastSync"`
 }
 
 //0. NewDeviceService 构造函数
@@ -45,9 +48,26 @@ func (s *DeviceService) Summary(ctx context.Context) (DeviceInfoSummary, error)
 	if err := s.db.WithContext(ctx).Table("orangepis").Count(&totalDevices).Error; err != nil {
 		return DeviceInfoSummary{}, err
 	}
-	if err := s.db.WithContext(ctx).Table("orangepis").Where("is_active = ?", true).Count(&activeDevices).Error; err != nil {
+	if err := s.db.WithContext(ctx).Table("orangepis").Where("lifecycle_state IN ?", models.LifecycleServingStates).Count(&activeDevices).Error; err != nil {
+		return DeviceInfoSummary{}, err
+	}
+	var stateCounts []struct {
+		LifecycleState string
+		Count          int
+	}
+	if err := s.db.WithContext(ctx).Table("orangepis").
+		Select("lifecycle_state, COUNT(*) AS count").
+		Group("lifecycle_state").
+		Scan(&stateCounts).Error; err != nil {
 		return DeviceInfoSummary{}, err
 	}
+	lifecycleStates := make(map[string]int, len(models.LifecycleTransitions))
+	for state := range models.LifecycleTransitions {
+		lifecycleStates[state] = 0
+	}
+	for _, row := range stateCounts {
+		lifecycleStates[row.LifecycleState] = row.Count
+	}
 	if err := s.db.WithContext(ctx).Table("buildings").Count(&buildingBounded).Error; err != nil {
 		return DeviceInfoSummary{}, err
 	}
@@ -55,6 +75,7 @@ func (s *DeviceService) Summary(ctx context.Context) (DeviceInfoSummary, error)
 	return DeviceInfoSummary{
 		TotalDevices:    int(totalDevices),
 		ActiveDevices:   int(activeDevices),
+		LifecycleStates: lifecycleStates,
 		BuildingBounded: int(buildingBounded),
 		LastSync:        time.Now(),
 	}, nil
@@ -0,0 +1,404 @@
+package services
+
+// ISmartSyncService Methods:
+//0. NewISmartSyncService(db *gorm.DB, auditService *AuditService) -> 初始化 iSmart 楼栋同步（从环境变量读取上游地址、凭证与同步周期）
+//1. Start(ctx context.Context) -> 启动定时同步
+//2. Sync(ctx context.Context, dryRun bool, trigger string, actor Actor) -> 立即同步并返回差异（dryRun 时只计算差异）
+//3. Status(ctx context.Context) -> 同步配置与最近一次同步记录
+//4. Runs(ctx context.Context, limit int) -> 查询同步记录
+//5. GetRun(ctx context.Context, id int64) -> 查询同步记录详情
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"icctv-http-service/models"
+
+	"gorm.io/gorm"
+)
+
+// 错误定义
+var (
+	ErrISmartNotConfigured   = errors.New("ismart sync is not configured (ISMART_BASE_URL)")
+	ErrISmartSyncRunning     = errors.New("ismart sync already running")
+	ErrISmartUpstream        = errors.New("ismart upstream error")
+	ErrISmartSyncRunNotFound = errors.New("ismart sync run not found")
+)
+
+// ismartRequestTimeout 请求 iSmart 楼栋列表的超时时间
+const ismartRequestTimeout = 30 * time.Second
+
+// defaultISmartSyncIntervalMinutes 默认定时同步周期（分钟）
+const defaultISmartSyncIntervalMinutes = 60
+
+// ISmartSyncServiceInterface 定义 iSmart 楼栋同步能力
+type ISmartSyncServiceInterface interface {
+	Start(ctx context.Context)                                                                         //1.定时同步
+	Sync(ctx context.Context, dryRun bool, trigger string, actor Actor) (*models.ISmartSyncRun, error) //2.立即同步
+	Status(ctx context.Context) (*ISmartSyncStatus, error)                                             //3.同步状态
+	Runs(ctx context.Context, limit int) ([]models.ISmartSyncRun, error)                               //4.同步记录
+	GetRun(ctx context.Context, id int64) (*models.ISmartSyncRun, error)                               //5.同步记录详情
+}
+
+// ISmartSyncStatus 同步配置（不含凭证）与最近一次同步记录
+type ISmartSyncStatus struct {
+	Configured      bool                  `json:"configured"`
+	BaseURL         string                `json:"base_url"`
+	BuildingsPath   string                `json:"buildings_path"`
+	IntervalMinutes int                   `json:"interval_minutes"`
+	LastRun         *models.ISmartSyncRun `json:"last_run"`
+}
+
+// ismartID 上游楼栋ID，兼容字符串与数字
+type ismartID string
+
+// UnmarshalJSON 数字ID按原样转为字符串
+func (id *ismartID) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*id = ismartID(strings.TrimSpace(text))
+		return nil
+	}
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return fmt.Errorf("invalid building id %s", data)
+	}
+	*id = ismartID(number.String())
+	return nil
+}
+
+// ismartBuilding iSmart 楼栋列表中的一项
+type ismartBuilding struct {
+	ID   ismartID `json:"id"`
+	Name string   `json:"name"`
+}
+
+// ISmartSyncService 从 iSmart 拉取楼栋列表，按 ismartId 创建或更新建筑，上游已不存在的建筑只做标记
+type ISmartSyncService struct {
+	db            *gorm.DB
+	auditService  *AuditService
+	baseURL       string
+	buildingsPath string
+	token         string
+	username      string
+	password      string
+	interval      time.Duration
+	running       sync.Mutex // 同一时间只执行一次同步
+}
+
+// 0. NewISmartSyncService 构造函数
+// ISMART_BASE_URL 为空时不启用；ISMART_BUILDINGS_PATH 为楼栋列表路径（默认 /api/buildings）；
+// 凭证使用 ISMART_TOKEN（Bearer）或 ISMART_USERNAME/ISMART_PASSWORD（Basic）；
+// ISMART_SYNC_INTERVAL_MINUTES 控制定时同步周期（默认 60，0 表示只手动同步）
+func NewISmartSyncService(db *gorm.DB, auditService *AuditService) *ISmartSyncService {
+	minutes := defaultISmartSyncIntervalMinutes
+	if val := os.Getenv("ISMART_SYNC_INTERVAL_MINUTES"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			minutes = parsed
+		} else {
+			log.Printf("Warning: invalid ISMART_SYNC_INTERVAL_MINUTES=%q, using %d", val, minutes)
+		}
+	}
+
+	return &ISmartSyncService{
+		db:            db,
+		auditService:  auditService,
+		baseURL:       strings.TrimRight(os.Getenv("ISMART_BASE_URL"), "/"),
+		buildingsPath: getenvDefault("ISMART_BUILDINGS_PATH", "/api/buildings"),
+		token:         os.Getenv("ISMART_TOKEN"),
+		username:      os.Getenv("ISMART_USERNAME"),
+		password:      os.Getenv("ISMART_PASSWORD"),
+		interval:      time.Duration(minutes) * time.Minute,
+	}
+}
+
+// 1. Start 启动定时同步，ctx 结束时停止
+func (s *ISmartSyncService) Start(ctx context.Context) {
+	if s.baseURL == "" {
+		log.Println("ismart sync disabled (ISMART_BASE_URL not set)")
+		return
+	}
+	if s.interval <= 0 {
+		log.Println("scheduled ismart sync disabled (ISMART_SYNC_INTERVAL_MINUTES=0)")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				run, err := s.Sync(ctx, false, models.ISmartSyncTriggerSchedule, Actor{Username: "system"})
+				if err != nil {
+					log.Printf("ismart sync: %v", err)
+					continue
+				}
+				log.Printf("ismart sync: %d upstream buildings, %d changes", run.Upstream, len(run.Changes))
+			}
+		}
+	}()
+}
+
+// 2. Sync 立即同步：上游新增的楼栋创建建筑，名称变化的更新名称，本地存在但上游已不存在的建筑标记 ismart_missing_at（不删除），
+// 重新出现的清除标记；ismartId 被回收站中的建筑或其他建筑的别名占用时记为冲突。
+// dryRun 时只计算差异不修改建筑；每次同步（包括失败）都写入同步记录，非 dry-run 同步写入审计日志
+func (s *ISmartSyncService) Sync(ctx context.Context, dryRun bool, trigger string, actor Actor) (*models.ISmartSyncRun, error) {
+	if s.baseURL == "" {
+		return nil, ErrISmartNotConfigured
+	}
+	if !s.running.TryLock() {
+		return nil, ErrISmartSyncRunning
+	}
+	defer s.running.Unlock()
+
+	run := &models.ISmartSyncRun{
+		Trigger:   trigger,
+		Actor:     actor.Username,
+		DryRun:    dryRun,
+		StartedAt: time.Now(),
+		Changes:   []models.ISmartSyncChange{},
+	}
+	err := s.sync(ctx, run)
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Success = err == nil
+	if err != nil {
+		run.Error = err.Error()
+	}
+	if recordErr := s.db.WithContext(context.WithoutCancel(ctx)).Create(run).Error; recordErr != nil {
+		log.Printf("ismart sync: failed to record run: %v", recordErr)
+	}
+
+	if !dryRun {
+		entry := models.AuditLog{
+			Action:     "ismart.sync",
+			TargetType: "ismart_sync_run",
+			TargetID:   run.ID,
+			Detail:     map[string]interface{}{"trigger": trigger, "upstream": run.Upstream, "changes": len(run.Changes)},
+			Success:    err == nil,
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		s.auditService.Record(ctx, actor, entry)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// 3. Status 同步配置与最近一次同步记录
+func (s *ISmartSyncService) Status(ctx context.Context) (*ISmartSyncStatus, error) {
+	status := &ISmartSyncStatus{
+		Configured:      s.baseURL != "",
+		BaseURL:         s.baseURL,
+		BuildingsPath:   s.buildingsPath,
+		IntervalMinutes: int(s.interval / time.Minute),
+	}
+	var runs []models.ISmartSyncRun
+	if err := s.db.WithContext(ctx).Order("id desc").Limit(1).Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	if len(runs) > 0 {
+		status.LastRun = &runs[0]
+	}
+	return status, nil
+}
+
+// 4. Runs 查询同步记录（按时间倒序，默认 20 条，最多 100 条）
+func (s *ISmartSyncService) Runs(ctx context.Context, limit int) ([]models.ISmartSyncRun, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	var items []models.ISmartSyncRun
+	if err := s.db.WithContext(ctx).Order("id desc").Limit(limit).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// 5. GetRun 查询同步记录详情
+func (s *ISmartSyncService) GetRun(ctx context.Context, id int64) (*models.ISmartSyncRun, error) {
+	var item models.ISmartSyncRun
+	if err := s.db.WithContext(ctx).First(&item, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrISmartSyncRunNotFound
+		}
+		return nil, err
+	}
+	return &item, nil
+}
+
+// sync 拉取上游楼栋、计算差异并在一个事务内应用（dry-run 时不应用）
+func (s *ISmartSyncService) sync(ctx context.Context, run *models.ISmartSyncRun) error {
+	upstream, err := s.fetchBuildings(ctx)
+	if err != nil {
+		return err
+	}
+	run.Upstream = len(upstream)
+
+	db := s.db.WithContext(ctx)
+	var buildings []models.Building
+	if err := db.Order("id asc").Find(&buildings).Error; err != nil {
+		return err
+	}
+	if len(upstream) == 0 && len(buildings) > 0 {
+		return fmt.Errorf("%w: upstream returned no buildings, refusing to flag all %d buildings as missing", ErrISmartUpstream, len(buildings))
+	}
+
+	local := make(map[string]models.Building, len(buildings))
+	for _, building := range buildings {
+		local[building.ISmartID] = building
+	}
+	var trashed []string
+	if err := db.Unscoped().Model(&models.Building{}).Where("deleted_at IS NOT NULL").Pluck("ismart_id", &trashed).Error; err != nil {
+		return err
+	}
+	var aliases []models.BuildingAlias
+	if err := db.Where("expires_at > ?", time.Now()).Find(&aliases).Error; err != nil {
+		return err
+	}
+	aliasOf := make(map[string]int64, len(aliases))
+	for _, alias := range aliases {
+		aliasOf[alias.ISmartID] = alias.BuildingID
+	}
+
+	seen := make(map[string]bool, len(upstream))
+	for _, item := range upstream {
+		id := string(item.ID)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		name := item.Name
+		if name == "" {
+			name = id
+		}
+
+		building, ok := local[id]
+		switch {
+		case ok && building.ISmartMissingAt != nil:
+			change := models.ISmartSyncChange{Action: models.ISmartChangeReappeared, ISmartID: id, BuildingID: building.ID, Name: name}
+			if building.Name != name {
+				change.OldName = building.Name
+			}
+			run.Changes = append(run.Changes, change)
+		case ok && building.Name != name:
+			run.Changes = append(run.Changes, models.ISmartSyncChange{Action: models.ISmartChangeUpdate, ISmartID: id, BuildingID: building.ID, Name: name, OldName: building.Name})
+		case ok:
+			run.Unchanged++
+		case slices.Contains(trashed, id):
+			run.Changes = append(run.Changes, models.ISmartSyncChange{Action: models.ISmartChangeConflict, ISmartID: id, Name: name, Message: "ismart_id is used by a building in trash"})
+		case aliasOf[id] != 0:
+			run.Changes = append(run.Changes, models.ISmartSyncChange{Action: models.ISmartChangeConflict, ISmartID: id, BuildingID: aliasOf[id], Name: name, Message: "ismart_id is an alias of a renamed building"})
+		default:
+			run.Changes = append(run.Changes, models.ISmartSyncChange{Action: models.ISmartChangeCreate, ISmartID: id, Name: name})
+		}
+	}
+	for _, building := range buildings {
+		if seen[building.ISmartID] {
+			continue
+		}
+		if building.ISmartMissingAt != nil {
+			run.Unchanged++
+			continue
+		}
+		run.Changes = append(run.Changes, models.ISmartSyncChange{Action: models.ISmartChangeMissing, ISmartID: building.ISmartID, BuildingID: building.ID, Name: building.Name})
+	}
+
+	if run.DryRun {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		for i := range run.Changes {
+			change := &run.Changes[i]
+			var err error
+			switch change.Action {
+			case models.ISmartChangeCreate:
+				building := models.Building{ISmartID: change.ISmartID, Name: change.Name}
+				err = tx.Create(&building).Error
+				change.BuildingID = building.ID
+			case models.ISmartChangeUpdate:
+				err = tx.Model(&models.Building{}).Where("id = ?", change.BuildingID).Update("name", change.Name).Error
+			case models.ISmartChangeReappeared:
+				err = tx.Model(&models.Building{}).Where("id = ?", change.BuildingID).
+					Updates(map[string]interface{}{"name": change.Name, "ismart_missing_at": nil}).Error
+			case models.ISmartChangeMissing:
+				err = tx.Model(&models.Building{}).Where("id = ?", change.BuildingID).Update("ismart_missing_at", now).Error
+			}
+			if err != nil {
+				return fmt.Errorf("%s %s: %w", change.Action, change.ISmartID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// fetchBuildings 请求上游楼栋列表，响应可以是数组或 {"data": [...]}
+func (s *ISmartSyncService) fetchBuildings(ctx context.Context) ([]ismartBuilding, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+s.buildingsPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrISmartUpstream, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	} else if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+
+	client := &http.Client{Timeout: ismartRequestTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrISmartUpstream, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrISmartUpstream, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", ErrISmartUpstream, resp.StatusCode)
+	}
+
+	var items []ismartBuilding
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &items)
+	} else {
+		var wrapped struct {
+			Data []ismartBuilding `json:"data"`
+		}
+		err = json.Unmarshal(trimmed, &wrapped)
+		items = wrapped.Data
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode building list: %v", ErrISmartUpstream, err)
+	}
+	for _, item := range items {
+		if item.ID == "" {
+			return nil, fmt.Errorf("%w: building without id in upstream list", ErrISmartUpstream)
+		}
+	}
+	return items, nil
+}
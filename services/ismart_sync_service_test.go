@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"icctv-http-service/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeISmart 模拟 iSmart 楼栋列表接口，响应内容可在测试中修改
+type fakeISmart struct {
+	mu        sync.Mutex
+	buildings []map[string]interface{}
+	authz     string
+}
+
+func (f *fakeISmart) set(buildings ...map[string]interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.buildings = buildings
+}
+
+func (f *fakeISmart) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path != "/api/buildings" {
+		http.NotFound(w, r)
+		return
+	}
+	f.authz = r.Header.Get("Authorization")
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": f.buildings})
+}
+
+// newISmartSyncTestService 使用临时 sqlite 库与模拟上游构造同步服务
+func newISmartSyncTestService(t *testing.T, upstream *fakeISmart) (*ISmartSyncService, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "sync.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Building{}, &models.BuildingAlias{}, &models.ISmartSyncRun{}, &models.AuditLog{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	server := httptest.NewServer(upstream)
+	t.Cleanup(server.Close)
+	t.Setenv("ISMART_BASE_URL", server.URL+"/")
+	t.Setenv("ISMART_BUILDINGS_PATH", "")
+	t.Setenv("ISMART_TOKEN", "secret")
+	return NewISmartSyncService(db, NewAuditService(db)), db
+}
+
+func seedBuilding(t *testing.T, db *gorm.DB, ismartID, name string) models.Building {
+	t.Helper()
+	building := models.Building{ISmartID: ismartID, Name: name}
+	if err := db.Create(&building).Error; err != nil {
+		t.Fatalf("seed building %s: %v", ismartID, err)
+	}
+	return building
+}
+
+func loadBuilding(t *testing.T, db *gorm.DB, ismartID string) *models.Building {
+	t.Helper()
+	var items []models.Building
+	if err := db.Where("ismart_id = ?", ismartID).Find(&items).Error; err != nil {
+		t.Fatalf("load building %s: %v", ismartID, err)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return &items[0]
+}
+
+// changesByID 按 ismartId 索引同步差异的变更类型
+func changesByID(run *models.ISmartSyncRun) map[string]string {
+	actions := make(map[string]string, len(run.Changes))
+	for _, change := range run.Changes {
+		actions[change.ISmartID] = change.Action
+	}
+	return actions
+}
+
+func countRows(t *testing.T, db *gorm.DB, model interface{}) int64 {
+	t.Helper()
+	var count int64
+	if err := db.Model(model).Count(&count).Error; err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	return count
+}
+
+func TestISmartSyncAppliesUpstreamChanges(t *testing.T) {
+	upstream := &fakeISmart{}
+	service, db := newISmartSyncTestService(t, upstream)
+	ctx := context.Background()
+	actor := Actor{Username: "admin"}
+
+	seedBuilding(t, db, "b1", "Old name")
+	seedBuilding(t, db, "b2", "Same")
+	seedBuilding(t, db, "b3", "Gone")
+	upstream.set(
+		map[string]interface{}{"id": "b1", "name": "New name"},
+		map[string]interface{}{"id": "b2", "name": "Same"},
+		map[string]interface{}{"id": 4, "name": "Numeric"},
+	)
+
+	// dry-run 只计算差异
+	run, err := service.Sync(ctx, true, models.ISmartSyncTriggerManual, actor)
+	if err != nil {
+		t.Fatalf("dry-run sync: %v", err)
+	}
+	want := map[string]string{"b1": models.ISmartChangeUpdate, "4": models.ISmartChangeCreate, "b3": models.ISmartChangeMissing}
+	if got := changesByID(run); len(got) != len(want) || got["b1"] != want["b1"] || got["4"] != want["4"] || got["b3"] != want["b3"] {
+		t.Fatalf("dry-run changes = %v, want %v", got, want)
+	}
+	if run.Upstream != 3 || run.Unchanged != 1 || !run.DryRun || !run.Success {
+		t.Fatalf("dry-run run = %+v", run)
+	}
+	if upstream.authz != "Bearer secret" {
+		t.Fatalf("upstream Authorization = %q", upstream.authz)
+	}
+	if b := loadBuilding(t, db, "b1"); b.Name != "Old name" {
+		t.Fatalf("dry-run renamed b1 to %q", b.Name)
+	}
+	if b := loadBuilding(t, db, "4"); b != nil {
+		t.Fatalf("dry-run created building %+v", b)
+	}
+	if b := loadBuilding(t, db, "b3"); b.ISmartMissingAt != nil {
+		t.Fatal("dry-run flagged b3 as missing")
+	}
+	if n := countRows(t, db, &models.AuditLog{}); n != 0 {
+		t.Fatalf("dry-run wrote %d audit logs", n)
+	}
+
+	// 正式同步：创建、更新与标记缺失
+	run, err = service.Sync(ctx, false, models.ISmartSyncTriggerManual, actor)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if len(run.Changes) != 3 {
+		t.Fatalf("sync changes = %+v", run.Changes)
+	}
+	if b := loadBuilding(t, db, "b1"); b.Name != "New name" {
+		t.Fatalf("b1 name = %q, want %q", b.Name, "New name")
+	}
+	created := loadBuilding(t, db, "4")
+	if created == nil || created.Name != "Numeric" {
+		t.Fatalf("created building = %+v", created)
+	}
+	for _, change := range run.Changes {
+		if change.Action == models.ISmartChangeCreate && change.BuildingID != created.ID {
+			t.Fatalf("create change building_id = %d, want %d", change.BuildingID, created.ID)
+		}
+	}
+	if b := loadBuilding(t, db, "b3"); b == nil || b.ISmartMissingAt == nil {
+		t.Fatal("b3 should be kept and flagged as missing")
+	}
+	if n := countRows(t, db, &models.AuditLog{}); n != 1 {
+		t.Fatalf("audit logs = %d, want 1", n)
+	}
+
+	// 已标记缺失的建筑在上游仍不存在时不重复标记
+	run, err = service.Sync(ctx, false, models.ISmartSyncTriggerSchedule, Actor{Username: "system"})
+	if err != nil {
+		t.Fatalf("repeat sync: %v", err)
+	}
+	if len(run.Changes) != 0 || run.Unchanged != 4 {
+		t.Fatalf("repeat sync = %d changes, %d unchanged", len(run.Changes), run.Unchanged)
+	}
+
+	// 重新出现：清除标记并更新名称
+	upstream.set(
+		map[string]interface{}{"id": "b1", "name": "New name"},
+		map[string]interface{}{"id": "b2", "name": "Same"},
+		map[string]interface{}{"id": "b3", "name": "Back"},
+		map[string]interface{}{"id": "4", "name": "Numeric"},
+	)
+	run, err = service.Sync(ctx, false, models.ISmartSyncTriggerManual, actor)
+	if err != nil {
+		t.Fatalf("reappeared sync: %v", err)
+	}
+	if len(run.Changes) != 1 || run.Changes[0].Action != models.ISmartChangeReappeared || run.Changes[0].OldName != "Gone" {
+		t.Fatalf("reappeared changes = %+v", run.Changes)
+	}
+	if b := loadBuilding(t, db, "b3"); b.ISmartMissingAt != nil || b.Name != "Back" {
+		t.Fatalf("reappeared b3 = %+v", b)
+	}
+
+	if n := countRows(t, db, &models.ISmartSyncRun{}); n != 4 {
+		t.Fatalf("sync runs = %d, want 4", n)
+	}
+}
+
+func TestISmartSyncRefusesEmptyUpstream(t *testing.T) {
+	upstream := &fakeISmart{}
+	service, db := newISmartSyncTestService(t, upstream)
+	ctx := context.Background()
+
+	seedBuilding(t, db, "b1", "One")
+	seedBuilding(t, db, "b2", "Two")
+	upstream.set()
+
+	if _, err := service.Sync(ctx, false, models.ISmartSyncTriggerManual, Actor{Username: "admin"}); !errors.Is(err, ErrISmartUpstream) {
+		t.Fatalf("sync error = %v, want %v", err, ErrISmartUpstream)
+	}
+	var flagged int64
+	if err := db.Model(&models.Building{}).Where("ismart_missing_at IS NOT NULL").Count(&flagged).Error; err != nil {
+		t.Fatalf("count flagged: %v", err)
+	}
+	if flagged != 0 {
+		t.Fatalf("%d buildings flagged as missing after an empty upstream response", flagged)
+	}
+
+	// 失败的同步也写入同步记录与审计日志
+	runs, err := service.Runs(ctx, 0)
+	if err != nil {
+		t.Fatalf("runs: %v", err)
+	}
+	if len(runs) != 1 || runs[0].Success || runs[0].Error == "" {
+		t.Fatalf("runs = %+v", runs)
+	}
+	var audit models.AuditLog
+	if err := db.First(&audit).Error; err != nil {
+		t.Fatalf("audit log: %v", err)
+	}
+	if audit.Success || audit.Action != "ismart.sync" {
+		t.Fatalf("audit log = %+v", audit)
+	}
+}
@@ -0,0 +1,664 @@
+package services
+
+// JobService Methods:
+//0. NewJobService(db *gorm.DB) -> 初始化异步任务服务（从环境变量读取并发数）
+//1. RegisterHandler(jobType string, handler JobHandler) -> 注册任务类型处理函数
+//2. Start(ctx context.Context) -> 启动调度器与工作协程
+//3. Enqueue(ctx context.Context, req EnqueueJobRequest, createdBy string) -> 创建任务
+//4. Get(ctx context.Context, id int64) -> 查询任务及目标明细
+//5. List(ctx context.Context, query JobListQuery) -> 分页查询任务
+//6. Cancel(ctx context.Context, id int64) -> 取消任务
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"icctv-http-service/models"
+
+	"gorm.io/gorm"
+)
+
+// 错误定义
+var (
+	ErrJobNotFound     = errors.New("job not found")
+	ErrJobTypeUnknown  = errors.New("unknown job type")
+	ErrJobNoTargets    = errors.New("job has no targets")
+	ErrJobFinished     = errors.New("job already finished")
+	ErrJobInvalidParam = errors.New("invalid job params")
+	ErrJobNotRetryable = errors.New("not retryable")
+)
+
+// 重试退避：第 n 次失败后等待 base*2^(n-1)，不超过 max
+const (
+	jobRetryBaseDelay = 5 * time.Second
+	jobRetryMaxDelay  = 5 * time.Minute
+)
+
+// JobServiceInterface 定义异步任务能力
+type JobServiceInterface interface {
+	RegisterHandler(jobType string, handler JobHandler)                                          //1.注册处理函数
+	Start(ctx context.Context) error                                                             //2.启动
+	Enqueue(ctx context.Context, req EnqueueJobRequest, createdBy string) (*models.Job, error)   //3.创建任务
+	Get(ctx context.Context, id int64) (*models.Job, error)                                      //4.任务详情
+	List(ctx context.Context, query JobListQuery) ([]models.Job, models.PaginationResult, error) //5.任务列表
+	Cancel(ctx context.Context, id int64) (*models.Job, error)                                   //6.取消任务
+	CancelPending(ctx context.Context, id int64) error                                           //7.取消未开始的目标
+}
+
+// JobTask 传给处理函数的单个目标执行上下文
+type JobTask struct {
+	JobID      int64
+	TargetID   int64
+	OrangePiID int64
+	Attempt    int
+	Params     map[string]interface{} // 任务参数与目标参数合并后的结果（目标参数优先）
+	CreatedBy  string
+}
+
+// Int 读取整数参数（JSON 数字解码后为 float64）
+func (t JobTask) Int(key string) (int, error) {
+	switch v := t.Params[key].(type) {
+	case float64:
+		return int(v), nil
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case json.Number:
+		n, err := v.Int64()
+		return int(n), err
+	case string:
+		return strconv.Atoi(v)
+	case nil:
+		return 0, fmt.Errorf("%w: %s is required", ErrJobInvalidParam, key)
+	default:
+		return 0, fmt.Errorf("%w: %s must be a number", ErrJobInvalidParam, key)
+	}
+}
+
+// JobHandler 任务处理函数，返回值写入目标的 result 字段
+type JobHandler func(ctx context.Context, task JobTask) (interface{}, error)
+
+// JobTargetRequest 单个目标及其专属参数
+type JobTargetRequest struct {
+	OrangePiID int64                  `json:"orangepi_id"`
+	Params     map[string]interface{} `json:"params"`
+}
+
+// EnqueueJobRequest 创建任务参数
+type EnqueueJobRequest struct {
+	Type        string                 `json:"type"`
+	OrangePiIDs []int64                `json:"orangepi_ids"` // 使用任务参数的目标
+	Targets     []JobTargetRequest     `json:"targets"`      // 带专属参数的目标
+	AllDevices  bool                   `json:"all_devices"`  // 对全部已部署及维护中的设备执行
+	Params      map[string]interface{} `json:"params"`
+	MaxRetries  int                    `json:"max_retries"`
+}
+
+// JobListQuery 任务列表查询条件
+type JobListQuery struct {
+	models.PaginationQuery
+	Type   string
+	Status string
+}
+
+// JobService 持久化的异步任务队列：任务与目标保存在数据库，调度器按并发数领取待执行目标
+type JobService struct {
+	db           *gorm.DB
+	workers      int
+	pollInterval time.Duration
+
+	mu       sync.Mutex
+	handlers map[string]JobHandler
+	running  map[int64]runningJobTarget // targetID -> 执行中的目标
+	wake     chan struct{}
+}
+
+type runningJobTarget struct {
+	jobID  int64
+	cancel context.CancelFunc
+}
+
+// 0. NewJobService 构造函数，JOB_WORKERS 控制同时执行的目标数量（默认 20）
+func NewJobService(db *gorm.DB) *JobService {
+	workers := 20
+	if val := os.Getenv("JOB_WORKERS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			workers = parsed
+		} else {
+			log.Printf("Warning: invalid JOB_WORKERS=%q, using %d", val, workers)
+		}
+	}
+
+	return &JobService{
+		db:           db,
+		workers:      workers,
+		pollInterval: time.Second,
+		handlers:     make(map[string]JobHandler),
+		running:      make(map[int64]runningJobTarget),
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// 1. RegisterHandler 注册任务类型，需在 Start 之前调用
+func (s *JobService) RegisterHandler(jobType string, handler JobHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[jobType] = handler
+}
+
+// 2. Start 恢复上次进程退出时未完成的目标并启动调度器，ctx 结束时停止领取新目标
+func (s *JobService) Start(ctx context.Context) error {
+	// 上次退出时仍在执行的目标重新排队
+	now := time.Now()
+	if err := s.db.WithContext(ctx).Model(&models.JobTarget{}).
+		Where("status = ?", models.JobStatusRunning).
+		Updates(map[string]interface{}{"status": models.JobStatusPending, "next_run_at": now}).Error; err != nil {
+		return err
+	}
+
+	go s.dispatch(ctx)
+	log.Printf("job service started with %d workers", s.workers)
+	return nil
+}
+
+// 3. Enqueue 创建任务，目标来自 orangepi_ids、targets 或 all_devices
+func (s *JobService) Enqueue(ctx context.Context, req EnqueueJobRequest, createdBy string) (*models.Job, error) {
+	s.mu.Lock()
+	_, ok := s.handlers[req.Type]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrJobTypeUnknown, req.Type)
+	}
+	if req.MaxRetries < 0 {
+		req.MaxRetries = 0
+	}
+
+	targets := make([]models.JobTarget, 0, len(req.OrangePiIDs)+len(req.Targets))
+	seen := make(map[int64]bool)
+	addTarget := func(id int64, params map[string]interface{}) {
+		if id <= 0 || seen[id] {
+			return
+		}
+		seen[id] = true
+		targets = append(targets, models.JobTarget{OrangePiID: id, Status: models.JobStatusPending, Params: params})
+	}
+	for _, t := range req.Targets {
+		addTarget(t.OrangePiID, t.Params)
+	}
+	for _, id := range req.OrangePiIDs {
+		addTarget(id, nil)
+	}
+
+	job := models.Job{
+		Type:       req.Type,
+		Status:     models.JobStatusPending,
+		Params:     req.Params,
+		MaxRetries: req.MaxRetries,
+		CreatedBy:  createdBy,
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if req.AllDevices {
+			var ids []int64
+			if err := tx.Model(&models.OrangePi{}).Where("lifecycle_state IN ?", models.LifecycleManagedStates).Order("id asc").Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			for _, id := range ids {
+				addTarget(id, nil)
+			}
+		}
+		if len(targets) == 0 {
+			return ErrJobNoTargets
+		}
+
+		// 目标设备必须存在
+		ids := make([]int64, 0, len(targets))
+		for _, t := range targets {
+			ids = append(ids, t.OrangePiID)
+		}
+		var count int64
+		if err := tx.Model(&models.OrangePi{}).Where("id IN ?", ids).Count(&count).Error; err != nil {
+			return err
+		}
+		if int(count) != len(ids) {
+			return fmt.Errorf("%w: some orangepi_ids do not exist", ErrOrangePiNotFound)
+		}
+
+		job.Total = len(targets)
+		if err := tx.Create(&job).Error; err != nil {
+			return err
+		}
+		for i := range targets {
+			targets[i].JobID = job.ID
+		}
+		return tx.CreateInBatches(&targets, 100).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	job.Targets = targets
+	s.notify()
+	return &job, nil
+}
+
+// 4. Get 查询任务及每个目标的执行结果
+func (s *JobService) Get(ctx context.Context, id int64) (*models.Job, error) {
+	var job models.Job
+	if err := s.db.WithContext(ctx).
+		Preload("Targets", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+		First(&job, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// 5. List 分页查询任务（不含目标明细），可按类型与状态筛选
+func (s *JobService) List(ctx context.Context, query JobListQuery) ([]models.Job, models.PaginationResult, error) {
+	if query.PageNum <= 0 {
+		query.PageNum = 1
+	}
+	if query.PageSize <= 0 || query.PageSize > 100 {
+		query.PageSize = 20
+	}
+
+	var (
+		jobs  []models.Job
+		total int64
+	)
+	tx := s.db.WithContext(ctx).Model(&models.Job{})
+	if query.Type != "" {
+		tx = tx.Where("type = ?", query.Type)
+	}
+	if query.Status != "" {
+		tx = tx.Where("status = ?", query.Status)
+	}
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, models.PaginationResult{}, err
+	}
+
+	order := "id desc"
+	if query.Asc {
+		order = "id asc"
+	}
+	if err := tx.Order(order).
+		Offset((query.PageNum - 1) * query.PageSize).
+		Limit(query.PageSize).
+		Find(&jobs).Error; err != nil {
+		return nil, models.PaginationResult{}, err
+	}
+
+	return jobs, models.PaginationResult{
+		Total:    int(total),
+		PageNum:  query.PageNum,
+		PageSize: query.PageSize,
+	}, nil
+}
+
+// 6. Cancel 取消任务：未开始的目标直接取消，执行中的目标中断其上下文
+func (s *JobService) Cancel(ctx context.Context, id int64) (*models.Job, error) {
+	if err := s.cancelPending(ctx, id); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	for _, rt := range s.running {
+		if rt.jobID == id {
+			rt.cancel()
+		}
+	}
+	s.mu.Unlock()
+
+	if err := s.refreshJob(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// 7. CancelPending 只取消未开始的目标，执行中的目标继续完成（用于暂停依赖任务队列的上层流程）
+func (s *JobService) CancelPending(ctx context.Context, id int64) error {
+	if err := s.cancelPending(ctx, id); err != nil {
+		return err
+	}
+	return s.refreshJob(ctx, id)
+}
+
+// cancelPending 标记任务为已取消并取消其未开始的目标
+func (s *JobService) cancelPending(ctx context.Context, id int64) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var job models.Job
+		if err := tx.First(&job, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrJobNotFound
+			}
+			return err
+		}
+		if job.FinishedAt != nil {
+			return ErrJobFinished
+		}
+
+		now := time.Now()
+		if err := tx.Model(&models.JobTarget{}).
+			Where("job_id = ? AND status = ?", id, models.JobStatusPending).
+			Updates(map[string]interface{}{"status": models.JobStatusCancelled, "finished_at": now}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&job).Update("status", models.JobStatusCancelled).Error
+	})
+}
+
+// notify 唤醒调度器
+func (s *JobService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch 调度循环：有空闲并发时领取到期的待执行目标
+func (s *JobService) dispatch(ctx context.Context) {
+	sem := make(chan struct{}, s.workers)
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.finalizeIdle(ctx)
+		if free := s.workers - len(sem); free > 0 {
+			for _, target := range s.claim(ctx, free) {
+				sem <- struct{}{}
+				go func(target models.JobTarget) {
+					defer func() { <-sem; s.notify() }()
+					defer s.recoverTarget(ctx, target)
+					s.execute(ctx, target)
+				}(target)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// finalizeIdle 补充汇总已无待执行目标但尚未结束的任务（例如上次汇总写入失败）
+func (s *JobService) finalizeIdle(ctx context.Context) {
+	var ids []int64
+	if err := s.db.WithContext(ctx).Model(&models.Job{}).
+		Where("finished_at IS NULL AND NOT EXISTS (SELECT 1 FROM job_targets WHERE job_targets.job_id = jobs.id AND job_targets.status IN ?)",
+			[]string{models.JobStatusPending, models.JobStatusRunning}).
+		Pluck("id", &ids).Error; err != nil {
+		return
+	}
+	for _, id := range ids {
+		if err := s.refreshJob(ctx, id); err != nil {
+			log.Printf("job %d: failed to refresh progress: %v", id, err)
+		}
+	}
+}
+
+// claim 以条件更新的方式领取目标，避免同一目标被重复执行
+func (s *JobService) claim(ctx context.Context, limit int) []models.JobTarget {
+	now := time.Now()
+	var candidates []models.JobTarget
+	if err := s.db.WithContext(ctx).
+		Where("status = ? AND (next_run_at IS NULL OR next_run_at <= ?)", models.JobStatusPending, now).
+		Order("id asc").Limit(limit).
+		Find(&candidates).Error; err != nil {
+		if ctx.Err() == nil {
+			log.Printf("job dispatcher: failed to load pending targets: %v", err)
+		}
+		return nil
+	}
+
+	claimed := make([]models.JobTarget, 0, len(candidates))
+	for _, target := range candidates {
+		res := s.db.WithContext(ctx).Model(&models.JobTarget{}).
+			Where("id = ? AND status = ?", target.ID, models.JobStatusPending).
+			Updates(map[string]interface{}{
+				"status":     models.JobStatusRunning,
+				"attempts":   gorm.Expr("attempts + 1"),
+				"started_at": now,
+			})
+		if res.Error != nil || res.RowsAffected == 0 {
+			continue
+		}
+		target.Status = models.JobStatusRunning
+		target.Attempts++
+		target.StartedAt = &now
+		claimed = append(claimed, target)
+
+		if err := s.db.WithContext(ctx).Model(&models.Job{}).
+			Where("id = ? AND status = ?", target.JobID, models.JobStatusPending).
+			Updates(map[string]interface{}{"status": models.JobStatusRunning, "started_at": now}).Error; err != nil {
+			log.Printf("job %d: failed to mark running: %v", target.JobID, err)
+		}
+	}
+	return claimed
+}
+
+// execute 执行单个目标并记录结果，失败时按退避策略重新排队
+func (s *JobService) execute(ctx context.Context, target models.JobTarget) {
+	var job models.Job
+	if err := s.db.WithContext(ctx).First(&job, target.JobID).Error; err != nil {
+		log.Printf("job target %d: failed to load job %d: %v", target.ID, target.JobID, err)
+		// 任务不存在时标记失败，其他错误（数据库暂时不可用）按退避重新排队
+		if ctx.Err() == nil {
+			s.releaseTarget(ctx, target, !errors.Is(err, gorm.ErrRecordNotFound), fmt.Sprintf("load job: %v", err))
+		}
+		return
+	}
+
+	s.mu.Lock()
+	handler := s.handlers[job.Type]
+	runCtx, cancel := context.WithCancel(ctx)
+	s.running[target.ID] = runningJobTarget{jobID: job.ID, cancel: cancel}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, target.ID)
+		s.mu.Unlock()
+		cancel()
+	}()
+
+	var (
+		result interface{}
+		err    error
+	)
+	if handler == nil {
+		err = fmt.Errorf("%w: %q", ErrJobTypeUnknown, job.Type)
+	} else {
+		params := make(map[string]interface{}, len(job.Params)+len(target.Params))
+		for k, v := range job.Params {
+			params[k] = v
+		}
+		for k, v := range target.Params {
+			params[k] = v
+		}
+		result, err = handler(runCtx, JobTask{
+			JobID:      job.ID,
+			TargetID:   target.ID,
+			OrangePiID: target.OrangePiID,
+			Attempt:    target.Attempts,
+			Params:     params,
+			CreatedBy:  job.CreatedBy,
+		})
+	}
+
+	// 服务正在退出：保持 running 状态，下次启动时重新排队
+	if ctx.Err() != nil {
+		return
+	}
+
+	now := time.Now()
+	outcome := models.JobTarget{Result: result, FinishedAt: &now}
+	switch {
+	case runCtx.Err() != nil && err != nil:
+		outcome.Status = models.JobStatusCancelled
+		outcome.Error = err.Error()
+	case err != nil && target.Attempts <= job.MaxRetries && isRetryableJobError(err):
+		next := now.Add(jobRetryDelay(target.Attempts))
+		outcome.Status = models.JobStatusPending
+		outcome.Error = err.Error()
+		outcome.NextRunAt = &next
+		outcome.FinishedAt = nil
+	case err != nil:
+		outcome.Status = models.JobStatusFailed
+		outcome.Error = err.Error()
+	default:
+		outcome.Status = models.JobStatusSucceeded
+	}
+
+	// 使用 Select 保证空值（清空错误、结果为空）也会写入
+	if err := s.db.WithContext(ctx).Model(&models.JobTarget{}).
+		Where("id = ? AND status = ?", target.ID, models.JobStatusRunning).
+		Select("Status", "Result", "Error", "NextRunAt", "FinishedAt").
+		Updates(&outcome).Error; err != nil {
+		log.Printf("job target %d: failed to save result: %v", target.ID, err)
+	}
+
+	// 任务已取消时，重新排队的目标直接取消
+	if outcome.Status == models.JobStatusPending {
+		s.db.WithContext(ctx).Model(&models.JobTarget{}).
+			Where("id = ? AND status = ? AND EXISTS (SELECT 1 FROM jobs WHERE jobs.id = ? AND jobs.status = ?)",
+				target.ID, models.JobStatusPending, job.ID, models.JobStatusCancelled).
+			Updates(map[string]interface{}{"status": models.JobStatusCancelled, "finished_at": now})
+	}
+
+	if err := s.refreshJob(ctx, job.ID); err != nil {
+		log.Printf("job %d: failed to refresh progress: %v", job.ID, err)
+	}
+}
+
+// recoverTarget 处理函数（或执行过程）panic 时记录堆栈，目标按重试策略重新排队，没有剩余重试次数时标记失败，
+// 避免 panic 导致服务退出、目标停留在 running
+func (s *JobService) recoverTarget(ctx context.Context, target models.JobTarget) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	log.Printf("job target %d: panic: %v\n%s", target.ID, r, debug.Stack())
+	// 服务正在退出：保持 running 状态，下次启动时重新排队
+	if ctx.Err() != nil {
+		return
+	}
+
+	maxRetries := 0
+	var job models.Job
+	if err := s.db.WithContext(ctx).First(&job, target.JobID).Error; err != nil {
+		log.Printf("job target %d: failed to load job %d: %v", target.ID, target.JobID, err)
+	} else {
+		maxRetries = job.MaxRetries
+	}
+	s.releaseTarget(ctx, target, target.Attempts <= maxRetries, fmt.Sprintf("panic: %v", r))
+}
+
+// releaseTarget 结束未能正常执行的 running 目标：retry 为 true 时按退避重新排队，否则标记失败
+func (s *JobService) releaseTarget(ctx context.Context, target models.JobTarget, retry bool, message string) {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":      models.JobStatusFailed,
+		"error":       message,
+		"finished_at": now,
+	}
+	if retry {
+		updates["status"] = models.JobStatusPending
+		updates["next_run_at"] = now.Add(jobRetryDelay(target.Attempts))
+		updates["finished_at"] = nil
+	}
+	if err := s.db.WithContext(ctx).Model(&models.JobTarget{}).
+		Where("id = ? AND status = ?", target.ID, models.JobStatusRunning).
+		Updates(updates).Error; err != nil {
+		log.Printf("job target %d: failed to release: %v", target.ID, err)
+	}
+	if err := s.refreshJob(ctx, target.JobID); err != nil {
+		log.Printf("job %d: failed to refresh progress: %v", target.JobID, err)
+	}
+}
+
+// refreshJob 汇总目标状态更新任务进度，所有目标结束后写入最终状态
+func (s *JobService) refreshJob(ctx context.Context, id int64) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var job models.Job
+		if err := tx.First(&job, id).Error; err != nil {
+			return err
+		}
+
+		var rows []struct {
+			Status string
+			Count  int
+		}
+		if err := tx.Model(&models.JobTarget{}).
+			Select("status, COUNT(*) AS count").
+			Where("job_id = ?", id).
+			Group("status").
+			Scan(&rows).Error; err != nil {
+			return err
+		}
+		counts := make(map[string]int, len(rows))
+		for _, row := range rows {
+			counts[row.Status] = row.Count
+		}
+
+		updates := map[string]interface{}{
+			"succeeded": counts[models.JobStatusSucceeded],
+			"failed":    counts[models.JobStatusFailed],
+			"cancelled": counts[models.JobStatusCancelled],
+		}
+		if counts[models.JobStatusPending] == 0 && counts[models.JobStatusRunning] == 0 && job.FinishedAt == nil {
+			updates["finished_at"] = time.Now()
+			switch {
+			case job.Status == models.JobStatusCancelled:
+			case counts[models.JobStatusFailed] == 0:
+				updates["status"] = models.JobStatusSucceeded
+			case counts[models.JobStatusSucceeded] == 0:
+				updates["status"] = models.JobStatusFailed
+			default:
+				updates["status"] = models.JobStatusPartiallyFailed
+			}
+		}
+		return tx.Model(&job).Updates(updates).Error
+	})
+}
+
+// jobRetryDelay 第 attempts 次执行失败后的重试等待时间（指数退避，最长 jobRetryMaxDelay）
+func jobRetryDelay(attempts int) time.Duration {
+	delay := jobRetryBaseDelay << (attempts - 1)
+	if delay > jobRetryMaxDelay || delay <= 0 {
+		delay = jobRetryMaxDelay
+	}
+	return delay
+}
+
+// isRetryableJobError 参数错误、设备不存在、身份或端口冲突等重试无意义的错误不再重试
+func isRetryableJobError(err error) bool {
+	for _, target := range []error{
+		ErrJobNotRetryable,
+		ErrJobInvalidParam,
+		ErrJobTypeUnknown,
+		ErrOrangePiNotFound,
+		gorm.ErrRecordNotFound,
+		ErrDeviceIDMismatch,
+		ErrPortOutOfRange,
+		ErrPortConflict,
+		ErrPortMigrationFailed,
+	} {
+		if errors.Is(err, target) {
+			return false
+		}
+	}
+	return true
+}
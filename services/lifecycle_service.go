@@ -0,0 +1,332 @@
+package services
+
+// LifecycleService Methods:
+//0. NewLifecycleService(db *gorm.DB, auditService *AuditService) -> 初始化设备生命周期服务
+//1. Transition(ctx context.Context, id int64, to, reason string, actor Actor) -> 按允许的转换变更设备生命周期状态
+//2. History(ctx context.Context, id int64) -> 查询设备替换链及生命周期变更记录
+//3. Replace(ctx context.Context, id int64, payload DeviceReplacement, actor Actor) -> 用新硬件替换故障设备
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"icctv-http-service/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrLifecycleStateInvalid = errors.New("invalid lifecycle state")
+	ErrLifecycleTransition   = errors.New("lifecycle transition not allowed")
+	ErrReplacementInvalid    = errors.New("invalid device replacement")
+)
+
+// LifecycleServiceInterface 定义设备生命周期能力
+type LifecycleServiceInterface interface {
+	Transition(ctx context.Context, id int64, to, reason string, actor Actor) (*models.OrangePi, error)              //1.状态变更
+	History(ctx context.Context, id int64) (*DeviceHistory, error)                                                   //2.变更记录
+	Replace(ctx context.Context, id int64, payload DeviceReplacement, actor Actor) (*DeviceReplacementResult, error) //3.替换设备
+}
+
+// DeviceHistory 设备替换链（从最早的设备到最新的设备）及链上所有设备的生命周期变更记录
+type DeviceHistory struct {
+	Chain  []models.OrangePi             `json:"chain"`
+	Events []models.DeviceLifecycleEvent `json:"events"`
+}
+
+// DeviceReplacement 替换设备请求
+type DeviceReplacement struct {
+	DeviceID string `json:"device_id"` // 新硬件的设备ID
+	Name     string `json:"name"`      // 新设备名称，为空时沿用旧设备名称
+	Reason   string `json:"reason"`    // 替换原因
+}
+
+// DeviceReplacementResult 替换结果
+type DeviceReplacementResult struct {
+	Old *models.OrangePi `json:"old"`
+	New *models.OrangePi `json:"new"`
+}
+
+// LifecycleService 设备生命周期：in_stock / provisioning / deployed / maintenance / faulty / retired
+type LifecycleService struct {
+	db           *gorm.DB
+	auditService *AuditService
+}
+
+// 0. NewLifecycleService 构造函数
+func NewLifecycleService(db *gorm.DB, auditService *AuditService) *LifecycleService {
+	return &LifecycleService{db: db, auditService: auditService}
+}
+
+// 1. Transition 变更设备生命周期状态，必须填写原因；转换规则见 models.LifecycleTransitions
+func (s *LifecycleService) Transition(ctx context.Context, id int64, to, reason string, actor Actor) (*models.OrangePi, error) {
+	reason = strings.TrimSpace(reason)
+	if !models.IsLifecycleState(to) {
+		return nil, fmt.Errorf("%w: %s", ErrLifecycleStateInvalid, to)
+	}
+	if reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrLifecycleStateInvalid)
+	}
+
+	var device models.OrangePi
+	var from string
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&device, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrangePiNotFound
+			}
+			return err
+		}
+		from = device.LifecycleState
+		if !models.CanTransitionLifecycle(from, to) {
+			return fmt.Errorf("%w: %s -> %s", ErrLifecycleTransition, from, to)
+		}
+		return transitionLifecycle(tx, &device, to, reason, actor.Username)
+	})
+
+	if errors.Is(err, ErrOrangePiNotFound) {
+		return nil, err
+	}
+
+	entry := models.AuditLog{
+		Action:     "orangepi.lifecycle",
+		TargetType: "orangepi",
+		TargetID:   id,
+		Detail:     map[string]interface{}{"from": from, "to": to, "reason": reason},
+		Success:    err == nil,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	s.auditService.Record(ctx, actor, entry)
+	if err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// 2. History 查询设备替换链及链上所有设备的生命周期变更记录（按时间倒序）
+func (s *LifecycleService) History(ctx context.Context, id int64) (*DeviceHistory, error) {
+	chain, err := s.replacementChain(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(chain))
+	for _, device := range chain {
+		ids = append(ids, device.ID)
+	}
+	var events []models.DeviceLifecycleEvent
+	if err := s.db.WithContext(ctx).
+		Where("orangepi_id IN ?", ids).
+		Order("id desc").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return &DeviceHistory{Chain: chain, Events: events}, nil
+}
+
+// 3. Replace 用新硬件替换故障设备：在一个事务内把楼栋绑定、FRP 端口、设备凭证转移到以新设备ID创建的设备记录，
+// 新设备直接进入 deployed，旧设备释放端口与凭证后按转换规则报废（deployed/provisioning 先转为 faulty）并记录替换者。
+// 设备没有标签模型，随替换转移的设备属性只有名称与对账开关；名称不要求唯一，未指定新名称时两条记录同名，以替换链区分
+func (s *LifecycleService) Replace(ctx context.Context, id int64, payload DeviceReplacement, actor Actor) (*DeviceReplacementResult, error) {
+	payload.DeviceID = strings.TrimSpace(payload.DeviceID)
+	payload.Reason = strings.TrimSpace(payload.Reason)
+	if payload.DeviceID == "" {
+		return nil, fmt.Errorf("%w: device_id is required", ErrReplacementInvalid)
+	}
+	if payload.Reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrReplacementInvalid)
+	}
+
+	var old, replacement models.OrangePi
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&old, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrangePiNotFound
+			}
+			return err
+		}
+		if old.LifecycleState == models.LifecycleRetired {
+			return fmt.Errorf("%w: orangepi %d is already retired", ErrLifecycleTransition, old.ID)
+		}
+		if old.PortMigrationState != "" {
+			return ErrPortMigrationInProgress
+		}
+		if old.DeviceID != nil && *old.DeviceID == payload.DeviceID {
+			return fmt.Errorf("%w: device_id is the same as the old device", ErrReplacementInvalid)
+		}
+		taken, err := deviceIDTaken(tx, payload.DeviceID, 0)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrDeviceIDConflict
+		}
+
+		replacement = models.OrangePi{
+			BuildingID:                 old.BuildingID,
+			Name:                       old.Name,
+			ICCTVAuthServiceRemotePort: old.ICCTVAuthServiceRemotePort,
+			SSHRemotePort:              old.SSHRemotePort,
+			LifecycleState:             models.LifecycleDeployed,
+			LifecycleReason:            fmt.Sprintf("replaces orangepi %d", old.ID),
+			DeviceKey:                  old.DeviceKey,
+			DeviceID:                   &payload.DeviceID,
+			ReconcileDisabled:          old.ReconcileDisabled,
+			ReplacesID:                 &old.ID,
+		}
+		if payload.Name != "" {
+			replacement.Name = payload.Name
+		}
+		now := time.Now()
+		replacement.LifecycleChangedAt = &now
+
+		// 先释放旧设备的绑定、端口与凭证，再创建新设备记录，避免端口与凭证同时出现在两条记录上
+		if err := tx.Model(&old).Updates(map[string]interface{}{
+			"building_id":                    nil,
+			"icctv_auth_service_remote_port": 0,
+			"ssh_remote_port":                0,
+			"device_key":                     "",
+		}).Error; err != nil {
+			return err
+		}
+		old.BuildingID = nil
+		old.ICCTVAuthServiceRemotePort = 0
+		old.SSHRemotePort = 0
+		old.DeviceKey = ""
+		if err := tx.Create(&replacement).Error; err != nil {
+			return err
+		}
+		if err := recordOrangePiBinding(tx, old.ID, replacement.BuildingID, nil, fmt.Sprintf("replaced by orangepi %d", replacement.ID), actor.Username); err != nil {
+			return err
+		}
+		if err := recordOrangePiBinding(tx, replacement.ID, nil, replacement.BuildingID, fmt.Sprintf("replaces orangepi %d", old.ID), actor.Username); err != nil {
+			return err
+		}
+		if err := recordInitialLifecycle(tx, &replacement, actor.Username); err != nil {
+			return err
+		}
+
+		// 旧设备按转换规则报废：不能直接报废的状态（deployed、provisioning）先转为 faulty
+		reason := fmt.Sprintf("replaced by orangepi %d: %s", replacement.ID, payload.Reason)
+		if !models.CanTransitionLifecycle(old.LifecycleState, models.LifecycleRetired) {
+			if !models.CanTransitionLifecycle(old.LifecycleState, models.LifecycleFaulty) {
+				return fmt.Errorf("%w: %s -> %s", ErrLifecycleTransition, old.LifecycleState, models.LifecycleRetired)
+			}
+			if err := transitionLifecycle(tx, &old, models.LifecycleFaulty, reason, actor.Username); err != nil {
+				return err
+			}
+		}
+		if err := transitionLifecycle(tx, &old, models.LifecycleRetired, reason, actor.Username); err != nil {
+			return err
+		}
+		old.ReplacedByID = &replacement.ID
+		return tx.Model(&old).Update("replaced_by_id", replacement.ID).Error
+	})
+
+	entry := models.AuditLog{
+		Action:     "orangepi.replace",
+		TargetType: "orangepi",
+		TargetID:   id,
+		Detail:     map[string]interface{}{"device_id": payload.DeviceID, "reason": payload.Reason},
+		Success:    err == nil,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.Detail["replacement_id"] = replacement.ID
+	}
+	if !errors.Is(err, ErrOrangePiNotFound) {
+		s.auditService.Record(ctx, actor, entry)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &DeviceReplacementResult{Old: &old, New: &replacement}, nil
+}
+
+// replacementChain 沿 replaces_id / replaced_by_id 查询设备所在的替换链（从最早到最新）
+func (s *LifecycleService) replacementChain(ctx context.Context, id int64) ([]models.OrangePi, error) {
+	var device models.OrangePi
+	if err := s.db.WithContext(ctx).First(&device, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrangePiNotFound
+		}
+		return nil, err
+	}
+
+	seen := map[int64]bool{device.ID: true}
+	chain := []models.OrangePi{device}
+	// 向前查找被替换的旧设备
+	for next := device.ReplacesID; next != nil && !seen[*next]; {
+		var prev models.OrangePi
+		if err := s.db.WithContext(ctx).First(&prev, *next).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				break
+			}
+			return nil, err
+		}
+		seen[prev.ID] = true
+		chain = append([]models.OrangePi{prev}, chain...)
+		next = prev.ReplacesID
+	}
+	// 向后查找替换者
+	for next := device.ReplacedByID; next != nil && !seen[*next]; {
+		var succ models.OrangePi
+		if err := s.db.WithContext(ctx).First(&succ, *next).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				break
+			}
+			return nil, err
+		}
+		seen[succ.ID] = true
+		chain = append(chain, succ)
+		next = succ.ReplacedByID
+	}
+	return chain, nil
+}
+
+// recordInitialLifecycle 在事务内记录新设备的初始状态（FromState 为空），生命周期历史从设备进入系统开始
+func recordInitialLifecycle(tx *gorm.DB, device *models.OrangePi, actor string) error {
+	return tx.Create(&models.DeviceLifecycleEvent{
+		OrangePiID: device.ID,
+		ToState:    device.LifecycleState,
+		Reason:     device.LifecycleReason,
+		Actor:      actor,
+	}).Error
+}
+
+// transitionLifecycle 在事务内写入新状态与变更记录（不校验转换规则）
+// 只在状态仍为读取时的值时更新，并发变更时返回 ErrLifecycleTransition
+func transitionLifecycle(tx *gorm.DB, device *models.OrangePi, to, reason, actor string) error {
+	now := time.Now()
+	from := device.LifecycleState
+	result := tx.Model(&models.OrangePi{}).
+		Where("id = ? AND lifecycle_state = ?", device.ID, from).
+		Updates(map[string]interface{}{
+			"lifecycle_state":      to,
+			"lifecycle_reason":     reason,
+			"lifecycle_changed_at": now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: state changed concurrently", ErrLifecycleTransition)
+	}
+	device.LifecycleState = to
+	device.LifecycleReason = reason
+	device.LifecycleChangedAt = &now
+
+	return tx.Create(&models.DeviceLifecycleEvent{
+		OrangePiID: device.ID,
+		FromState:  from,
+		ToState:    to,
+		Reason:     reason,
+		Actor:      actor,
+	}).Error
+}
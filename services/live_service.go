@@ -0,0 +1,162 @@
+package services
+
+// LiveService Methods:
+//0. NewLiveService(db *gorm.DB, orangePiService *OrangePiService, publicNetService *PublicNetService, authService *AuthService) -> 初始化直播地址服务（从环境变量读取 RTSP 端口偏移）
+//1. Resolve(ctx context.Context, ismartID string, channel int, token string) -> 解析楼栋通道的 WHEP / HLS / RTSP 播放地址
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"icctv-http-service/models"
+
+	"gorm.io/gorm"
+)
+
+// liveHealthTimeout 选择设备时健康检查的超时时间
+const liveHealthTimeout = 3 * time.Second
+
+// LiveServiceInterface 定义直播地址解析能力
+type LiveServiceInterface interface {
+	Resolve(ctx context.Context, ismartID string, channel int, token string) (*LiveView, error) //1.解析播放地址
+}
+
+// LiveView 可直接使用的播放地址（地址中已携带视频 Token）
+type LiveView struct {
+	ISmartID   string    `json:"ismartid"`
+	Channel    int       `json:"channel"`
+	Path       string    `json:"path"`
+	OrangePiID int64     `json:"orangepi_id"`
+	Healthy    bool      `json:"healthy"` // 选中的设备健康检查是否通过（全部不健康时仍返回最近有心跳的设备）
+	Token      string    `json:"token"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	WHEP       string    `json:"whep"`
+	HLS        string    `json:"hls"`
+	RTSP       string    `json:"rtsp,omitempty"` // 未配置 LIVE_RTSP_PORT_OFFSET 时为空
+}
+
+// LiveService 根据楼栋当前绑定的设备生成播放地址：WHEP、HLS 经公网IP与设备认证端口（agent 反向代理 MediaMTX），
+// RTSP 经认证端口加 LIVE_RTSP_PORT_OFFSET 的 FRP 端口（需要在 frpc 中转发 MediaMTX 的 8554 端口）
+type LiveService struct {
+	db               *gorm.DB
+	orangePiService  *OrangePiService
+	publicNetService *PublicNetService
+	authService      *AuthService
+	rtspPortOffset   int
+}
+
+// 0. NewLiveService 构造函数
+// LIVE_RTSP_PORT_OFFSET 为 RTSP 远程端口相对认证端口的偏移（默认 0，表示不提供 RTSP 地址）
+func NewLiveService(db *gorm.DB, orangePiService *OrangePiService, publicNetService *PublicNetService, authService *AuthService) *LiveService {
+	offset := 0
+	if val := os.Getenv("LIVE_RTSP_PORT_OFFSET"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			offset = parsed
+		} else {
+			log.Printf("Warning: invalid LIVE_RTSP_PORT_OFFSET=%q, RTSP urls disabled", val)
+		}
+	}
+
+	return &LiveService{
+		db:               db,
+		orangePiService:  orangePiService,
+		publicNetService: publicNetService,
+		authService:      authService,
+		rtspPortOffset:   offset,
+	}
+}
+
+// 1. Resolve 解析楼栋通道的播放地址
+// token 不为空时必须是覆盖该楼栋与通道的观看 Token 并原样使用，为空时签发只包含该通道的新 Token；
+// 楼栋绑定多台设备时并发检查健康状态，优先选择健康且最近有心跳的设备
+func (s *LiveService) Resolve(ctx context.Context, ismartID string, channel int, token string) (*LiveView, error) {
+	path := mediaMTXPathName(channel)
+	var payload *VideoTokenPayload
+	if token != "" {
+		var err error
+		if payload, err = s.authService.ValidateVideoToken(token); err != nil {
+			return nil, err
+		}
+		if payload.BuildingID != ismartID || !slices.Contains(payload.Channels, path) {
+			return nil, ErrVideoTokenScope
+		}
+		// 录像 Token（限定用途或时间范围）不能用于直播
+		if (payload.Purpose != "" && payload.Purpose != VideoTokenPurposeView) || payload.Start != 0 {
+			return nil, fmt.Errorf("%w: not a live token", ErrVideoTokenScope)
+		}
+	}
+
+	building, err := findBuildingChannel(ctx, s.db, ismartID, channel)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := availableDevices(ctx, s.db, building.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+	publicNetConfig, err := s.publicNetService.Get(ctx)
+	if err != nil || publicNetConfig == nil {
+		return nil, errors.New("public network configuration not found")
+	}
+	device, healthy := s.pickDevice(ctx, devices)
+
+	if payload == nil {
+		if token, err = s.authService.GenerateVideoToken(ctx, ismartID, []string{path}); err != nil {
+			return nil, err
+		}
+		// 读取新签发 Token 的过期时间
+		if payload, err = s.authService.ValidateVideoToken(token); err != nil {
+			return nil, err
+		}
+	}
+
+	query := url.Values{}
+	query.Set("token", token)
+	base := fmt.Sprintf("http://%s:%d/%s", publicNetConfig.ExternalIP, device.ICCTVAuthServiceRemotePort, path)
+	view := &LiveView{
+		ISmartID:   ismartID,
+		Channel:    channel,
+		Path:       path,
+		OrangePiID: device.ID,
+		Healthy:    healthy,
+		Token:      token,
+		ExpiresAt:  time.Unix(payload.Exp, 0),
+		WHEP:       base + "/whep?" + query.Encode(),
+		HLS:        base + "/index.m3u8?" + query.Encode(),
+	}
+	if s.rtspPortOffset > 0 {
+		view.RTSP = fmt.Sprintf("rtsp://%s:%d/%s?%s", publicNetConfig.ExternalIP,
+			device.ICCTVAuthServiceRemotePort+s.rtspPortOffset, path, query.Encode())
+	}
+	return view, nil
+}
+
+// pickDevice 并发健康检查，按原有顺序（最近心跳优先）返回第一台健康设备
+func (s *LiveService) pickDevice(ctx context.Context, devices []models.OrangePi) (models.OrangePi, bool) {
+	healthy := make([]bool, len(devices))
+	var wg sync.WaitGroup
+	for i := range devices {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			status, err := s.orangePiService.fetchHealth(ctx, devices[i].ICCTVAuthServiceRemotePort, liveHealthTimeout)
+			healthy[i] = err == nil && status.Status == "healthy"
+		}(i)
+	}
+	wg.Wait()
+
+	for i, device := range devices {
+		if healthy[i] {
+			return device, true
+		}
+	}
+	return devices[0], false
+}
@@ -0,0 +1,432 @@
+package services
+
+// MediaMTXSyncService Methods:
+//0. NewMediaMTXSyncService(db *gorm.DB, orangePiService *OrangePiService, auditService *AuditService) -> 初始化 MediaMTX 路径同步服务（从环境变量读取检测周期）
+//1. Start(ctx context.Context) -> 启动自动同步（NVR 地址或绑定变化后自动下发）
+//2. Preview(ctx context.Context, buildingID int64) -> 预览楼栋的期望路径配置与各设备同步状态
+//3. SyncBuilding(ctx context.Context, buildingID int64, actor Actor) -> 立即向楼栋绑定的所有设备下发路径配置
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"icctv-http-service/models"
+
+	"gorm.io/gorm"
+)
+
+// mediaMTXSyncRetryInterval 同一配置下发失败后的重试间隔
+const mediaMTXSyncRetryInterval = 10 * time.Minute
+
+// MediaMTXSyncServiceInterface 定义 MediaMTX 路径同步能力
+type MediaMTXSyncServiceInterface interface {
+	Start(ctx context.Context)                                                                     //1.自动同步
+	Preview(ctx context.Context, buildingID int64) (*MediaMTXPathPreview, error)                   //2.预览
+	SyncBuilding(ctx context.Context, buildingID int64, actor Actor) ([]MediaMTXDeviceSync, error) //3.立即同步
+}
+
+// MediaMTXPathEntry 一个期望的 MediaMTX 路径（source 中的密码已脱敏）
+type MediaMTXPathEntry struct {
+	Name    string `json:"name"`
+	NVRID   int64  `json:"nvr_id"`
+	NVRName string `json:"nvr_name"`
+	Channel int    `json:"channel"`
+	Source  string `json:"source"`
+}
+
+// MediaMTXPathPreview 楼栋的期望路径配置及各绑定设备的同步状态
+type MediaMTXPathPreview struct {
+	BuildingID int64                      `json:"building_id"`
+	Hash       string                     `json:"hash"`
+	Paths      []MediaMTXPathEntry        `json:"paths"`
+	Conflicts  []string                   `json:"conflicts"`
+	Devices    []models.MediaMTXSyncState `json:"devices"`
+}
+
+// MediaMTXDeviceSync 一台设备的同步结果
+type MediaMTXDeviceSync struct {
+	OrangePiID int64                   `json:"orangepi_id"`
+	Name       string                  `json:"name"`
+	Success    bool                    `json:"success"`
+	Error      string                  `json:"error,omitempty"`
+	Results    []models.PathSyncResult `json:"results"`
+}
+
+// mediaMTXPathConf 下发给 agent 的单个路径配置（对应 mediamtx.yml paths 下的条目）
+type mediaMTXPathConf struct {
+	Source string `json:"source"`
+}
+
+// mediaMTXDesired 楼栋的期望路径配置
+type mediaMTXDesired struct {
+	paths     map[string]mediaMTXPathConf
+	entries   []MediaMTXPathEntry
+	conflicts []string
+	hash      string
+}
+
+// MediaMTXSyncService 根据楼栋 NVR 的 RTSP 地址生成 channelN 路径，通过 agent 的
+// PUT /api/device/mediamtx/paths 下发到绑定的 OrangePi，并记录每个路径的结果
+type MediaMTXSyncService struct {
+	db              *gorm.DB
+	orangePiService *OrangePiService
+	auditService    *AuditService
+	interval        time.Duration
+}
+
+// 0. NewMediaMTXSyncService 构造函数
+// MEDIAMTX_SYNC_INTERVAL_SECONDS 控制检测配置变化的周期（默认 60，0 表示关闭自动同步）
+func NewMediaMTXSyncService(db *gorm.DB, orangePiService *OrangePiService, auditService *AuditService) *MediaMTXSyncService {
+	seconds := 60
+	if val := os.Getenv("MEDIAMTX_SYNC_INTERVAL_SECONDS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			seconds = parsed
+		} else {
+			log.Printf("Warning: invalid MEDIAMTX_SYNC_INTERVAL_SECONDS=%q, using %d", val, seconds)
+		}
+	}
+
+	return &MediaMTXSyncService{
+		db:              db,
+		orangePiService: orangePiService,
+		auditService:    auditService,
+		interval:        time.Duration(seconds) * time.Second,
+	}
+}
+
+// 1. Start 启动自动同步：定期比较每台已部署或维护中设备的期望配置哈希与最近一次成功下发的哈希，
+// NVR 的 RTSP 地址、账户或设备绑定发生变化时自动下发；失败的配置每 10 分钟重试一次
+func (s *MediaMTXSyncService) Start(ctx context.Context) {
+	if s.interval <= 0 {
+		log.Println("mediamtx auto sync disabled (MEDIAMTX_SYNC_INTERVAL_SECONDS=0)")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.syncChanged(ctx)
+			}
+		}
+	}()
+}
+
+// 2. Preview 预览楼栋的期望路径配置（密码脱敏）与各绑定设备最近一次同步状态
+func (s *MediaMTXSyncService) Preview(ctx context.Context, buildingID int64) (*MediaMTXPathPreview, error) {
+	building, err := s.findBuilding(ctx, buildingID)
+	if err != nil {
+		return nil, err
+	}
+	desired, err := s.desiredPaths(ctx, building.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &MediaMTXPathPreview{
+		BuildingID: building.ID,
+		Hash:       desired.hash,
+		Paths:      desired.entries,
+		Conflicts:  desired.conflicts,
+		Devices:    make([]models.MediaMTXSyncState, 0),
+	}
+	var deviceIDs []int64
+	if err := s.db.WithContext(ctx).Model(&models.OrangePi{}).
+		Where("building_id = ?", building.ID).
+		Pluck("id", &deviceIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(deviceIDs) > 0 {
+		if err := s.db.WithContext(ctx).
+			Where("orangepi_id IN ?", deviceIDs).
+			Order("orangepi_id asc").
+			Find(&preview.Devices).Error; err != nil {
+			return nil, err
+		}
+	}
+	return preview, nil
+}
+
+// 3. SyncBuilding 立即向楼栋绑定的所有已部署或维护中设备下发路径配置（不比较哈希），返回每台设备每个路径的结果
+func (s *MediaMTXSyncService) SyncBuilding(ctx context.Context, buildingID int64, actor Actor) ([]MediaMTXDeviceSync, error) {
+	building, err := s.findBuilding(ctx, buildingID)
+	if err != nil {
+		return nil, err
+	}
+	desired, err := s.desiredPaths(ctx, building.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []models.OrangePi
+	if err := s.db.WithContext(ctx).
+		Where("building_id = ? AND lifecycle_state IN ?", building.ID, models.LifecycleManagedStates).
+		Order("id asc").
+		Find(&devices).Error; err != nil {
+		return nil, err
+	}
+
+	results := make([]MediaMTXDeviceSync, len(devices))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, reconcileConcurrency)
+	for i := range devices {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			results[i] = s.push(ctx, devices[i], building.ID, desired)
+		}(i)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		if !result.Success {
+			failed++
+		}
+	}
+	entry := models.AuditLog{
+		Action:     "mediamtx.sync",
+		TargetType: "building",
+		TargetID:   building.ID,
+		Detail: map[string]interface{}{
+			"paths":     len(desired.paths),
+			"hash":      desired.hash,
+			"devices":   len(devices),
+			"failed":    failed,
+			"conflicts": desired.conflicts,
+		},
+		Success: failed == 0,
+	}
+	if failed > 0 {
+		entry.Error = fmt.Sprintf("%d of %d devices failed", failed, len(devices))
+	}
+	s.auditService.Record(ctx, actor, entry)
+	return results, nil
+}
+
+// syncChanged 对期望配置与最近成功下发不一致的设备执行同步
+func (s *MediaMTXSyncService) syncChanged(ctx context.Context) {
+	var devices []models.OrangePi
+	if err := s.db.WithContext(ctx).
+		Where("lifecycle_state IN ? AND building_id IS NOT NULL", models.LifecycleManagedStates).
+		Find(&devices).Error; err != nil {
+		log.Printf("mediamtx sync: failed to list devices: %v", err)
+		return
+	}
+	if len(devices) == 0 {
+		return
+	}
+
+	var states []models.MediaMTXSyncState
+	if err := s.db.WithContext(ctx).Find(&states).Error; err != nil {
+		log.Printf("mediamtx sync: failed to load sync states: %v", err)
+		return
+	}
+	stateByDevice := make(map[int64]models.MediaMTXSyncState, len(states))
+	for _, state := range states {
+		stateByDevice[state.OrangePiID] = state
+	}
+
+	desiredByBuilding := make(map[int64]*mediaMTXDesired)
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		synced int
+		failed int
+	)
+	sem := make(chan struct{}, reconcileConcurrency)
+	for _, device := range devices {
+		buildingID := *device.BuildingID
+		desired, ok := desiredByBuilding[buildingID]
+		if !ok {
+			var err error
+			desired, err = s.desiredPaths(ctx, buildingID)
+			if err != nil {
+				log.Printf("mediamtx sync: failed to build paths for building %d: %v", buildingID, err)
+				continue
+			}
+			desiredByBuilding[buildingID] = desired
+		}
+
+		if state, ok := stateByDevice[device.ID]; ok && state.Hash == desired.hash && state.BuildingID == buildingID {
+			if state.Success || time.Since(state.SyncedAt) < mediaMTXSyncRetryInterval {
+				continue
+			}
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(device models.OrangePi, buildingID int64, desired *mediaMTXDesired) {
+			defer func() { <-sem; wg.Done() }()
+			result := s.push(ctx, device, buildingID, desired)
+			mu.Lock()
+			synced++
+			if !result.Success {
+				failed++
+			}
+			mu.Unlock()
+		}(device, buildingID, desired)
+	}
+	wg.Wait()
+	if synced > 0 {
+		log.Printf("mediamtx sync: pushed paths to %d devices, %d failed", synced, failed)
+	}
+}
+
+// push 向一台设备下发路径配置并保存同步状态
+func (s *MediaMTXSyncService) push(ctx context.Context, device models.OrangePi, buildingID int64, desired *mediaMTXDesired) MediaMTXDeviceSync {
+	result := MediaMTXDeviceSync{
+		OrangePiID: device.ID,
+		Name:       device.Name,
+		Results:    make([]models.PathSyncResult, 0),
+	}
+
+	var err error
+	if device.DeviceIDMismatch {
+		// 端口上应答的不是该设备，下发会写到别的设备上
+		err = ErrDeviceIDMismatch
+	} else {
+		body := map[string]interface{}{
+			"paths": desired.paths,
+			"prune": true, // 删除设备上不再需要的 channelN 路径
+		}
+		var out struct {
+			Results []models.PathSyncResult `json:"results"`
+		}
+		err = s.orangePiService.callRemote(ctx, device.ICCTVAuthServiceRemotePort, http.MethodPut, "/api/device/mediamtx/paths", body, 30*time.Second, &out)
+		if err == nil {
+			result.Results = out.Results
+		}
+	}
+
+	result.Success = err == nil
+	if err != nil {
+		result.Error = err.Error()
+	}
+	for _, path := range result.Results {
+		if !path.Success {
+			result.Success = false
+		}
+	}
+
+	state := models.MediaMTXSyncState{
+		OrangePiID: device.ID,
+		BuildingID: buildingID,
+		Hash:       desired.hash,
+		Paths:      len(desired.paths),
+		Success:    result.Success,
+		Results:    result.Results,
+		Error:      result.Error,
+		SyncedAt:   time.Now(),
+	}
+	if err := s.saveState(ctx, &state); err != nil {
+		log.Printf("mediamtx sync: failed to save state for orangepi %d: %v", device.ID, err)
+	}
+	return result
+}
+
+// saveState 按设备覆盖保存最近一次同步状态
+func (s *MediaMTXSyncService) saveState(ctx context.Context, state *models.MediaMTXSyncState) error {
+	db := s.db.WithContext(context.WithoutCancel(ctx))
+	var existing models.MediaMTXSyncState
+	err := db.Where("orangepi_id = ?", state.OrangePiID).First(&existing).Error
+	if err == nil {
+		state.ID = existing.ID
+		state.CreatedAt = existing.CreatedAt
+		return db.Save(state).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return db.Create(state).Error
+}
+
+// desiredPaths 由楼栋所有 NVR 的通道生成期望路径配置：channelN -> RTSP 地址（地址未带账户时注入 NVR 管理员账户）
+// 多个 NVR 使用相同通道号时保留 ID 较小的 NVR，并记录冲突
+func (s *MediaMTXSyncService) desiredPaths(ctx context.Context, buildingID int64) (*mediaMTXDesired, error) {
+	var nvrs []models.NVR
+	if err := s.db.WithContext(ctx).Where("building_id = ?", buildingID).Order("id asc").Find(&nvrs).Error; err != nil {
+		return nil, err
+	}
+
+	desired := &mediaMTXDesired{
+		paths:     make(map[string]mediaMTXPathConf),
+		entries:   make([]MediaMTXPathEntry, 0),
+		conflicts: make([]string, 0),
+	}
+	owners := make(map[string]models.NVR)
+	for _, nvr := range nvrs {
+		for _, ch := range nvr.RTSPUrls {
+			if ch.URL == "" {
+				continue
+			}
+			name := mediaMTXPathName(ch.Channel)
+			if owner, ok := owners[name]; ok {
+				desired.conflicts = append(desired.conflicts,
+					fmt.Sprintf("%s: nvr %d (%s) ignored, already provided by nvr %d (%s)", name, nvr.ID, nvr.Name, owner.ID, owner.Name))
+				continue
+			}
+			source, masked := rtspSourceWithCredentials(ch.URL, nvr.AdminUser)
+			owners[name] = nvr
+			desired.paths[name] = mediaMTXPathConf{Source: source}
+			desired.entries = append(desired.entries, MediaMTXPathEntry{
+				Name:    name,
+				NVRID:   nvr.ID,
+				NVRName: nvr.Name,
+				Channel: ch.Channel,
+				Source:  masked,
+			})
+		}
+	}
+	sort.Slice(desired.entries, func(i, j int) bool { return desired.entries[i].Channel < desired.entries[j].Channel })
+
+	// encoding/json 按 key 排序输出 map，哈希与 NVR 顺序无关
+	raw, err := json.Marshal(desired.paths)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(raw)
+	desired.hash = hex.EncodeToString(sum[:])
+	return desired, nil
+}
+
+// rtspSourceWithCredentials 地址中没有账户时注入管理员账户，返回实际地址与脱敏地址
+func rtspSourceWithCredentials(raw string, admin models.AdminUser) (string, string) {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		// 无法解析时不返回原文，避免泄露其中的密码
+		return raw, "(invalid url)"
+	}
+	if parsed.User == nil && admin.Name != "" {
+		parsed.User = url.UserPassword(admin.Name, admin.Password)
+	}
+	return parsed.String(), parsed.Redacted()
+}
+
+func (s *MediaMTXSyncService) findBuilding(ctx context.Context, id int64) (*models.Building, error) {
+	var building models.Building
+	if err := s.db.WithContext(ctx).First(&building, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBuildingNotFound
+		}
+		return nil, err
+	}
+	return &building, nil
+}
@@ -2,14 +2,15 @@ package services
 
 // NVRService Methods:
 //0. NewNVRService(db *gorm.DB) -> 初始化NVR服务
-//1. List(ctx context.Context) -> 查询所有NVR
+//1. List(ctx context.Context, scope *SiteScope) -> 查询NVR（scope 不为空时只返回范围内建筑的NVR）
 //2. GetByID(ctx context.Context, id int64) -> 根据ID查询NVR
-//3. Create(ctx context.Context, payload models.NVR) -> 创建NVR
-//4. Update(ctx context.Context, id int64, payload models.NVR) -> 更新NVR信息
+//3. Create(ctx context.Context, payload models.NVR, actor Actor) -> 创建NVR
+//4. Update(ctx context.Context, id int64, payload models.NVR, actor Actor) -> 更新NVR信息
 //5. Delete(ctx context.Context, id int64) -> 删除NVR
 
 import (
 	"context"
+	"fmt"
 
 	"icctv-http-service/models"
 
@@ -18,11 +19,11 @@ import (
 
 // NVRServiceInterface 定义NVR业务能力
 type NVRServiceInterface interface {
-	List(ctx context.Context) ([]models.NVR, error)                                //1.查询NVR列表
-	GetByID(ctx context.Context, id int64) (*models.NVR, error)                    //2.根据ID查询NVR
-	Create(ctx context.Context, payload models.NVR) (*models.NVR, error)           //3.创建NVR
-	Update(ctx context.Context, id int64, payload models.NVR) (*models.NVR, error) //4.更新NVR
-	Delete(ctx context.Context, id int64) error                                    //5.删除NVR
+	List(ctx context.Context, scope *SiteScope) ([]models.NVR, error)                           //1.查询NVR列表
+	GetByID(ctx context.Context, id int64) (*models.NVR, error)                                 //2.根据ID查询NVR
+	Create(ctx context.Context, payload models.NVR, actor Actor) (*models.NVR, error)           //3.创建NVR
+	Update(ctx context.Context, id int64, payload models.NVR, actor Actor) (*models.NVR, error) //4.更新NVR
+	Delete(ctx context.Context, id int64) error                                                 //5.删除NVR
 }
 
 // NVRService NVR业务逻辑
@@ -35,12 +36,19 @@ func NewNVRService(db *gorm.DB) *NVRService {
 	return &NVRService{db: db}
 }
 
-// 1. List NVR列表
-func (s *NVRService) List(ctx context.Context) ([]models.NVR, error) {
+// 1. List NVR列表，scope 不为空时只返回范围内建筑的NVR，只开放部分通道的建筑去掉范围外的通道
+func (s *NVRService) List(ctx context.Context, scope *SiteScope) ([]models.NVR, error) {
 	var items []models.NVR
-	if err := s.db.WithContext(ctx).Preload("Building").Find(&items).Error; err != nil {
+	tx := s.db.WithContext(ctx).Preload("Building")
+	if scope != nil {
+		tx = tx.Where("building_id IN ?", scope.BuildingIDs)
+	}
+	if err := tx.Find(&items).Error; err != nil {
 		return nil, err
 	}
+	for i := range items {
+		scope.LimitChannels(&items[i])
+	}
 	return items, nil
 }
 
@@ -53,20 +61,32 @@ func (s *NVRService) GetByID(ctx context.Context, id int64) (*models.NVR, error)
 	return &item, nil
 }
 
-// 3. Create 创建NVR
-func (s *NVRService) Create(ctx context.Context, payload models.NVR) (*models.NVR, error) {
-	if err := s.db.WithContext(ctx).Create(&payload).Error; err != nil {
+// 3. Create 创建NVR（创建时已绑定建筑则记录绑定变更；通道位置必须是所属建筑的楼层/区域）
+func (s *NVRService) Create(ctx context.Context, payload models.NVR, actor Actor) (*models.NVR, error) {
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkChannelPlacements(tx, payload.BuildingID, payload.RTSPUrls); err != nil {
+			return err
+		}
+		if err := tx.Create(&payload).Error; err != nil {
+			return err
+		}
+		return recordNVRBinding(tx, payload.ID, 0, payload.BuildingID, "created", actor.Username)
+	}); err != nil {
 		return nil, err
 	}
 	return &payload, nil
 }
 
-// 4. Update 更新NVR
-func (s *NVRService) Update(ctx context.Context, id int64, payload models.NVR) (*models.NVR, error) {
+// 4. Update 更新NVR（建筑绑定只能通过绑定/迁移接口变更；提交的通道位置按所属建筑校验）
+func (s *NVRService) Update(ctx context.Context, id int64, payload models.NVR, actor Actor) (*models.NVR, error) {
 	var item models.NVR
 	if err := s.db.WithContext(ctx).First(&item, id).Error; err != nil {
 		return nil, err
 	}
+	// 绑定/迁移接口负责建筑校验与绑定历史，这里只接受与当前相同的建筑
+	if payload.BuildingID != 0 && payload.BuildingID != item.BuildingID {
+		return nil, fmt.Errorf("%w: use /api/bind/building-nvr", ErrBindingViaUpdate)
+	}
 
 	if payload.Name != "" {
 		item.Name = payload.Name
@@ -74,9 +94,6 @@ func (s *NVRService) Update(ctx context.Context, id int64, payload models.NVR) (
 	if payload.URL != "" {
 		item.URL = payload.URL
 	}
-	if payload.BuildingID != 0 {
-		item.BuildingID = payload.BuildingID
-	}
 	if payload.AdminUser.Name != "" || payload.AdminUser.Password != "" {
 		item.AdminUser = payload.AdminUser
 	}
@@ -87,7 +104,12 @@ func (s *NVRService) Update(ctx context.Context, id int64, payload models.NVR) (
 		item.RTSPUrls = payload.RTSPUrls
 	}
 
-	if err := s.db.WithContext(ctx).Save(&item).Error; err != nil {
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkChannelPlacements(tx, item.BuildingID, payload.RTSPUrls); err != nil {
+			return err
+		}
+		return tx.Save(&item).Error
+	}); err != nil {
 		return nil, err
 	}
 	return &item, nil
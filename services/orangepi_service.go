@@ -1,10 +1,10 @@
 package services
 
 // OrangePiService Methods:
-//0. NewOrangePiService(db *gorm.DB) -> 初始化设备服务
-//1. List(ctx context.Context, ismartId string) -> 按ismartId筛选设备
-//2. Create(ctx context.Context, payload models.OrangePi) -> 创建设备
-//3. Update(ctx context.Context, id int64, payload models.OrangePi) -> 更新设备
+//0. NewOrangePiService(db *gorm.DB, publicNetService *PublicNetService, portPool *PortPoolService) -> 初始化设备服务
+//1. List(ctx context.Context, ismartId string, scope *SiteScope) -> 按ismartId与站点范围筛选设备
+//2. Create(ctx context.Context, payload models.OrangePi, actor Actor) -> 创建设备
+//3. Update(ctx context.Context, id int64, payload models.OrangePi, actor Actor) -> 更新设备
 //4. Delete(ctx context.Context, id int64) -> 删除设备
 
 import (
@@ -13,6 +13,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"time"
 
@@ -21,15 +23,31 @@ import (
 	"gorm.io/gorm"
 )
 
+// 错误定义
+var (
+	ErrDeviceIDMismatch        = errors.New("device_id mismatch")
+	ErrDeviceIDConflict        = errors.New("device_id already assigned to another orangepi")
+	ErrNoReportedDeviceID      = errors.New("no reported device_id to accept")
+	ErrPortMigrationInProgress = errors.New("port migration in progress")
+	ErrPortMigrationFailed     = errors.New("port migration failed, resolve it before changing ports")
+	ErrDeviceUnauthorized      = errors.New("invalid device key")
+	ErrNoAvailableDevice       = errors.New("no available orangepi bound to building")
+)
+
 // OrangePiServiceInterface 定义设备业务能力
 type OrangePiServiceInterface interface {
-	List(ctx context.Context, ismartId string) ([]models.OrangePi, error)                                    //1.查询设备
-	Create(ctx context.Context, payload models.OrangePi) (*models.OrangePi, error)                           //2.创建设备
-	Update(ctx context.Context, id int64, payload models.OrangePi) (*models.OrangePi, error)                 //3.更新设备
-	Delete(ctx context.Context, id int64) error                                                              //4.删除设备
-	RemoteUpdatePorts(ctx context.Context, id int64, sshPort int, authPort int) (*RemoteUpdateResult, error) //5.远程更新端口
-	RemoteGetInfo(ctx context.Context, id int64) (*RemoteDeviceInfo, error)                                  //6.远程获取设备信息
-	RemoteHealthCheck(ctx context.Context, id int64) (*RemoteHealthStatus, error)                            //7.远程健康检查
+	List(ctx context.Context, ismartId string, scope *SiteScope) ([]models.OrangePi, error)               //1.查询设备
+	Create(ctx context.Context, payload models.OrangePi, actor Actor) (*models.OrangePi, error)           //2.创建设备
+	Update(ctx context.Context, id int64, payload models.OrangePi, actor Actor) (*models.OrangePi, error) //3.更新设备
+	Delete(ctx context.Context, id int64) error                                                           //4.删除设备
+	RemoteGetInfo(ctx context.Context, id int64) (*RemoteDeviceInfo, error)                               //5.远程获取设备信息
+	RemoteHealthCheck(ctx context.Context, id int64) (*RemoteHealthStatus, error)                         //6.远程健康检查
+	AcceptReportedDeviceID(ctx context.Context, id int64) (*models.OrangePi, error)                       //7.确认端口上应答的设备ID
+	PortPoolReport(ctx context.Context) (*PortPoolReport, error)                                          //8.端口池使用情况
+	RegisterJobHandlers(jobs *JobService)                                                                 //9.注册异步任务
+	Heartbeat(ctx context.Context, deviceKey string, payload DeviceHeartbeat) (*models.OrangePi, error)   //10.设备心跳
+	VersionReport(ctx context.Context) (*FleetVersionReport, error)                                       //11.版本分布
+	BuildingIDByISmartID(ctx context.Context, ismartID string) (int64, error)                             //12.按ismartId查询建筑ID
 }
 
 // RemoteUpdateResult 远程更新结果
@@ -37,17 +55,25 @@ type RemoteUpdateResult struct {
 	Success   bool   `json:"success"`
 	Message   string `json:"message"`
 	Restarted bool   `json:"restarted"`
+
+	// 经端口迁移执行时附带迁移记录
+	Migration *models.PortMigration `json:"migration,omitempty"`
 }
 
 // RemoteDeviceInfo 远程设备信息
 type RemoteDeviceInfo struct {
 	DeviceID           string   `json:"device_id"`
+	AgentVersion       string   `json:"agent_version"`
 	MediaMTXVersion    string   `json:"mediamtx_version"`
 	FRPCServer         string   `json:"frpc_server"`
 	FRPCAuthRemotePort int      `json:"frpc_auth_remote_port"`
 	FRPCSSHRemotePort  int      `json:"frpc_ssh_remote_port"`
 	AvailableChannels  []string `json:"available_channels"`
 	Status             string   `json:"status"`
+
+	// 以下字段由中心端校验设备ID后填充
+	IdentityMismatch bool   `json:"identity_mismatch"`
+	ExpectedDeviceID string `json:"expected_device_id,omitempty"`
 }
 
 // RemoteHealthStatus 远程健康状态
@@ -59,26 +85,52 @@ type RemoteHealthStatus struct {
 	FRPCStatus     string          `json:"frpc_status"`
 }
 
+// DeviceHeartbeat 设备心跳上报内容
+type DeviceHeartbeat struct {
+	DeviceID        string `json:"device_id"`
+	AgentVersion    string `json:"agent_version"`
+	MediaMTXVersion string `json:"mediamtx_version"`
+}
+
+// VersionCount 某个版本的设备数量（版本为空表示未上报）
+type VersionCount struct {
+	Version string `json:"version"`
+	Count   int    `json:"count"`
+}
+
+// FleetVersionReport 全部设备的版本分布
+type FleetVersionReport struct {
+	Total    int            `json:"total"`
+	Agent    []VersionCount `json:"agent"`
+	MediaMTX []VersionCount `json:"mediamtx"`
+}
+
 // OrangePiService 设备业务逻辑
 type OrangePiService struct {
 	db               *gorm.DB
 	publicNetService *PublicNetService
+	portPool         *PortPoolService
 }
 
 // 0. NewOrangePiService 构造函数
-func NewOrangePiService(db *gorm.DB, publicNetService *PublicNetService) *OrangePiService {
+func NewOrangePiService(db *gorm.DB, publicNetService *PublicNetService, portPool *PortPoolService) *OrangePiService {
 	return &OrangePiService{
 		db:               db,
 		publicNetService: publicNetService,
+		portPool:         portPool,
 	}
 }
 
-// 1. List 查询设备列表
-func (s *OrangePiService) List(ctx context.Context, ismartId string) ([]models.OrangePi, error) {
+// 1. List 查询设备列表，scope 不为空时只返回绑定到范围内建筑的设备
+func (s *OrangePiService) List(ctx context.Context, ismartId string, scope *SiteScope) ([]models.OrangePi, error) {
 	var devices []models.OrangePi
 	tx := s.db.WithContext(ctx).Model(&models.OrangePi{})
 	if ismartId != "" {
-		tx = tx.Where("ismart_id = ?", ismartId)
+		tx = tx.Joins("JOIN buildings ON buildings.id = orangepis.building_id").
+			Where("buildings.ismart_id = ?", ismartId)
+	}
+	if scope != nil {
+		tx = tx.Where("orangepis.building_id IN ?", scope.BuildingIDs)
 	}
 	if err := tx.Preload("Building").Find(&devices).Error; err != nil {
 		return nil, err
@@ -86,37 +138,98 @@ func (s *OrangePiService) List(ctx context.Context, ismartId string) ([]models.O
 	return devices, nil
 }
 
-// 2. Create 创建设备
-func (s *OrangePiService) Create(ctx context.Context, payload models.OrangePi) (*models.OrangePi, error) {
-	if err := s.db.WithContext(ctx).Create(&payload).Error; err != nil {
+// 2. Create 创建设备（未指定端口时从端口池自动分配，未指定生命周期状态时为 deployed；记录初始生命周期状态，创建时已绑定建筑则记录绑定变更）
+func (s *OrangePiService) Create(ctx context.Context, payload models.OrangePi, actor Actor) (*models.OrangePi, error) {
+	if payload.LifecycleState == "" {
+		payload.LifecycleState = models.LifecycleDeployed
+	}
+	if !models.IsLifecycleState(payload.LifecycleState) {
+		return nil, fmt.Errorf("%w: %s", ErrLifecycleStateInvalid, payload.LifecycleState)
+	}
+	payload.LifecycleReason = "created"
+	authUnset := payload.ICCTVAuthServiceRemotePort == 0
+	sshUnset := payload.SSHRemotePort == 0
+	if authUnset != sshUnset {
+		return nil, errors.New("icctv_auth_service_remote_port and ssh_remote_port must be given together or both omitted")
+	}
+
+	err := s.portPool.Transaction(ctx, func(tx *gorm.DB) error {
+		if err := checkBuildingExists(tx, payload.BuildingID); err != nil {
+			return err
+		}
+		if authUnset {
+			authPort, sshPort, err := s.portPool.Allocate(tx)
+			if err != nil {
+				return err
+			}
+			payload.ICCTVAuthServiceRemotePort = authPort
+			payload.SSHRemotePort = sshPort
+		} else if err := s.portPool.Validate(tx, 0, payload.ICCTVAuthServiceRemotePort, payload.SSHRemotePort); err != nil {
+			return err
+		}
+		if err := tx.Create(&payload).Error; err != nil {
+			return err
+		}
+		if err := recordInitialLifecycle(tx, &payload, actor.Username); err != nil {
+			return err
+		}
+		if err := recordOrangePiBinding(tx, payload.ID, nil, payload.BuildingID, "created", actor.Username); err != nil {
+			return err
+		}
+		// 重新读取以带上关联建筑(返回派生的 ismartid)
+		return tx.Preload("Building").First(&payload, payload.ID).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &payload, nil
 }
 
-// 3. Update 更新设备
-func (s *OrangePiService) Update(ctx context.Context, id int64, payload models.OrangePi) (*models.OrangePi, error) {
+// 3. Update 更新设备：在端口池事务内读取并只写入变更的字段，避免覆盖后台任务（迁移、对账、心跳等）同时写入的字段；
+// 建筑绑定只能通过绑定/迁移接口变更，端口迁移进行中或失败时不能修改端口
+func (s *OrangePiService) Update(ctx context.Context, id int64, payload models.OrangePi, actor Actor) (*models.OrangePi, error) {
 	var device models.OrangePi
-	if err := s.db.WithContext(ctx).First(&device, id).Error; err != nil {
-		return nil, err
-	}
+	err := s.portPool.Transaction(ctx, func(tx *gorm.DB) error {
+		if err := tx.Preload("Building").First(&device, id).Error; err != nil {
+			return err
+		}
+		// 绑定/迁移接口负责 ismartId、健康检查与未绑定等校验，这里只接受与当前相同的建筑
+		if payload.BuildingID != nil && (device.BuildingID == nil || *device.BuildingID != *payload.BuildingID) {
+			return fmt.Errorf("%w: use /api/bind/building-orangepi", ErrBindingViaUpdate)
+		}
 
-	// 更新字段
-	if payload.ISmartID != "" {
-		device.ISmartID = payload.ISmartID
-	}
-	if payload.Name != "" {
-		device.Name = payload.Name
-	}
-	if payload.ICCTVAuthServiceRemotePort != 0 {
-		device.ICCTVAuthServiceRemotePort = payload.ICCTVAuthServiceRemotePort
-	}
-	if payload.SSHRemotePort != 0 {
-		device.SSHRemotePort = payload.SSHRemotePort
-	}
-	device.IsActive = payload.IsActive
+		// 更新字段
+		var columns []string
+		if payload.Name != "" {
+			device.Name = payload.Name
+			columns = append(columns, "name")
+		}
+		if payload.ICCTVAuthServiceRemotePort != 0 || payload.SSHRemotePort != 0 {
+			switch device.PortMigrationState {
+			case models.PortMigrationStateMigrating:
+				return ErrPortMigrationInProgress
+			case models.PortMigrationStateFailed:
+				return ErrPortMigrationFailed
+			}
+			if payload.ICCTVAuthServiceRemotePort != 0 {
+				device.ICCTVAuthServiceRemotePort = payload.ICCTVAuthServiceRemotePort
+			}
+			if payload.SSHRemotePort != 0 {
+				device.SSHRemotePort = payload.SSHRemotePort
+			}
+			// 端口变更时校验范围及唯一性
+			if err := s.portPool.Validate(tx, device.ID, device.ICCTVAuthServiceRemotePort, device.SSHRemotePort); err != nil {
+				return err
+			}
+			columns = append(columns, "icctv_auth_service_remote_port", "ssh_remote_port")
+		}
+		if len(columns) == 0 {
+			return nil
+		}
 
-	if err := s.db.WithContext(ctx).Save(&device).Error; err != nil {
+		return tx.Model(&device).Select(columns).Updates(&device).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &device, nil
@@ -127,124 +240,345 @@ func (s *OrangePiService) Delete(ctx context.Context, id int64) error {
 	return s.db.WithContext(ctx).Delete(&models.OrangePi{}, id).Error
 }
 
-// 5. RemoteUpdatePorts 远程更新OrangePi端口
-func (s *OrangePiService) RemoteUpdatePorts(ctx context.Context, id int64, sshPort int, authPort int) (*RemoteUpdateResult, error) {
+// 5. RemoteGetInfo 远程获取设备信息
+func (s *OrangePiService) RemoteGetInfo(ctx context.Context, id int64) (*RemoteDeviceInfo, error) {
 	// 获取设备信息
 	var device models.OrangePi
 	if err := s.db.WithContext(ctx).First(&device, id).Error; err != nil {
 		return nil, err
 	}
 
-	// 获取公网配置
-	publicNetConfig, err := s.publicNetService.Get(ctx)
-	if err != nil || publicNetConfig == nil {
-		return nil, errors.New("public network configuration not found")
+	var deviceInfo RemoteDeviceInfo
+	if err := s.callRemote(ctx, device.ICCTVAuthServiceRemotePort, http.MethodGet, "/api/device/info", nil, 15*time.Second, &deviceInfo); err != nil {
+		return nil, err
 	}
 
-	// 构建远程URL
-	remoteURL := fmt.Sprintf("http://%s:%d/api/device/frpc/ports",
-		publicNetConfig.ExternalIP, device.ICCTVAuthServiceRemotePort)
+	// 校验端口上应答的设备ID
+	if err := s.reconcileDeviceID(ctx, &device, deviceInfo.DeviceID); err != nil {
+		return nil, err
+	}
+	deviceInfo.IdentityMismatch = device.DeviceIDMismatch
+	if device.DeviceIDMismatch && device.DeviceID != nil {
+		deviceInfo.ExpectedDeviceID = *device.DeviceID
+	} else if err := s.recordVersions(ctx, &device, deviceInfo.AgentVersion, deviceInfo.MediaMTXVersion); err != nil {
+		return nil, err
+	}
 
-	// 构建请求体
-	requestBody := map[string]int{
-		"orangepi_ssh_remote_port":        sshPort,
-		"icctv_orangepi_auth_remote_port": authPort,
+	return &deviceInfo, nil
+}
+
+// 6. RemoteHealthCheck 远程健康检查
+func (s *OrangePiService) RemoteHealthCheck(ctx context.Context, id int64) (*RemoteHealthStatus, error) {
+	// 获取设备信息
+	var device models.OrangePi
+	if err := s.db.WithContext(ctx).First(&device, id).Error; err != nil {
+		return nil, err
 	}
 
-	jsonBody, err := json.Marshal(requestBody)
+	return s.fetchHealth(ctx, device.ICCTVAuthServiceRemotePort, 10*time.Second)
+}
+
+// 7. AcceptReportedDeviceID 确认端口上实际应答的设备ID为该设备的新身份（更换硬件或修正端口后使用）
+func (s *OrangePiService) AcceptReportedDeviceID(ctx context.Context, id int64) (*models.OrangePi, error) {
+	var device models.OrangePi
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&device, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrangePiNotFound
+			}
+			return err
+		}
+		if !device.DeviceIDMismatch || device.ReportedDeviceID == "" {
+			return ErrNoReportedDeviceID
+		}
+
+		// 该设备ID不能已被其他 OrangePi 占用
+		taken, err := deviceIDTaken(tx, device.ReportedDeviceID, device.ID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrDeviceIDConflict
+		}
+
+		reported := device.ReportedDeviceID
+		device.DeviceID = &reported
+		device.ReportedDeviceID = ""
+		device.DeviceIDMismatch = false
+		return tx.Model(&device).Select("device_id", "reported_device_id", "device_id_mismatch").Updates(&device).Error
+	})
 	if err != nil {
 		return nil, err
 	}
+	return &device, nil
+}
 
-	// 发送HTTP请求
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Post(remoteURL, "application/json", bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to remote device: %w", err)
+// 8. PortPoolReport 端口池使用情况
+func (s *OrangePiService) PortPoolReport(ctx context.Context) (*PortPoolReport, error) {
+	return s.portPool.Report(ctx)
+}
+
+// 9. RegisterJobHandlers 注册远程操作的异步任务类型，参数与同步接口一致（端口更新任务由 PortMigrationService 注册）
+func (s *OrangePiService) RegisterJobHandlers(jobs *JobService) {
+	jobs.RegisterHandler(models.JobTypeRemoteInfo, func(ctx context.Context, task JobTask) (interface{}, error) {
+		return s.RemoteGetInfo(ctx, task.OrangePiID)
+	})
+	jobs.RegisterHandler(models.JobTypeHealthCheck, func(ctx context.Context, task JobTask) (interface{}, error) {
+		return s.RemoteHealthCheck(ctx, task.OrangePiID)
+	})
+}
+
+// 10. Heartbeat 设备使用设备凭证(X-Device-Key)上报心跳与版本
+func (s *OrangePiService) Heartbeat(ctx context.Context, deviceKey string, payload DeviceHeartbeat) (*models.OrangePi, error) {
+	if deviceKey == "" {
+		return nil, ErrDeviceUnauthorized
+	}
+	var device models.OrangePi
+	if err := s.db.WithContext(ctx).Where("device_key = ?", deviceKey).First(&device).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeviceUnauthorized
+		}
+		return nil, err
+	}
+	if device.LifecycleState == models.LifecycleRetired {
+		return nil, fmt.Errorf("%w: device retired", ErrDeviceUnauthorized)
 	}
-	defer resp.Body.Close()
 
-	var result RemoteUpdateResult
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	// 凭证被复制到其他硬件上时拒绝；尚未记录设备ID时按首次获取处理
+	if payload.DeviceID != "" {
+		if device.DeviceID != nil && *device.DeviceID != payload.DeviceID {
+			return nil, fmt.Errorf("%w: device key belongs to %s", ErrDeviceIDMismatch, *device.DeviceID)
+		}
+		if device.DeviceID == nil {
+			if err := s.reconcileDeviceID(ctx, &device, payload.DeviceID); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	// 如果远程更新成功，更新本地数据库
-	if result.Success {
-		device.SSHRemotePort = sshPort
-		device.ICCTVAuthServiceRemotePort = authPort
-		if err := s.db.WithContext(ctx).Save(&device).Error; err != nil {
+	now := time.Now()
+	device.LastHeartbeatAt = &now
+	if err := s.db.WithContext(ctx).Model(&device).Update("last_heartbeat_at", now).Error; err != nil {
+		return nil, err
+	}
+	// 部署中的设备首次心跳即视为上线
+	if device.LifecycleState == models.LifecycleProvisioning {
+		if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return transitionLifecycle(tx, &device, models.LifecycleDeployed, "first heartbeat", "device")
+		}); err != nil && !errors.Is(err, ErrLifecycleTransition) {
 			return nil, err
 		}
 	}
-
-	return &result, nil
+	if err := s.recordVersions(ctx, &device, payload.AgentVersion, payload.MediaMTXVersion); err != nil {
+		return nil, err
+	}
+	return &device, nil
 }
 
-// 6. RemoteGetInfo 远程获取设备信息
-func (s *OrangePiService) RemoteGetInfo(ctx context.Context, id int64) (*RemoteDeviceInfo, error) {
-	// 获取设备信息
-	var device models.OrangePi
-	if err := s.db.WithContext(ctx).First(&device, id).Error; err != nil {
+// 11. VersionReport 按 agent / MediaMTX 版本统计设备数量（按版本字符串降序，未上报的排最后）
+func (s *OrangePiService) VersionReport(ctx context.Context) (*FleetVersionReport, error) {
+	var total int64
+	if err := s.db.WithContext(ctx).Model(&models.OrangePi{}).Count(&total).Error; err != nil {
 		return nil, err
 	}
 
-	// 获取公网配置
-	publicNetConfig, err := s.publicNetService.Get(ctx)
-	if err != nil || publicNetConfig == nil {
-		return nil, errors.New("public network configuration not found")
+	report := &FleetVersionReport{Total: int(total)}
+	for column, dst := range map[string]*[]VersionCount{
+		"agent_version":    &report.Agent,
+		"mediamtx_version": &report.MediaMTX,
+	} {
+		// 迁移前已存在的设备版本列为 NULL，与空字符串合并
+		expr := "COALESCE(" + column + ", '')"
+		var rows []VersionCount
+		if err := s.db.WithContext(ctx).Model(&models.OrangePi{}).
+			Select(expr + " AS version, COUNT(*) AS count").
+			Group(expr).
+			Order("version desc").
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		*dst = rows
 	}
+	return report, nil
+}
 
-	// 构建远程URL
-	remoteURL := fmt.Sprintf("http://%s:%d/api/device/info",
-		publicNetConfig.ExternalIP, device.ICCTVAuthServiceRemotePort)
-
-	// 发送HTTP请求
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Get(remoteURL)
+// 12. BuildingIDByISmartID 按 ismartId 查询建筑ID（兼容按 ismartid 指定建筑的请求）
+func (s *OrangePiService) BuildingIDByISmartID(ctx context.Context, ismartID string) (int64, error) {
+	building, err := findBuildingByISmartID(ctx, s.db, ismartID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to remote device: %w", err)
+		if errors.Is(err, ErrBuildingNotFound) {
+			return 0, fmt.Errorf("%w: %s", ErrBuildingNotFound, ismartID)
+		}
+		return 0, err
 	}
-	defer resp.Body.Close()
+	return building.ID, nil
+}
 
-	var deviceInfo RemoteDeviceInfo
-	if err := json.NewDecoder(resp.Body).Decode(&deviceInfo); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+// checkBuildingExists 绑定的建筑必须存在（未绑定时不检查）
+func checkBuildingExists(tx *gorm.DB, buildingID *int64) error {
+	if buildingID == nil {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&models.Building{}).Where("id = ?", *buildingID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: %d", ErrBuildingNotFound, *buildingID)
 	}
+	return nil
+}
 
-	return &deviceInfo, nil
+// recordVersions 记录设备上报的版本，空值表示未知，不覆盖已有记录
+func (s *OrangePiService) recordVersions(ctx context.Context, device *models.OrangePi, agentVersion, mediaMTXVersion string) error {
+	if agentVersion == "" && mediaMTXVersion == "" {
+		return nil
+	}
+	if agentVersion != "" {
+		device.AgentVersion = agentVersion
+	}
+	if mediaMTXVersion != "" {
+		device.MediaMTXVersion = mediaMTXVersion
+	}
+	now := time.Now()
+	device.VersionReportedAt = &now
+	return s.db.WithContext(ctx).Model(device).
+		Select("agent_version", "mediamtx_version", "version_reported_at").
+		Updates(device).Error
 }
 
-// 7. RemoteHealthCheck 远程健康检查
-func (s *OrangePiService) RemoteHealthCheck(ctx context.Context, id int64) (*RemoteHealthStatus, error) {
-	// 获取设备信息
-	var device models.OrangePi
-	if err := s.db.WithContext(ctx).First(&device, id).Error; err != nil {
+// reconcileDeviceID 首次获取到设备ID时写入，之后若端口上应答的设备ID不一致则标记
+func (s *OrangePiService) reconcileDeviceID(ctx context.Context, device *models.OrangePi, reported string) error {
+	if reported == "" {
+		return nil
+	}
+
+	switch {
+	case device.DeviceID == nil:
+		// 首次写入前确认该ID没有被其他设备记录占用
+		taken, err := deviceIDTaken(s.db.WithContext(ctx), reported, device.ID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			device.ReportedDeviceID = reported
+			device.DeviceIDMismatch = true
+		} else {
+			device.DeviceID = &reported
+			device.ReportedDeviceID = ""
+			device.DeviceIDMismatch = false
+		}
+	case *device.DeviceID != reported:
+		if device.DeviceIDMismatch && device.ReportedDeviceID == reported {
+			return nil
+		}
+		log.Printf("orangepi %d: device_id mismatch, expected %s but %s answered on port %d",
+			device.ID, *device.DeviceID, reported, device.ICCTVAuthServiceRemotePort)
+		device.ReportedDeviceID = reported
+		device.DeviceIDMismatch = true
+	default:
+		if !device.DeviceIDMismatch {
+			return nil
+		}
+		device.ReportedDeviceID = ""
+		device.DeviceIDMismatch = false
+	}
+
+	return s.db.WithContext(ctx).Model(device).
+		Select("device_id", "reported_device_id", "device_id_mismatch").
+		Updates(device).Error
+}
+
+// deviceIDTaken 设备ID是否已被其他设备记录占用（包括回收站中的记录，device_id 唯一索引包含已删除的记录）
+func deviceIDTaken(tx *gorm.DB, deviceID string, excludeID int64) (bool, error) {
+	var count int64
+	if err := tx.Unscoped().Model(&models.OrangePi{}).
+		Where("device_id = ? AND id <> ?", deviceID, excludeID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// pushPorts 通过 viaAuthPort 通知设备修改 FRPC 远程端口并重启 frpc
+func (s *OrangePiService) pushPorts(ctx context.Context, viaAuthPort int, sshPort int, authPort int) (*RemoteUpdateResult, error) {
+	requestBody := map[string]int{
+		"orangepi_ssh_remote_port":        sshPort,
+		"icctv_orangepi_auth_remote_port": authPort,
+	}
+
+	var result RemoteUpdateResult
+	if err := s.callRemote(ctx, viaAuthPort, http.MethodPost, "/api/device/frpc/ports", requestBody, 30*time.Second, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// fetchHealth 访问指定认证端口上的 /health
+func (s *OrangePiService) fetchHealth(ctx context.Context, authPort int, timeout time.Duration) (*RemoteHealthStatus, error) {
+	var healthStatus RemoteHealthStatus
+	if err := s.callRemote(ctx, authPort, http.MethodGet, "/health", nil, timeout, &healthStatus); err != nil {
+		return nil, err
+	}
+	return &healthStatus, nil
+}
+
+// fetchDeviceInfo 访问指定认证端口上的 /api/device/info
+func (s *OrangePiService) fetchDeviceInfo(ctx context.Context, authPort int, timeout time.Duration) (*RemoteDeviceInfo, error) {
+	var deviceInfo RemoteDeviceInfo
+	if err := s.callRemote(ctx, authPort, http.MethodGet, "/api/device/info", nil, timeout, &deviceInfo); err != nil {
 		return nil, err
 	}
+	return &deviceInfo, nil
+}
 
+// callRemote 经公网 FRP 端口调用 OrangePi 认证服务接口，body 不为 nil 时以 JSON 发送
+func (s *OrangePiService) callRemote(ctx context.Context, authPort int, method, path string, body interface{}, timeout time.Duration, out interface{}) error {
 	// 获取公网配置
 	publicNetConfig, err := s.publicNetService.Get(ctx)
 	if err != nil || publicNetConfig == nil {
-		return nil, errors.New("public network configuration not found")
+		return errors.New("public network configuration not found")
 	}
 
 	// 构建远程URL
-	remoteURL := fmt.Sprintf("http://%s:%d/health",
-		publicNetConfig.ExternalIP, device.ICCTVAuthServiceRemotePort)
+	remoteURL := fmt.Sprintf("http://%s:%d%s", publicNetConfig.ExternalIP, authPort, path)
+
+	var reader io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, remoteURL, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
 	// 发送HTTP请求
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(remoteURL)
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to remote device: %w", err)
+		return fmt.Errorf("failed to connect to remote device: %w", err)
 	}
 	defer resp.Body.Close()
 
-	var healthStatus RemoteHealthStatus
-	if err := json.NewDecoder(resp.Body).Decode(&healthStatus); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if out == nil {
+		if resp.StatusCode >= http.StatusBadRequest {
+			return fmt.Errorf("remote device returned status %d", resp.StatusCode)
+		}
+		return nil
 	}
-
-	return &healthStatus, nil
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		if resp.StatusCode >= http.StatusBadRequest {
+			return fmt.Errorf("remote device returned status %d", resp.StatusCode)
+		}
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,493 @@
+package services
+
+// PortMigrationService Methods:
+//0. NewPortMigrationService(db *gorm.DB, orangePiService *OrangePiService, portPool *PortPoolService) -> 初始化端口迁移服务
+//1. Migrate(ctx context.Context, id int64, sshPort, authPort int, deadline time.Duration, operator string) -> 迁移端口并验证，失败时回滚
+//2. List(ctx context.Context, orangePiID int64) -> 查询迁移记录
+//3. Resolve(ctx context.Context, orangePiID int64) -> 人工处理后清除迁移失败或中断的迁移标记
+//4. RegisterJobHandlers(jobs *JobService) -> 注册端口迁移与端口更新异步任务
+//5. UpdatePorts(ctx context.Context, id int64, sshPort, authPort int, operator string) -> 以端口迁移执行旧的远程更新端口接口
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"icctv-http-service/models"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"gorm.io/gorm"
+)
+
+// 默认的 SSH 回滚命令：把 frpc.toml 中的新端口改回旧端口并重启 frpc
+const defaultSSHRollbackCmd = `sed -i -e 's/remotePort *= *{new_auth_port}$/remotePort = {old_auth_port}/' ` +
+	`-e 's/remotePort *= *{new_ssh_port}$/remotePort = {old_ssh_port}/' ` +
+	`$HOME/icctv_orangepi_service/config/frpc.toml && docker restart frpc`
+
+// 错误定义
+var ErrPortMigrationNotFailed = errors.New("orangepi has no failed or interrupted port migration")
+
+// PortMigrationServiceInterface 定义端口迁移能力
+type PortMigrationServiceInterface interface {
+	Migrate(ctx context.Context, id int64, sshPort, authPort int, deadline time.Duration, operator string) (*models.PortMigration, error) //1.迁移端口
+	List(ctx context.Context, orangePiID int64) ([]models.PortMigration, error)                                                           //2.迁移记录
+	Resolve(ctx context.Context, orangePiID int64) (*models.OrangePi, error)                                                              //3.清除失败/中断标记
+	RegisterJobHandlers(jobs *JobService)                                                                                                 //4.注册异步任务
+	UpdatePorts(ctx context.Context, id int64, sshPort, authPort int, operator string) (*RemoteUpdateResult, error)                       //5.远程更新端口
+}
+
+// PortMigrationService 端口迁移：确认设备ID -> 下发新端口 -> 探测新端口 /health 并校验设备ID -> 提交数据库或回滚
+type PortMigrationService struct {
+	db              *gorm.DB
+	orangePiService *OrangePiService
+	portPool        *PortPoolService
+	defaultDeadline time.Duration
+	probeInterval   time.Duration
+
+	mu     sync.Mutex
+	active map[int64]bool // 本进程内正在迁移的设备，不在其中的 port-migrating 状态是进程中断遗留的
+}
+
+// 0. NewPortMigrationService 构造函数，PORT_MIGRATION_DEADLINE_SECONDS 控制默认探测时限
+func NewPortMigrationService(db *gorm.DB, orangePiService *OrangePiService, portPool *PortPoolService) *PortMigrationService {
+	deadline := 60
+	if val := os.Getenv("PORT_MIGRATION_DEADLINE_SECONDS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			deadline = parsed
+		}
+	}
+
+	return &PortMigrationService{
+		db:              db,
+		orangePiService: orangePiService,
+		portPool:        portPool,
+		defaultDeadline: time.Duration(deadline) * time.Second,
+		probeInterval:   3 * time.Second,
+		active:          make(map[int64]bool),
+	}
+}
+
+// 1. Migrate 迁移设备 FRP 端口，每一步都记录在迁移记录中
+func (s *PortMigrationService) Migrate(ctx context.Context, id int64, sshPort, authPort int, deadline time.Duration, operator string) (*models.PortMigration, error) {
+	if deadline <= 0 {
+		deadline = s.defaultDeadline
+	}
+
+	// 迁移过程中设备会重启 frpc，不随客户端断开而中止
+	ctx = context.WithoutCancel(ctx)
+
+	s.mu.Lock()
+	if s.active[id] {
+		s.mu.Unlock()
+		return nil, ErrPortMigrationInProgress
+	}
+	s.active[id] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.active, id)
+		s.mu.Unlock()
+	}()
+
+	var (
+		device    models.OrangePi
+		migration models.PortMigration
+	)
+	err := s.portPool.Transaction(ctx, func(tx *gorm.DB) error {
+		if err := tx.First(&device, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrangePiNotFound
+			}
+			return err
+		}
+		if device.DeviceIDMismatch {
+			return ErrDeviceIDMismatch
+		}
+		switch device.PortMigrationState {
+		case models.PortMigrationStateMigrating:
+			return ErrPortMigrationInProgress
+		case models.PortMigrationStateFailed:
+			return ErrPortMigrationFailed
+		}
+		// 迁移记录创建后新端口即被预留（Validate/Allocate 视为已占用），提交前不会分配给其他设备
+		if err := s.portPool.Validate(tx, device.ID, authPort, sshPort); err != nil {
+			return err
+		}
+
+		migration = models.PortMigration{
+			OrangePiID:  device.ID,
+			OldAuthPort: device.ICCTVAuthServiceRemotePort,
+			OldSSHPort:  device.SSHRemotePort,
+			NewAuthPort: authPort,
+			NewSSHPort:  sshPort,
+			Status:      models.PortMigrationStatusRunning,
+			Steps:       []models.PortMigrationStep{},
+			Operator:    operator,
+		}
+		if err := tx.Create(&migration).Error; err != nil {
+			return err
+		}
+		return tx.Model(&device).Update("port_migration_state", models.PortMigrationStateMigrating).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 1) 确认旧端口上应答的设备，之后以该设备ID校验新端口
+	expectedID, msg := s.identify(ctx, &device)
+	s.addStep(ctx, &migration, "identify", expectedID != "", msg)
+	if expectedID == "" {
+		return s.finish(ctx, &migration, models.PortMigrationStatusFailed, "")
+	}
+
+	// 2) 通过旧端口下发新端口
+	result, err := s.orangePiService.pushPorts(ctx, migration.OldAuthPort, sshPort, authPort)
+	switch {
+	case err != nil:
+		// frpc 重启可能导致连接在响应前断开，继续探测新端口确认结果
+		s.addStep(ctx, &migration, "apply", false, err.Error())
+	case !result.Success:
+		s.addStep(ctx, &migration, "apply", false, result.Message)
+		return s.finish(ctx, &migration, models.PortMigrationStatusFailed, "")
+	default:
+		s.addStep(ctx, &migration, "apply", true, fmt.Sprintf("restarted=%t %s", result.Restarted, result.Message))
+	}
+
+	// 3) 在时限内探测新认证端口，应答的必须是同一台设备
+	ok, msg := s.probe(ctx, authPort, expectedID, deadline)
+	s.addStep(ctx, &migration, "verify_new_port", ok, msg)
+	if ok {
+		err := s.portPool.Transaction(ctx, func(tx *gorm.DB) error {
+			if err := s.portPool.Validate(tx, device.ID, authPort, sshPort); err != nil {
+				return err
+			}
+			return tx.Model(&device).Updates(map[string]interface{}{
+				"icctv_auth_service_remote_port": authPort,
+				"ssh_remote_port":                sshPort,
+			}).Error
+		})
+		if err == nil {
+			s.addStep(ctx, &migration, "commit", true, "ports saved")
+			return s.finish(ctx, &migration, models.PortMigrationStatusSucceeded, "")
+		}
+		// 数据库仍是旧端口，设备已切到新端口：按失败处理并回滚设备
+		s.addStep(ctx, &migration, "commit", false, err.Error())
+	}
+
+	// 4) 新端口不可达、应答的不是该设备或提交失败：标记失败并尝试回滚
+	if err := s.db.WithContext(ctx).Model(&device).
+		Update("port_migration_state", models.PortMigrationStateFailed).Error; err != nil {
+		log.Printf("orangepi %d: failed to mark port migration failure: %v", device.ID, err)
+	}
+	s.addStep(ctx, &migration, "mark_failed", true, models.PortMigrationStateFailed)
+
+	if s.rollback(ctx, &migration) {
+		ok, msg := s.probe(ctx, migration.OldAuthPort, expectedID, deadline)
+		s.addStep(ctx, &migration, "verify_old_port", ok, msg)
+		if ok {
+			return s.finish(ctx, &migration, models.PortMigrationStatusRolledBack, "")
+		}
+	}
+	return s.finish(ctx, &migration, models.PortMigrationStatusFailed, models.PortMigrationStateFailed)
+}
+
+// 2. List 查询迁移记录（orangePiID 为 0 时返回全部）
+func (s *PortMigrationService) List(ctx context.Context, orangePiID int64) ([]models.PortMigration, error) {
+	var items []models.PortMigration
+	tx := s.db.WithContext(ctx).Model(&models.PortMigration{})
+	if orangePiID > 0 {
+		tx = tx.Where("orangepi_id = ?", orangePiID)
+	}
+	if err := tx.Order("id desc").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// 3. Resolve 人工确认设备已恢复后清除 port-migration-failed 标记；
+// 服务在迁移中途重启时设备会停留在 port-migrating，本进程内没有该设备的迁移在执行时同样可以清除，
+// 遗留的 running 迁移记录标记为失败（数据库中的端口可能是旧端口也可能已提交新端口，需人工核对）
+func (s *PortMigrationService) Resolve(ctx context.Context, orangePiID int64) (*models.OrangePi, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var device models.OrangePi
+	if err := s.db.WithContext(ctx).First(&device, orangePiID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrangePiNotFound
+		}
+		return nil, err
+	}
+	switch {
+	case device.PortMigrationState == models.PortMigrationStateMigrating && s.active[device.ID]:
+		return nil, ErrPortMigrationInProgress
+	case device.PortMigrationState != models.PortMigrationStateFailed &&
+		device.PortMigrationState != models.PortMigrationStateMigrating:
+		return nil, ErrPortMigrationNotFailed
+	}
+
+	var stale []models.PortMigration
+	if err := s.db.WithContext(ctx).
+		Where("orangepi_id = ? AND status = ?", device.ID, models.PortMigrationStatusRunning).
+		Find(&stale).Error; err != nil {
+		return nil, err
+	}
+	for i := range stale {
+		s.addStep(ctx, &stale[i], "resolve_interrupted", true, "migration was interrupted and resolved manually")
+		if _, err := s.finish(ctx, &stale[i], models.PortMigrationStatusFailed, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	device.PortMigrationState = ""
+	if err := s.db.WithContext(ctx).Model(&device).Update("port_migration_state", "").Error; err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// 4. RegisterJobHandlers 注册端口迁移异步任务，每个目标通过目标参数指定新端口；
+// 远程更新端口任务(remote_update_ports)同样以端口迁移执行
+func (s *PortMigrationService) RegisterJobHandlers(jobs *JobService) {
+	handler := func(ctx context.Context, task JobTask) (interface{}, error) {
+		sshPort, err := task.Int("ssh_remote_port")
+		if err != nil {
+			return nil, err
+		}
+		authPort, err := task.Int("icctv_auth_service_remote_port")
+		if err != nil {
+			return nil, err
+		}
+		var deadline time.Duration
+		if _, ok := task.Params["deadline_seconds"]; ok {
+			seconds, err := task.Int("deadline_seconds")
+			if err != nil {
+				return nil, err
+			}
+			deadline = time.Duration(seconds) * time.Second
+		}
+
+		migration, err := s.Migrate(ctx, task.OrangePiID, sshPort, authPort, deadline, task.CreatedBy)
+		if err != nil {
+			return nil, err
+		}
+		// 迁移本身已包含验证与回滚，失败后不自动重试
+		if migration.Status != models.PortMigrationStatusSucceeded {
+			return migration, fmt.Errorf("%w: port migration %s", ErrJobNotRetryable, migration.Status)
+		}
+		return migration, nil
+	}
+	jobs.RegisterHandler(models.JobTypePortMigration, handler)
+	jobs.RegisterHandler(models.JobTypeRemoteUpdatePorts, handler)
+}
+
+// 5. UpdatePorts 远程更新端口接口（POST /api/orangepi/remote/ports）：以端口迁移执行，保留原有的返回格式并附带迁移记录
+func (s *PortMigrationService) UpdatePorts(ctx context.Context, id int64, sshPort, authPort int, operator string) (*RemoteUpdateResult, error) {
+	migration, err := s.Migrate(ctx, id, sshPort, authPort, 0, operator)
+	if err != nil {
+		return nil, err
+	}
+	result := &RemoteUpdateResult{
+		Success:   migration.Status == models.PortMigrationStatusSucceeded,
+		Message:   "port migration " + migration.Status,
+		Restarted: migration.Status == models.PortMigrationStatusSucceeded,
+		Migration: migration,
+	}
+	if n := len(migration.Steps); n > 0 {
+		result.Message = fmt.Sprintf("port migration %s: %s", migration.Status, migration.Steps[n-1].Message)
+	}
+	return result, nil
+}
+
+// rollback 依次尝试经旧端口 HTTP 回滚和 SSH 回滚
+func (s *PortMigrationService) rollback(ctx context.Context, migration *models.PortMigration) bool {
+	// 旧隧道可能仍然可用（frpc 未成功重启）
+	result, err := s.orangePiService.pushPorts(ctx, migration.OldAuthPort, migration.OldSSHPort, migration.OldAuthPort)
+	switch {
+	case err != nil:
+		s.addStep(ctx, migration, "rollback_http_old_port", false, err.Error())
+	case !result.Success:
+		s.addStep(ctx, migration, "rollback_http_old_port", false, result.Message)
+	default:
+		s.addStep(ctx, migration, "rollback_http_old_port", true, result.Message)
+		return true
+	}
+
+	config, err := sshClientConfig()
+	if err != nil {
+		s.addStep(ctx, migration, "rollback_ssh", false, err.Error())
+		return false
+	}
+	command := strings.NewReplacer(
+		"{old_auth_port}", strconv.Itoa(migration.OldAuthPort),
+		"{old_ssh_port}", strconv.Itoa(migration.OldSSHPort),
+		"{new_auth_port}", strconv.Itoa(migration.NewAuthPort),
+		"{new_ssh_port}", strconv.Itoa(migration.NewSSHPort),
+	).Replace(getenvDefault("ORANGEPI_SSH_ROLLBACK_CMD", defaultSSHRollbackCmd))
+
+	// SSH 隧道可能已切到新端口，也可能仍在旧端口
+	for _, port := range []int{migration.NewSSHPort, migration.OldSSHPort} {
+		output, err := s.runSSH(ctx, config, port, command)
+		if err != nil {
+			s.addStep(ctx, migration, "rollback_ssh", false, fmt.Sprintf("port %d: %v %s", port, err, output))
+			continue
+		}
+		s.addStep(ctx, migration, "rollback_ssh", true, fmt.Sprintf("port %d: %s", port, output))
+		return true
+	}
+	return false
+}
+
+// identify 读取旧端口上应答的设备ID：已记录设备ID时必须一致，未记录时以应答的设备ID为准；无法确认时返回空字符串
+func (s *PortMigrationService) identify(ctx context.Context, device *models.OrangePi) (string, string) {
+	info, err := s.orangePiService.fetchDeviceInfo(ctx, device.ICCTVAuthServiceRemotePort, 15*time.Second)
+	switch {
+	case err != nil:
+		return "", fmt.Sprintf("port %d: %v", device.ICCTVAuthServiceRemotePort, err)
+	case info.DeviceID == "":
+		return "", fmt.Sprintf("port %d: device did not report device_id", device.ICCTVAuthServiceRemotePort)
+	case device.DeviceID != nil && *device.DeviceID != info.DeviceID:
+		return "", fmt.Sprintf("port %d answered by device %s, expected %s", device.ICCTVAuthServiceRemotePort, info.DeviceID, *device.DeviceID)
+	}
+	return info.DeviceID, fmt.Sprintf("port %d answered by device %s", device.ICCTVAuthServiceRemotePort, info.DeviceID)
+}
+
+// probe 在时限内轮询指定认证端口的 /health，可访问后读取 /api/device/info 确认应答的是 expectedID 这台设备
+func (s *PortMigrationService) probe(ctx context.Context, authPort int, expectedID string, deadline time.Duration) (bool, string) {
+	until := time.Now().Add(deadline)
+	for attempt := 1; ; attempt++ {
+		health, err := s.orangePiService.fetchHealth(ctx, authPort, 5*time.Second)
+		if err == nil {
+			var info *RemoteDeviceInfo
+			info, err = s.orangePiService.fetchDeviceInfo(ctx, authPort, 5*time.Second)
+			if err == nil {
+				if info.DeviceID != expectedID {
+					// 端口上是另一台设备（端口被其他 frpc 占用），不再等待
+					return false, fmt.Sprintf("port %d answered by device %q, expected %s", authPort, info.DeviceID, expectedID)
+				}
+				return true, fmt.Sprintf("port %d healthy after %d attempt(s), status=%s, device_id=%s", authPort, attempt, health.Status, info.DeviceID)
+			}
+		}
+		if time.Now().Add(s.probeInterval).After(until) {
+			return false, fmt.Sprintf("port %d unreachable within %s: %v", authPort, deadline, err)
+		}
+		time.Sleep(s.probeInterval)
+	}
+}
+
+// runSSH 通过公网 FRP SSH 端口执行命令
+func (s *PortMigrationService) runSSH(ctx context.Context, config *ssh.ClientConfig, port int, command string) (string, error) {
+	publicNetConfig, err := s.orangePiService.publicNetService.Get(ctx)
+	if err != nil || publicNetConfig == nil {
+		return "", errors.New("public network configuration not found")
+	}
+
+	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", publicNetConfig.ExternalIP, port), config)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	output, err := session.CombinedOutput(command)
+	return strings.TrimSpace(string(output)), err
+}
+
+// addStep 追加步骤并立即落库，便于迁移过程中查询进度
+func (s *PortMigrationService) addStep(ctx context.Context, migration *models.PortMigration, name string, success bool, message string) {
+	migration.Steps = append(migration.Steps, models.PortMigrationStep{
+		Name:    name,
+		Success: success,
+		Message: message,
+		At:      time.Now(),
+	})
+	if err := s.db.WithContext(ctx).Model(migration).Select("steps").Updates(migration).Error; err != nil {
+		log.Printf("port migration %d: failed to record step %s: %v", migration.ID, name, err)
+	}
+}
+
+// finish 结束迁移并设置设备迁移状态
+func (s *PortMigrationService) finish(ctx context.Context, migration *models.PortMigration, status string, deviceState string) (*models.PortMigration, error) {
+	now := time.Now()
+	migration.Status = status
+	migration.FinishedAt = &now
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(migration).Updates(map[string]interface{}{
+			"status":      status,
+			"finished_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.OrangePi{}).Where("id = ?", migration.OrangePiID).
+			Update("port_migration_state", deviceState).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return migration, nil
+}
+
+// sshClientConfig 从环境变量读取 SSH 凭证：ORANGEPI_SSH_USER + ORANGEPI_SSH_KEY_FILE 或 ORANGEPI_SSH_PASSWORD，
+// 主机密钥必须通过 ORANGEPI_SSH_KNOWN_HOSTS 校验，未配置时不执行 SSH 回滚
+func sshClientConfig() (*ssh.ClientConfig, error) {
+	user := os.Getenv("ORANGEPI_SSH_USER")
+	if user == "" {
+		return nil, errors.New("ssh rollback not configured (ORANGEPI_SSH_USER is empty)")
+	}
+
+	var auths []ssh.AuthMethod
+	if keyFile := os.Getenv("ORANGEPI_SSH_KEY_FILE"); keyFile != "" {
+		key, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		auths = append(auths, ssh.PublicKeys(signer))
+	}
+	if password := os.Getenv("ORANGEPI_SSH_PASSWORD"); password != "" {
+		auths = append(auths, ssh.Password(password))
+	}
+	if len(auths) == 0 {
+		return nil, errors.New("ssh rollback not configured (no key file or password)")
+	}
+
+	knownHostsFile := os.Getenv("ORANGEPI_SSH_KNOWN_HOSTS")
+	if knownHostsFile == "" {
+		return nil, errors.New("ssh rollback not configured (ORANGEPI_SSH_KNOWN_HOSTS is empty)")
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssh.ClientConfig{
+		User:            user,
+		Auth:            auths,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         10 * time.Second,
+	}, nil
+}
+
+// getenvDefault 读取环境变量，为空时返回默认值
+func getenvDefault(key, defVal string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return defVal
+}
@@ -0,0 +1,329 @@
+package services
+
+// PortPoolService Methods:
+//0. NewPortPoolService(db *gorm.DB) -> 初始化 FRP 端口池（从环境变量读取端口范围）
+//1. Validate(tx *gorm.DB, excludeID int64, authPort, sshPort int) -> 校验端口范围及唯一性（含进行中迁移预留的端口）
+//2. Allocate(tx *gorm.DB) -> 分配下一组空闲端口
+//3. Report(ctx context.Context) -> 端口池使用情况
+//4. Transaction(ctx context.Context, fn func(tx *gorm.DB) error) -> 持有端口池锁执行事务（端口校验/分配与保存在同一事务内）
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"icctv-http-service/models"
+
+	"gorm.io/gorm"
+)
+
+// 错误定义
+var (
+	ErrPortOutOfRange = errors.New("port out of range")
+	ErrPortConflict   = errors.New("port already in use")
+	ErrPortPoolFull   = errors.New("port pool exhausted")
+)
+
+// 默认端口范围，与 OrangePi 侧 frpc 配置约定一致（认证 290xx / SSH 300xx）
+const (
+	defaultAuthPortRange = "29000-29999"
+	defaultSSHPortRange  = "30000-30999"
+)
+
+// PortPoolServiceInterface 定义端口池能力
+type PortPoolServiceInterface interface {
+	Validate(tx *gorm.DB, excludeID int64, authPort, sshPort int) error //1.校验端口
+	Allocate(tx *gorm.DB) (authPort int, sshPort int, err error)        //2.分配端口
+	Report(ctx context.Context) (*PortPoolReport, error)                //3.使用情况
+	Transaction(ctx context.Context, fn func(tx *gorm.DB) error) error  //4.端口事务
+}
+
+// PortRange 端口范围（闭区间）
+type PortRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Contains 端口是否落在范围内
+func (r PortRange) Contains(port int) bool {
+	return port >= r.Start && port <= r.End
+}
+
+// Size 范围内端口数量
+func (r PortRange) Size() int {
+	return r.End - r.Start + 1
+}
+
+// PortAssignment 端口占用明细
+type PortAssignment struct {
+	OrangePiID int64  `json:"orangepi_id"`
+	Name       string `json:"name"`
+	Port       int    `json:"port"`
+}
+
+// PortPoolUsage 单个用途的端口池使用情况
+type PortPoolUsage struct {
+	Purpose    string           `json:"purpose"`
+	Range      PortRange        `json:"range"`
+	Total      int              `json:"total"`
+	Used       int              `json:"used"`
+	Free       int              `json:"free"`
+	OutOfRange []PortAssignment `json:"out_of_range"`
+	Duplicates []PortAssignment `json:"duplicates"`
+}
+
+// PortPoolReport 端口池报告
+type PortPoolReport struct {
+	Auth     PortPoolUsage `json:"auth"`
+	SSH      PortPoolUsage `json:"ssh"`
+	NextPair []int         `json:"next_pair"` // 下一组可分配的 [认证端口, SSH端口]，端口池耗尽时为空
+}
+
+// PortPoolService FRP 远程端口池
+type PortPoolService struct {
+	db        *gorm.DB
+	authRange PortRange
+	sshRange  PortRange
+	mu        sync.Mutex // 串行化端口校验/分配与保存，避免并发请求得到相同端口
+}
+
+// 0. NewPortPoolService 构造函数，读取 FRP_AUTH_PORT_RANGE / FRP_SSH_PORT_RANGE（格式 start-end）
+func NewPortPoolService(db *gorm.DB) *PortPoolService {
+	return &PortPoolService{
+		db:        db,
+		authRange: parsePortRangeEnv("FRP_AUTH_PORT_RANGE", defaultAuthPortRange),
+		sshRange:  parsePortRangeEnv("FRP_SSH_PORT_RANGE", defaultSSHPortRange),
+	}
+}
+
+// 1. Validate 校验端口在配置范围内且未被其他设备占用（excludeID 为当前设备，新建时传 0）；保存端口前需在 Transaction 内调用
+func (s *PortPoolService) Validate(tx *gorm.DB, excludeID int64, authPort, sshPort int) error {
+	if !s.authRange.Contains(authPort) {
+		return fmt.Errorf("%w: icctv_auth_service_remote_port %d not in %d-%d",
+			ErrPortOutOfRange, authPort, s.authRange.Start, s.authRange.End)
+	}
+	if !s.sshRange.Contains(sshPort) {
+		return fmt.Errorf("%w: ssh_remote_port %d not in %d-%d",
+			ErrPortOutOfRange, sshPort, s.sshRange.Start, s.sshRange.End)
+	}
+	if authPort == sshPort {
+		return fmt.Errorf("%w: auth and ssh ports must differ", ErrPortConflict)
+	}
+
+	// 同一个 FRP 服务端上任意用途的端口都不能重复
+	var holders []models.OrangePi
+	if err := tx.Model(&models.OrangePi{}).
+		Where("id <> ?", excludeID).
+		Where("icctv_auth_service_remote_port IN ? OR ssh_remote_port IN ?",
+			[]int{authPort, sshPort}, []int{authPort, sshPort}).
+		Find(&holders).Error; err != nil {
+		return err
+	}
+	for _, holder := range holders {
+		for _, port := range []int{authPort, sshPort} {
+			if holder.ICCTVAuthServiceRemotePort == port || holder.SSHRemotePort == port {
+				return fmt.Errorf("%w: port %d is used by orangepi %d (%s)",
+					ErrPortConflict, port, holder.ID, holder.Name)
+			}
+		}
+	}
+
+	// 进行中的端口迁移预留新端口，直到提交或结束
+	var migrations []models.PortMigration
+	if err := tx.Model(&models.PortMigration{}).
+		Where("status = ? AND orangepi_id <> ?", models.PortMigrationStatusRunning, excludeID).
+		Where("new_auth_port IN ? OR new_ssh_port IN ?",
+			[]int{authPort, sshPort}, []int{authPort, sshPort}).
+		Find(&migrations).Error; err != nil {
+		return err
+	}
+	for _, migration := range migrations {
+		for _, port := range []int{authPort, sshPort} {
+			if migration.NewAuthPort == port || migration.NewSSHPort == port {
+				return fmt.Errorf("%w: port %d is reserved by port migration %d of orangepi %d",
+					ErrPortConflict, port, migration.ID, migration.OrangePiID)
+			}
+		}
+	}
+	return nil
+}
+
+// 2. Allocate 按相同偏移分配下一组空闲端口（如 29005 / 30005），便于运维对照；需在 Transaction 内调用
+func (s *PortPoolService) Allocate(tx *gorm.DB) (int, int, error) {
+	used, err := s.usedPorts(tx)
+	if err != nil {
+		return 0, 0, err
+	}
+	authPort, sshPort := s.nextPair(used)
+	if authPort == 0 {
+		return 0, 0, ErrPortPoolFull
+	}
+	return authPort, sshPort, nil
+}
+
+// 3. Report 端口池使用情况：占用数量、越界端口、重复端口以及下一组可分配端口（不含已释放端口的设备，下一组端口跳过迁移预留的端口）
+func (s *PortPoolService) Report(ctx context.Context) (*PortPoolReport, error) {
+	var devices []models.OrangePi
+	if err := s.db.WithContext(ctx).
+		Where("icctv_auth_service_remote_port <> 0 OR ssh_remote_port <> 0").
+		Order("id asc").
+		Find(&devices).Error; err != nil {
+		return nil, err
+	}
+
+	authPorts := make([]PortAssignment, 0, len(devices))
+	sshPorts := make([]PortAssignment, 0, len(devices))
+	used := make(map[int]bool, len(devices)*2)
+	for _, device := range devices {
+		authPorts = append(authPorts, PortAssignment{OrangePiID: device.ID, Name: device.Name, Port: device.ICCTVAuthServiceRemotePort})
+		sshPorts = append(sshPorts, PortAssignment{OrangePiID: device.ID, Name: device.Name, Port: device.SSHRemotePort})
+		used[device.ICCTVAuthServiceRemotePort] = true
+		used[device.SSHRemotePort] = true
+	}
+
+	// 重复检测跨用途进行
+	counts := make(map[int]int, len(devices)*2)
+	for _, a := range append(append([]PortAssignment{}, authPorts...), sshPorts...) {
+		counts[a.Port]++
+	}
+
+	report := &PortPoolReport{
+		Auth: buildPortPoolUsage("auth", s.authRange, authPorts, counts),
+		SSH:  buildPortPoolUsage("ssh", s.sshRange, sshPorts, counts),
+	}
+	reserved, err := s.reservedPorts(s.db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	for port := range reserved {
+		used[port] = true
+	}
+	if authPort, sshPort := s.nextPair(used); authPort != 0 {
+		report.NextPair = []int{authPort, sshPort}
+	}
+	return report, nil
+}
+
+// 4. Transaction 持有端口池锁执行事务：写入端口的操作在 fn 内调用 Validate/Allocate 并保存设备，
+// 校验与保存在同一事务内完成，并发的创建、审批、修改与迁移不会得到相同端口。
+// 端口字段跨两列且已释放的端口为 0，无法用唯一索引约束，因此以进程内锁串行化（服务单实例部署）
+func (s *PortPoolService) Transaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.db.WithContext(ctx).Transaction(fn)
+}
+
+// usedPorts 查询所有设备已占用以及进行中迁移预留的端口
+func (s *PortPoolService) usedPorts(tx *gorm.DB) (map[int]bool, error) {
+	var devices []models.OrangePi
+	if err := tx.Model(&models.OrangePi{}).
+		Select("icctv_auth_service_remote_port", "ssh_remote_port").
+		Find(&devices).Error; err != nil {
+		return nil, err
+	}
+	used, err := s.reservedPorts(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, device := range devices {
+		used[device.ICCTVAuthServiceRemotePort] = true
+		used[device.SSHRemotePort] = true
+	}
+	return used, nil
+}
+
+// reservedPorts 查询进行中的端口迁移预留的新端口
+func (s *PortPoolService) reservedPorts(tx *gorm.DB) (map[int]bool, error) {
+	var migrations []models.PortMigration
+	if err := tx.Model(&models.PortMigration{}).
+		Select("new_auth_port", "new_ssh_port").
+		Where("status = ?", models.PortMigrationStatusRunning).
+		Find(&migrations).Error; err != nil {
+		return nil, err
+	}
+	reserved := make(map[int]bool, len(migrations)*2)
+	for _, migration := range migrations {
+		reserved[migration.NewAuthPort] = true
+		reserved[migration.NewSSHPort] = true
+	}
+	return reserved, nil
+}
+
+// nextPair 返回两个范围中相同偏移下都空闲的第一组端口，没有则返回 0, 0
+func (s *PortPoolService) nextPair(used map[int]bool) (int, int) {
+	size := s.authRange.Size()
+	if s.sshRange.Size() < size {
+		size = s.sshRange.Size()
+	}
+	for offset := 0; offset < size; offset++ {
+		authPort := s.authRange.Start + offset
+		sshPort := s.sshRange.Start + offset
+		if !used[authPort] && !used[sshPort] && authPort != sshPort {
+			return authPort, sshPort
+		}
+	}
+	return 0, 0
+}
+
+// buildPortPoolUsage 统计单个端口范围的占用情况
+func buildPortPoolUsage(purpose string, portRange PortRange, assignments []PortAssignment, counts map[int]int) PortPoolUsage {
+	usage := PortPoolUsage{
+		Purpose:    purpose,
+		Range:      portRange,
+		Total:      portRange.Size(),
+		OutOfRange: []PortAssignment{},
+		Duplicates: []PortAssignment{},
+	}
+	inRange := make(map[int]bool, len(assignments))
+	for _, a := range assignments {
+		if !portRange.Contains(a.Port) {
+			usage.OutOfRange = append(usage.OutOfRange, a)
+		} else {
+			inRange[a.Port] = true
+		}
+		if counts[a.Port] > 1 {
+			usage.Duplicates = append(usage.Duplicates, a)
+		}
+	}
+	usage.Used = len(inRange)
+	usage.Free = usage.Total - usage.Used
+	return usage
+}
+
+// parsePortRangeEnv 解析 "start-end" 格式的端口范围，非法时使用默认值
+func parsePortRangeEnv(key, defVal string) PortRange {
+	val := os.Getenv(key)
+	if val == "" {
+		val = defVal
+	}
+	portRange, err := parsePortRange(val)
+	if err != nil {
+		log.Printf("Warning: invalid %s=%q (%v), using %s", key, val, err, defVal)
+		portRange, _ = parsePortRange(defVal)
+	}
+	return portRange
+}
+
+func parsePortRange(val string) (PortRange, error) {
+	parts := strings.SplitN(strings.TrimSpace(val), "-", 2)
+	if len(parts) != 2 {
+		return PortRange{}, errors.New("expected start-end")
+	}
+	start, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return PortRange{}, err
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return PortRange{}, err
+	}
+	if start <= 0 || end > 65535 || start > end {
+		return PortRange{}, errors.New("range must satisfy 0 < start <= end <= 65535")
+	}
+	return PortRange{Start: start, End: end}, nil
+}
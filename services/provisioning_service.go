@@ -0,0 +1,304 @@
+package services
+
+// ProvisioningService Methods:
+//0. NewProvisioningService(db *gorm.DB, publicNetService *PublicNetService, authService *AuthService, auditService *AuditService) -> 初始化部署包服务（从环境变量读取 FRP 服务端参数）
+//1. Bundle(ctx context.Context, id int64, actor Actor) -> 生成设备部署包(zip)
+//2. ListTemplates(ctx context.Context) -> 查询部署包模板
+//3. UpdateTemplate(ctx context.Context, name, content string, actor Actor) -> 修改模板（保存前校验可渲染）
+//4. ResetTemplate(ctx context.Context, name string, actor Actor) -> 恢复内置默认模板
+//5. Render(ctx context.Context, id int64, name string) -> 渲染单个模板（期望配置，用于与设备实际配置对比）
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"text/template"
+	"time"
+
+	"icctv-http-service/models"
+
+	"gorm.io/gorm"
+)
+
+// 错误定义
+var (
+	ErrTemplateNotFound = errors.New("provisioning template not found")
+	ErrTemplateInvalid  = errors.New("invalid provisioning template")
+)
+
+// ProvisioningServiceInterface 定义部署包能力
+type ProvisioningServiceInterface interface {
+	Bundle(ctx context.Context, id int64, actor Actor) (*ProvisioningBundle, error)                              //1.生成部署包
+	ListTemplates(ctx context.Context) ([]models.ProvisioningTemplate, error)                                    //2.模板列表
+	UpdateTemplate(ctx context.Context, name, content string, actor Actor) (*models.ProvisioningTemplate, error) //3.修改模板
+	ResetTemplate(ctx context.Context, name string, actor Actor) (*models.ProvisioningTemplate, error)           //4.恢复默认
+	Render(ctx context.Context, id int64, name string) (string, error)                                           //5.渲染期望配置
+}
+
+// ProvisioningData 模板可用的数据
+type ProvisioningData struct {
+	Device           models.OrangePi // 设备记录
+	DeviceID         string          // 硬件设备ID（未上报时为空，agent 启动后自动生成）
+	DeviceKey        string          // 设备凭证
+	BuildingISmartID string          // 所属楼栋 ismartId
+	ServerAddr       string          // FRP 服务端地址（PublicNetConfig.ExternalIP）
+	ServerPort       int             // FRP 服务端端口（FRP_SERVER_PORT）
+	FRPToken         string          // FRP 认证 token（FRP_AUTH_TOKEN）
+	AuthRemotePort   int             // 认证服务远程端口
+	SSHRemotePort    int             // SSH 远程端口
+	VideoTokenSecret string          // 视频 Token 校验秘钥
+	CenterURL        string          // 中心服务地址（CENTER_PUBLIC_URL）
+	AgentImage       string          // 认证服务镜像（ORANGEPI_AGENT_IMAGE）
+	GeneratedAt      time.Time       // 生成时间
+}
+
+// ProvisioningBundle 部署包
+type ProvisioningBundle struct {
+	FileName string
+	Content  []byte
+}
+
+// ProvisioningService 根据设备记录渲染 frpc.toml、.env 与 docker-compose 并打包
+type ProvisioningService struct {
+	db               *gorm.DB
+	publicNetService *PublicNetService
+	authService      *AuthService
+	auditService     *AuditService
+	frpServerPort    int
+	frpToken         string
+	centerURL        string
+	agentImage       string
+}
+
+// 0. NewProvisioningService 构造函数
+// FRP_SERVER_PORT(默认 7000)、FRP_AUTH_TOKEN、CENTER_PUBLIC_URL、ORANGEPI_AGENT_IMAGE 会写入模板数据
+func NewProvisioningService(db *gorm.DB, publicNetService *PublicNetService, authService *AuthService, auditService *AuditService) *ProvisioningService {
+	serverPort := 7000
+	if val, err := strconv.Atoi(getenvDefault("FRP_SERVER_PORT", "7000")); err == nil && val > 0 {
+		serverPort = val
+	}
+
+	return &ProvisioningService{
+		db:               db,
+		publicNetService: publicNetService,
+		authService:      authService,
+		auditService:     auditService,
+		frpServerPort:    serverPort,
+		frpToken:         getenvDefault("FRP_AUTH_TOKEN", ""),
+		centerURL:        getenvDefault("CENTER_PUBLIC_URL", ""),
+		agentImage:       getenvDefault("ORANGEPI_AGENT_IMAGE", "icctv/ismart-auth-service:latest"),
+	}
+}
+
+// 1. Bundle 渲染全部模板并打包为 zip；设备没有设备凭证时会生成并保存
+func (s *ProvisioningService) Bundle(ctx context.Context, id int64, actor Actor) (*ProvisioningBundle, error) {
+	var device models.OrangePi
+	if err := s.db.WithContext(ctx).Preload("Building").First(&device, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrangePiNotFound
+		}
+		return nil, err
+	}
+
+	publicNetConfig, err := s.publicNetService.Get(ctx)
+	if err != nil || publicNetConfig == nil {
+		return nil, errors.New("public network configuration not found")
+	}
+
+	if device.DeviceKey == "" {
+		key, err := generateDeviceKey()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.db.WithContext(ctx).Model(&device).Update("device_key", key).Error; err != nil {
+			return nil, err
+		}
+		device.DeviceKey = key
+	}
+
+	templates, err := s.ListTemplates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data := s.templateData(device, publicNetConfig.ExternalIP)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, tpl := range templates {
+		rendered, err := renderProvisioningTemplate(tpl.Name, tpl.Content, data)
+		if err != nil {
+			return nil, err
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: tpl.Path, Method: zip.Deflate, Modified: data.GeneratedAt})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(rendered); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	// 部署包包含设备凭证与签名秘钥，记录下载人
+	s.auditService.Record(ctx, actor, models.AuditLog{
+		Action:     "provisioning.bundle",
+		TargetType: "orangepi",
+		TargetID:   device.ID,
+		Detail:     map[string]interface{}{"files": len(templates)},
+		Success:    true,
+	})
+
+	return &ProvisioningBundle{
+		FileName: fmt.Sprintf("orangepi-%d-provisioning.zip", device.ID),
+		Content:  buf.Bytes(),
+	}, nil
+}
+
+// 2. ListTemplates 查询模板（首次调用时写入内置默认模板）
+func (s *ProvisioningService) ListTemplates(ctx context.Context) ([]models.ProvisioningTemplate, error) {
+	if err := s.ensureDefaults(ctx); err != nil {
+		return nil, err
+	}
+	var templates []models.ProvisioningTemplate
+	if err := s.db.WithContext(ctx).Order("id asc").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// 3. UpdateTemplate 修改模板内容，用示例数据试渲染通过后才保存
+func (s *ProvisioningService) UpdateTemplate(ctx context.Context, name, content string, actor Actor) (*models.ProvisioningTemplate, error) {
+	if err := s.ensureDefaults(ctx); err != nil {
+		return nil, err
+	}
+	var tpl models.ProvisioningTemplate
+	if err := s.db.WithContext(ctx).Where("name = ?", name).First(&tpl).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTemplateNotFound
+		}
+		return nil, err
+	}
+
+	sample := s.templateData(models.OrangePi{
+		ModelFields:                models.ModelFields{ID: 1},
+		Building:                   &models.Building{ISmartID: "0000000"},
+		Name:                       "orangepi-sample",
+		ICCTVAuthServiceRemotePort: 29000,
+		SSHRemotePort:              30000,
+		DeviceKey:                  "sample-device-key",
+	}, "127.0.0.1")
+	if _, err := renderProvisioningTemplate(name, content, sample); err != nil {
+		return nil, err
+	}
+
+	tpl.Content = content
+	tpl.UpdatedBy = actor.Username
+	if err := s.db.WithContext(ctx).Model(&tpl).
+		Select("content", "updated_by").Updates(&tpl).Error; err != nil {
+		return nil, err
+	}
+	s.auditService.Record(ctx, actor, models.AuditLog{
+		Action:     "provisioning.template.update",
+		TargetType: "provisioning_template",
+		TargetID:   tpl.ID,
+		Detail:     map[string]interface{}{"name": tpl.Name},
+		Success:    true,
+	})
+	return &tpl, nil
+}
+
+// 4. ResetTemplate 恢复内置默认模板
+func (s *ProvisioningService) ResetTemplate(ctx context.Context, name string, actor Actor) (*models.ProvisioningTemplate, error) {
+	for _, def := range defaultProvisioningTemplates {
+		if def.Name == name {
+			return s.UpdateTemplate(ctx, name, def.Content, actor)
+		}
+	}
+	return nil, ErrTemplateNotFound
+}
+
+// 5. Render 按设备当前记录渲染指定模板，不修改设备（设备凭证为空时保持为空）
+func (s *ProvisioningService) Render(ctx context.Context, id int64, name string) (string, error) {
+	var device models.OrangePi
+	if err := s.db.WithContext(ctx).Preload("Building").First(&device, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrOrangePiNotFound
+		}
+		return "", err
+	}
+	publicNetConfig, err := s.publicNetService.Get(ctx)
+	if err != nil || publicNetConfig == nil {
+		return "", errors.New("public network configuration not found")
+	}
+	if err := s.ensureDefaults(ctx); err != nil {
+		return "", err
+	}
+
+	var tpl models.ProvisioningTemplate
+	if err := s.db.WithContext(ctx).Where("name = ?", name).First(&tpl).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrTemplateNotFound
+		}
+		return "", err
+	}
+	rendered, err := renderProvisioningTemplate(tpl.Name, tpl.Content, s.templateData(device, publicNetConfig.ExternalIP))
+	if err != nil {
+		return "", err
+	}
+	return string(rendered), nil
+}
+
+// ensureDefaults 补齐缺失的内置模板（已存在的模板不会被覆盖）
+func (s *ProvisioningService) ensureDefaults(ctx context.Context) error {
+	for _, def := range defaultProvisioningTemplates {
+		tpl := def
+		if err := s.db.WithContext(ctx).
+			Where(models.ProvisioningTemplate{Name: def.Name}).
+			FirstOrCreate(&tpl).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// templateData 组装模板数据
+func (s *ProvisioningService) templateData(device models.OrangePi, externalIP string) ProvisioningData {
+	data := ProvisioningData{
+		Device:           device,
+		DeviceKey:        device.DeviceKey,
+		ServerAddr:       externalIP,
+		ServerPort:       s.frpServerPort,
+		FRPToken:         s.frpToken,
+		AuthRemotePort:   device.ICCTVAuthServiceRemotePort,
+		SSHRemotePort:    device.SSHRemotePort,
+		VideoTokenSecret: s.authService.VideoTokenSecret(),
+		CenterURL:        s.centerURL,
+		AgentImage:       s.agentImage,
+		GeneratedAt:      time.Now(),
+	}
+	if device.DeviceID != nil {
+		data.DeviceID = *device.DeviceID
+	}
+	if device.Building != nil {
+		data.BuildingISmartID = device.Building.ISmartID
+	}
+	return data
+}
+
+// renderProvisioningTemplate 渲染单个模板，引用不存在的字段视为错误
+func renderProvisioningTemplate(name, content string, data ProvisioningData) ([]byte, error) {
+	tpl, err := template.New(name).Option("missingkey=error").Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTemplateInvalid, err)
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTemplateInvalid, err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,103 @@
+package services
+
+import "icctv-http-service/models"
+
+// defaultProvisioningTemplates 内置部署包模板，首次使用时写入数据库，之后以数据库中的内容为准
+var defaultProvisioningTemplates = []models.ProvisioningTemplate{
+	{Name: "frpc.toml", Path: "config/frpc.toml", Content: defaultFRPCTemplate},
+	{Name: ".env", Path: ".env", Content: defaultAgentEnvTemplate},
+	{Name: "docker-compose.yml", Path: "docker-compose.yml", Content: defaultComposeTemplate},
+	{Name: "mediamtx.yml", Path: "config/mediamtx.yml", Content: defaultMediaMTXTemplate},
+}
+
+const defaultFRPCTemplate = `# {{.Device.Name}} (orangepi #{{.Device.ID}})
+serverAddr = "{{.ServerAddr}}"
+serverPort = {{.ServerPort}}
+{{- if .FRPToken}}
+auth.method = "token"
+auth.token = "{{.FRPToken}}"
+{{- end}}
+
+[[proxies]]
+name = "orangepi-{{.Device.ID}}-auth"
+type = "tcp"
+localIP = "127.0.0.1"
+localPort = 8889
+remotePort = {{.AuthRemotePort}}
+
+[[proxies]]
+name = "orangepi-{{.Device.ID}}-ssh"
+type = "tcp"
+localIP = "127.0.0.1"
+localPort = 22
+remotePort = {{.SSHRemotePort}}
+`
+
+const defaultAgentEnvTemplate = `# {{.Device.Name}} (orangepi #{{.Device.ID}})
+ORANGEPI_ID={{.Device.ID}}
+DEVICE_ID={{.DeviceID}}
+DEVICE_KEY={{.DeviceKey}}
+BUILDING_ID={{.BuildingISmartID}}
+VIDEO_TOKEN_SECRET_KEY='{{.VideoTokenSecret}}'
+CENTER_URL={{.CenterURL}}
+FRPC_SERVER={{.ServerAddr}}
+FRPC_AUTH_REMOTE_PORT={{.AuthRemotePort}}
+FRPC_SSH_REMOTE_PORT={{.SSHRemotePort}}
+AUTH_SERVICE_PORT=8889
+MEDIAMTX_API_URL=http://127.0.0.1:9997
+`
+
+const defaultComposeTemplate = `services:
+  mediamtx:
+    image: bluenviron/mediamtx:latest
+    container_name: mediamtx
+    restart: unless-stopped
+    network_mode: host
+    volumes:
+      - ./config/mediamtx.yml:/mediamtx.yml
+      - ./recordings:/recordings
+
+  frpc:
+    image: snowdreamtech/frpc:latest
+    container_name: frpc
+    restart: unless-stopped
+    network_mode: host
+    volumes:
+      - ./config/frpc.toml:/etc/frp/frpc.toml
+
+  ismart_auth_service:
+    image: {{.AgentImage}}
+    container_name: ismart_auth_service
+    restart: unless-stopped
+    network_mode: host
+    env_file: .env
+    volumes:
+      - ./config:/app/config
+      - /var/run/docker.sock:/var/run/docker.sock
+`
+
+// defaultMediaMTXTemplate 只包含基础配置，NVR 通道路径由 agent 通过 MediaMTX API 动态维护
+const defaultMediaMTXTemplate = `# {{.Device.Name}} (orangepi #{{.Device.ID}})
+logLevel: info
+
+api: yes
+apiAddress: 127.0.0.1:9997
+
+rtsp: yes
+rtspAddress: :8554
+
+hls: yes
+hlsAddress: :8888
+
+webrtc: no
+rtmp: no
+srt: no
+
+pathDefaults:
+  sourceOnDemand: yes
+  record: no
+  recordPath: /recordings/%path/%Y-%m-%d_%H-%M-%S-%f
+
+paths:
+  all_others:
+`
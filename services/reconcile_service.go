@@ -0,0 +1,310 @@
+package services
+
+// ReconcileService Methods:
+//0. NewReconcileService(db *gorm.DB, orangePiService *OrangePiService, publicNetService *PublicNetService, portMigrationService *PortMigrationService) -> 初始化对账服务（从环境变量读取周期）
+//1. Start(ctx context.Context) -> 启动周期对账
+//2. Run(ctx context.Context, req ReconcileRequest) -> 立即对账，可选修复或仅预演
+//3. List(ctx context.Context, driftedOnly bool) -> 查询最近一次对账结果
+//4. SetDisabled(ctx context.Context, id int64, disabled bool) -> 设置设备是否参与对账
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"icctv-http-service/models"
+
+	"gorm.io/gorm"
+)
+
+// 对账动作
+const (
+	ReconcileActionNone      = "none"       // 无偏差
+	ReconcileActionSkipped   = "skipped"    // 未检查（禁用、停用、迁移中等）
+	ReconcileActionWouldFix  = "would_fix"  // 预演：将会下发修复
+	ReconcileActionFixed     = "fixed"      // 已下发修复
+	ReconcileActionFixFailed = "fix_failed" // 修复失败
+	ReconcileActionReport    = "report"     // 只记录偏差（未要求修复或无法自动修复）
+)
+
+// 同时检查的设备数量
+const reconcileConcurrency = 10
+
+// ReconcileServiceInterface 定义期望状态对账能力
+type ReconcileServiceInterface interface {
+	Start(ctx context.Context)                                                          //1.周期对账
+	Run(ctx context.Context, req ReconcileRequest) ([]ReconcileResult, error)           //2.立即对账
+	List(ctx context.Context, driftedOnly bool) ([]models.DeviceDrift, error)           //3.对账结果
+	SetDisabled(ctx context.Context, id int64, disabled bool) (*models.OrangePi, error) //4.对账开关
+}
+
+// ReconcileRequest 对账参数
+type ReconcileRequest struct {
+	OrangePiIDs []int64 `json:"orangepi_ids"` // 为空时检查全部设备
+	Fix         bool    `json:"fix"`          // 发现端口偏差时通过端口迁移（校验、探测与回滚）下发期望端口
+	DryRun      bool    `json:"dry_run"`      // 只报告将要执行的修复，不实际下发
+}
+
+// ReconcileResult 单个设备的对账结果
+type ReconcileResult struct {
+	OrangePiID int64               `json:"orangepi_id"`
+	Name       string              `json:"name"`
+	Action     string              `json:"action"`
+	Message    string              `json:"message,omitempty"`
+	Drift      *models.DeviceDrift `json:"drift,omitempty"`
+}
+
+// ReconcileService 比较数据库中的期望 FRPC 配置与设备实际上报的配置
+type ReconcileService struct {
+	db                   *gorm.DB
+	orangePiService      *OrangePiService
+	publicNetService     *PublicNetService
+	portMigrationService *PortMigrationService
+	interval             time.Duration
+	autoFix              bool
+	running              sync.Mutex // 同一时间只执行一轮对账
+}
+
+// 0. NewReconcileService 构造函数
+// RECONCILE_INTERVAL_MINUTES 控制周期（默认 15，0 表示关闭周期对账），RECONCILE_AUTO_FIX=true 时周期对账会自动修复端口偏差
+func NewReconcileService(db *gorm.DB, orangePiService *OrangePiService, publicNetService *PublicNetService, portMigrationService *PortMigrationService) *ReconcileService {
+	minutes := 15
+	if val := os.Getenv("RECONCILE_INTERVAL_MINUTES"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			minutes = parsed
+		} else {
+			log.Printf("Warning: invalid RECONCILE_INTERVAL_MINUTES=%q, using %d", val, minutes)
+		}
+	}
+	autoFix, _ := strconv.ParseBool(os.Getenv("RECONCILE_AUTO_FIX"))
+
+	return &ReconcileService{
+		db:                   db,
+		orangePiService:      orangePiService,
+		publicNetService:     publicNetService,
+		portMigrationService: portMigrationService,
+		interval:             time.Duration(minutes) * time.Minute,
+		autoFix:              autoFix,
+	}
+}
+
+// 1. Start 启动周期对账，ctx 结束时停止
+func (s *ReconcileService) Start(ctx context.Context) {
+	if s.interval <= 0 {
+		log.Println("reconciler disabled (RECONCILE_INTERVAL_MINUTES=0)")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				results, err := s.Run(ctx, ReconcileRequest{Fix: s.autoFix})
+				if err != nil {
+					log.Printf("reconciler: %v", err)
+					continue
+				}
+				drifted := 0
+				for _, r := range results {
+					if r.Drift != nil && r.Drift.Drifted {
+						drifted++
+					}
+				}
+				log.Printf("reconciler: checked %d devices, %d drifted", len(results), drifted)
+			}
+		}
+	}()
+}
+
+// 2. Run 立即对账：检查设备实际配置，记录偏差，按需修复端口
+func (s *ReconcileService) Run(ctx context.Context, req ReconcileRequest) ([]ReconcileResult, error) {
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	var devices []models.OrangePi
+	tx := s.db.WithContext(ctx).Model(&models.OrangePi{}).Order("id asc")
+	if len(req.OrangePiIDs) > 0 {
+		tx = tx.Where("id IN ?", req.OrangePiIDs)
+	}
+	if err := tx.Find(&devices).Error; err != nil {
+		return nil, err
+	}
+
+	publicNetConfig, err := s.publicNetService.Get(ctx)
+	if err != nil || publicNetConfig == nil {
+		return nil, errors.New("public network configuration not found")
+	}
+
+	results := make([]ReconcileResult, len(devices))
+	sem := make(chan struct{}, reconcileConcurrency)
+	var wg sync.WaitGroup
+	for i := range devices {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			results[i] = s.reconcileDevice(ctx, devices[i], publicNetConfig.ExternalIP, req)
+		}(i)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// 3. List 查询最近一次对账结果
+func (s *ReconcileService) List(ctx context.Context, driftedOnly bool) ([]models.DeviceDrift, error) {
+	var items []models.DeviceDrift
+	tx := s.db.WithContext(ctx).Model(&models.DeviceDrift{})
+	if driftedOnly {
+		tx = tx.Where("drifted = ?", true)
+	}
+	if err := tx.Order("orangepi_id asc").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// 4. SetDisabled 设置设备的"不参与对账"标记
+func (s *ReconcileService) SetDisabled(ctx context.Context, id int64, disabled bool) (*models.OrangePi, error) {
+	var device models.OrangePi
+	if err := s.db.WithContext(ctx).First(&device, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrangePiNotFound
+		}
+		return nil, err
+	}
+	device.ReconcileDisabled = disabled
+	if err := s.db.WithContext(ctx).Model(&device).Update("reconcile_disabled", disabled).Error; err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// reconcileDevice 检查单个设备
+func (s *ReconcileService) reconcileDevice(ctx context.Context, device models.OrangePi, externalIP string, req ReconcileRequest) ReconcileResult {
+	result := ReconcileResult{OrangePiID: device.ID, Name: device.Name}
+
+	switch {
+	case device.ReconcileDisabled:
+		result.Action, result.Message = ReconcileActionSkipped, "reconcile disabled for this device"
+		return result
+	case !slices.Contains(models.LifecycleServingStates, device.LifecycleState):
+		// 维护、故障等状态不巡检，避免产生告警
+		result.Action, result.Message = ReconcileActionSkipped, "lifecycle state: "+device.LifecycleState
+		return result
+	case device.PortMigrationState != "":
+		result.Action, result.Message = ReconcileActionSkipped, "port migration state: "+device.PortMigrationState
+		return result
+	}
+
+	drift := models.DeviceDrift{OrangePiID: device.ID, CheckedAt: time.Now(), Fields: []models.DriftField{}}
+	info, err := s.orangePiService.RemoteGetInfo(ctx, device.ID)
+	if err != nil {
+		drift.Error = err.Error()
+		s.saveDrift(ctx, &drift)
+		result.Action, result.Message, result.Drift = ReconcileActionReport, "device unreachable", &drift
+		return result
+	}
+	drift.Reachable = true
+
+	if info.IdentityMismatch {
+		drift.Fields = append(drift.Fields, models.DriftField{Field: "device_id", Desired: info.ExpectedDeviceID, Actual: info.DeviceID})
+	}
+	// 设备未上报的字段（0 或空）视为未知，不算偏差
+	portDrift := false
+	if info.FRPCAuthRemotePort != 0 && info.FRPCAuthRemotePort != device.ICCTVAuthServiceRemotePort {
+		portDrift = true
+		drift.Fields = append(drift.Fields, models.DriftField{
+			Field:   "frpc_auth_remote_port",
+			Desired: strconv.Itoa(device.ICCTVAuthServiceRemotePort),
+			Actual:  strconv.Itoa(info.FRPCAuthRemotePort),
+		})
+	}
+	if info.FRPCSSHRemotePort != 0 && info.FRPCSSHRemotePort != device.SSHRemotePort {
+		portDrift = true
+		drift.Fields = append(drift.Fields, models.DriftField{
+			Field:   "frpc_ssh_remote_port",
+			Desired: strconv.Itoa(device.SSHRemotePort),
+			Actual:  strconv.Itoa(info.FRPCSSHRemotePort),
+		})
+	}
+	if info.FRPCServer != "" && !sameFRPServer(externalIP, info.FRPCServer) {
+		drift.Fields = append(drift.Fields, models.DriftField{Field: "frpc_server", Desired: externalIP, Actual: info.FRPCServer})
+	}
+	drift.Drifted = len(drift.Fields) > 0
+	result.Drift = &drift
+
+	switch {
+	case !drift.Drifted:
+		result.Action = ReconcileActionNone
+	case info.IdentityMismatch:
+		// 端口上应答的不是该设备，任何修复都会作用到错误的设备上
+		result.Action, result.Message = ReconcileActionReport, "device_id mismatch, not fixing"
+	case !portDrift:
+		result.Action, result.Message = ReconcileActionReport, "frpc server drift cannot be fixed remotely, re-provision the device"
+	case !req.Fix:
+		result.Action = ReconcileActionReport
+	case req.DryRun:
+		result.Action = ReconcileActionWouldFix
+		result.Message = fmt.Sprintf("would push ssh_remote_port=%d icctv_auth_service_remote_port=%d",
+			device.SSHRemotePort, device.ICCTVAuthServiceRemotePort)
+	default:
+		now := time.Now()
+		drift.LastFixAt = &now
+		// 通过端口迁移下发期望端口：校验设备ID、探测端口，失败时回滚并记录迁移步骤
+		migration, err := s.portMigrationService.Migrate(ctx, device.ID, device.SSHRemotePort, device.ICCTVAuthServiceRemotePort, 0, "reconcile")
+		switch {
+		case err != nil:
+			result.Action, result.Message = ReconcileActionFixFailed, err.Error()
+		case migration.Status != models.PortMigrationStatusSucceeded:
+			result.Action, result.Message = ReconcileActionFixFailed, fmt.Sprintf("port migration %d %s", migration.ID, migration.Status)
+		default:
+			result.Action, result.Message = ReconcileActionFixed, fmt.Sprintf("port migration %d succeeded", migration.ID)
+		}
+		drift.LastFixResult = result.Action + ": " + result.Message
+	}
+
+	s.saveDrift(ctx, &drift)
+	return result
+}
+
+// saveDrift 每个设备只保留最近一次对账结果
+func (s *ReconcileService) saveDrift(ctx context.Context, drift *models.DeviceDrift) {
+	var existing models.DeviceDrift
+	err := s.db.WithContext(ctx).Where("orangepi_id = ?", drift.OrangePiID).First(&existing).Error
+	switch {
+	case err == nil:
+		drift.ID = existing.ID
+		drift.CreatedAt = existing.CreatedAt
+		if drift.LastFixAt == nil {
+			drift.LastFixAt = existing.LastFixAt
+			drift.LastFixResult = existing.LastFixResult
+		}
+		err = s.db.WithContext(ctx).Save(drift).Error
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		err = s.db.WithContext(ctx).Create(drift).Error
+	}
+	if err != nil {
+		log.Printf("reconciler: failed to save drift for orangepi %d: %v", drift.OrangePiID, err)
+	}
+}
+
+// sameFRPServer 比较 FRP 服务端地址（忽略端口与大小写）
+func sameFRPServer(desired, actual string) bool {
+	host := strings.TrimSpace(actual)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.EqualFold(strings.TrimSpace(desired), host)
+}
@@ -0,0 +1,307 @@
+package services
+
+// RecordingService Methods:
+//0. NewRecordingService(db *gorm.DB, orangePiService *OrangePiService, publicNetService *PublicNetService, authService *AuthService) -> 初始化录像服务（从环境变量读取下载链接有效期）
+//1. Search(ctx context.Context, query RecordingQuery, scope *VideoTokenPayload) -> 通过楼栋的 OrangePi 查询录像片段（附带下载链接）
+//2. DownloadURL(ctx context.Context, query RecordingQuery, scope *VideoTokenPayload) -> 生成指定时间范围的签名下载链接
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"icctv-http-service/models"
+
+	"gorm.io/gorm"
+)
+
+// recordingMaxRange 单次查询/下载允许的最大时间范围
+const recordingMaxRange = 24 * time.Hour
+
+var (
+	ErrRecordingRangeInvalid = errors.New("invalid recording time range")
+	ErrChannelNotFound       = errors.New("channel not found in building")
+	ErrVideoTokenScope       = errors.New("video token does not cover this request")
+)
+
+// RecordingServiceInterface 定义录像查询与下载能力
+type RecordingServiceInterface interface {
+	Search(ctx context.Context, query RecordingQuery, scope *VideoTokenPayload) (*RecordingSearchResult, error)  //1.查询录像
+	DownloadURL(ctx context.Context, query RecordingQuery, scope *VideoTokenPayload) (*RecordingDownload, error) //2.下载链接
+}
+
+// RecordingQuery 录像查询条件，OrangePiID 为空时自动选择楼栋绑定的设备
+type RecordingQuery struct {
+	ISmartID   string
+	Channel    int
+	Start      time.Time
+	End        time.Time
+	OrangePiID int64
+}
+
+// RecordingSegment 一个录像片段
+type RecordingSegment struct {
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Duration    float64   `json:"duration"` // 秒
+	DownloadURL string    `json:"download_url"`
+}
+
+// RecordingSearchResult 录像查询结果，片段下载链接共用一个 Token，在 ExpiresAt 前有效
+type RecordingSearchResult struct {
+	ISmartID   string             `json:"ismartid"`
+	Channel    int                `json:"channel"`
+	Path       string             `json:"path"`
+	OrangePiID int64              `json:"orangepi_id"`
+	Start      time.Time          `json:"start"`
+	End        time.Time          `json:"end"`
+	Segments   []RecordingSegment `json:"segments"`
+	ExpiresAt  time.Time          `json:"expires_at"`
+}
+
+// RecordingDownload 签名下载链接
+type RecordingDownload struct {
+	ISmartID   string    `json:"ismartid"`
+	Channel    int       `json:"channel"`
+	Path       string    `json:"path"`
+	OrangePiID int64     `json:"orangepi_id"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	URL        string    `json:"url"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// agentRecordingSegment agent /api/recordings/list 返回的片段（MediaMTX playback /list 格式）
+type agentRecordingSegment struct {
+	Start    time.Time `json:"start"`
+	Duration float64   `json:"duration"`
+}
+
+// RecordingService 通过 OrangePi agent 的 /api/recordings/list 查询录像，
+// 并生成经 PublicNetConfig.ExternalIP 与设备认证端口访问 /api/recordings/download 的签名链接
+type RecordingService struct {
+	db               *gorm.DB
+	orangePiService  *OrangePiService
+	publicNetService *PublicNetService
+	authService      *AuthService
+	downloadTTL      time.Duration
+}
+
+// 0. NewRecordingService 构造函数
+// RECORDING_DOWNLOAD_TTL_SECONDS 控制下载链接有效期（默认 300）
+func NewRecordingService(db *gorm.DB, orangePiService *OrangePiService, publicNetService *PublicNetService, authService *AuthService) *RecordingService {
+	seconds := 300
+	if val := os.Getenv("RECORDING_DOWNLOAD_TTL_SECONDS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			seconds = parsed
+		} else {
+			log.Printf("Warning: invalid RECORDING_DOWNLOAD_TTL_SECONDS=%q, using %d", val, seconds)
+		}
+	}
+
+	return &RecordingService{
+		db:               db,
+		orangePiService:  orangePiService,
+		publicNetService: publicNetService,
+		authService:      authService,
+		downloadTTL:      time.Duration(seconds) * time.Second,
+	}
+}
+
+// 1. Search 查询楼栋频道在时间范围内的录像片段，依次尝试楼栋绑定的设备，返回第一个成功应答的设备结果
+// scope 为调用方的视频 Token（管理员为 nil），必须覆盖该楼栋与频道
+func (s *RecordingService) Search(ctx context.Context, query RecordingQuery, scope *VideoTokenPayload) (*RecordingSearchResult, error) {
+	devices, path, err := s.prepare(ctx, query, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	// 查询使用只覆盖该频道与时间范围的短期观看 Token
+	listToken, _, err := s.authService.GenerateRecordingToken(query.ISmartID, path, VideoTokenPurposeView, query.Start, query.End, time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	params.Set("path", path)
+	params.Set("start", query.Start.Format(time.RFC3339Nano))
+	params.Set("end", query.End.Format(time.RFC3339Nano))
+	params.Set("token", listToken)
+
+	externalIP, err := s.externalIP(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, device := range devices {
+		var items []agentRecordingSegment
+		if err := s.orangePiService.callRemote(ctx, device.ICCTVAuthServiceRemotePort, http.MethodGet, "/api/recordings/list?"+params.Encode(), nil, 15*time.Second, &items); err != nil {
+			lastErr = fmt.Errorf("orangepi %d: %w", device.ID, err)
+			continue
+		}
+
+		token, expiresAt, err := s.authService.GenerateRecordingToken(query.ISmartID, path, VideoTokenPurposeDownload, query.Start, query.End, s.downloadTTL)
+		if err != nil {
+			return nil, err
+		}
+		result := &RecordingSearchResult{
+			ISmartID:   query.ISmartID,
+			Channel:    query.Channel,
+			Path:       path,
+			OrangePiID: device.ID,
+			Start:      query.Start,
+			End:        query.End,
+			Segments:   make([]RecordingSegment, 0, len(items)),
+			ExpiresAt:  expiresAt,
+		}
+		for _, item := range items {
+			// 片段裁剪到查询范围内，保证下载链接不超出 Token 的时间范围
+			start := item.Start
+			end := item.Start.Add(time.Duration(item.Duration * float64(time.Second)))
+			if start.Before(query.Start) {
+				start = query.Start
+			}
+			if end.After(query.End) {
+				end = query.End
+			}
+			if !end.After(start) {
+				continue
+			}
+			result.Segments = append(result.Segments, RecordingSegment{
+				Start:       start,
+				End:         end,
+				Duration:    end.Sub(start).Seconds(),
+				DownloadURL: recordingDownloadURL(externalIP, device.ICCTVAuthServiceRemotePort, path, start, end, token),
+			})
+		}
+		return result, nil
+	}
+	return nil, lastErr
+}
+
+// 2. DownloadURL 生成下载指定时间范围录像（Progressive MP4）的签名链接，Token 用途为 download，仅覆盖该频道与时间范围
+func (s *RecordingService) DownloadURL(ctx context.Context, query RecordingQuery, scope *VideoTokenPayload) (*RecordingDownload, error) {
+	devices, path, err := s.prepare(ctx, query, scope)
+	if err != nil {
+		return nil, err
+	}
+	device := devices[0]
+	externalIP, err := s.externalIP(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, expiresAt, err := s.authService.GenerateRecordingToken(query.ISmartID, path, VideoTokenPurposeDownload, query.Start, query.End, s.downloadTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &RecordingDownload{
+		ISmartID:   query.ISmartID,
+		Channel:    query.Channel,
+		Path:       path,
+		OrangePiID: device.ID,
+		Start:      query.Start,
+		End:        query.End,
+		URL:        recordingDownloadURL(externalIP, device.ICCTVAuthServiceRemotePort, path, query.Start, query.End, token),
+		ExpiresAt:  expiresAt,
+	}, nil
+}
+
+// prepare 校验时间范围、调用方 Token 范围与频道，返回候选设备（最近有心跳的优先）及 MediaMTX 路径名
+func (s *RecordingService) prepare(ctx context.Context, query RecordingQuery, scope *VideoTokenPayload) ([]models.OrangePi, string, error) {
+	if query.Start.IsZero() || query.End.IsZero() || !query.End.After(query.Start) {
+		return nil, "", fmt.Errorf("%w: end must be after start", ErrRecordingRangeInvalid)
+	}
+	if query.End.Sub(query.Start) > recordingMaxRange {
+		return nil, "", fmt.Errorf("%w: range exceeds %s", ErrRecordingRangeInvalid, recordingMaxRange)
+	}
+
+	path := mediaMTXPathName(query.Channel)
+	if scope != nil {
+		if scope.BuildingID != query.ISmartID || !slices.Contains(scope.Channels, path) {
+			return nil, "", ErrVideoTokenScope
+		}
+		// 录像 Token 只能在自身用途与时间范围内使用，不能换取更大范围
+		if scope.Purpose != "" && scope.Purpose != VideoTokenPurposeView {
+			return nil, "", fmt.Errorf("%w: purpose %s", ErrVideoTokenScope, scope.Purpose)
+		}
+		if scope.Start != 0 && (query.Start.Unix() < scope.Start || query.End.Unix() > scope.End) {
+			return nil, "", fmt.Errorf("%w: time range", ErrVideoTokenScope)
+		}
+	}
+
+	building, err := findBuildingChannel(ctx, s.db, query.ISmartID, query.Channel)
+	if err != nil {
+		return nil, "", err
+	}
+	devices, err := availableDevices(ctx, s.db, building.ID, query.OrangePiID)
+	if err != nil {
+		return nil, "", err
+	}
+	return devices, path, nil
+}
+
+// findBuildingChannel 查找楼栋（包括宽限期内的旧 ismartId）并确认其 NVR 配置了该通道
+func findBuildingChannel(ctx context.Context, db *gorm.DB, ismartID string, channel int) (*models.Building, error) {
+	building, err := findBuildingByISmartID(ctx, db, ismartID)
+	if err != nil {
+		return nil, err
+	}
+
+	var nvrs []models.NVR
+	if err := db.WithContext(ctx).Where("building_id = ?", building.ID).Find(&nvrs).Error; err != nil {
+		return nil, err
+	}
+	for _, nvr := range nvrs {
+		for _, ch := range nvr.RTSPUrls {
+			if ch.Channel == channel {
+				return building, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %d", ErrChannelNotFound, channel)
+}
+
+// availableDevices 楼栋绑定的已部署(deployed)且端口身份一致的设备，最近有心跳的优先；orangePiID 大于 0 时只取该设备
+func availableDevices(ctx context.Context, db *gorm.DB, buildingID int64, orangePiID int64) ([]models.OrangePi, error) {
+	tx := db.WithContext(ctx).
+		Where("building_id = ? AND lifecycle_state IN ? AND device_id_mismatch = ?", buildingID, models.LifecycleServingStates, false)
+	if orangePiID > 0 {
+		tx = tx.Where("id = ?", orangePiID)
+	}
+	var devices []models.OrangePi
+	if err := tx.Order("last_heartbeat_at desc, id asc").Find(&devices).Error; err != nil {
+		return nil, err
+	}
+	if len(devices) == 0 {
+		return nil, ErrNoAvailableDevice
+	}
+	return devices, nil
+}
+
+// externalIP 读取公网IP，下载链接经 FRP 公网端口访问设备
+func (s *RecordingService) externalIP(ctx context.Context) (string, error) {
+	publicNetConfig, err := s.publicNetService.Get(ctx)
+	if err != nil || publicNetConfig == nil {
+		return "", errors.New("public network configuration not found")
+	}
+	return publicNetConfig.ExternalIP, nil
+}
+
+// recordingDownloadURL 拼接 agent 的录像下载链接（Progressive MP4）
+func recordingDownloadURL(externalIP string, authPort int, path string, start, end time.Time, token string) string {
+	params := url.Values{}
+	params.Set("path", path)
+	params.Set("start", start.Format(time.RFC3339Nano))
+	params.Set("duration", strconv.FormatFloat(end.Sub(start).Seconds(), 'f', -1, 64))
+	params.Set("format", "mp4")
+	params.Set("token", token)
+	return fmt.Sprintf("http://%s:%d/api/recordings/download?%s", externalIP, authPort, params.Encode())
+}
@@ -0,0 +1,318 @@
+package services
+
+// RegistrationService Methods:
+//0. NewRegistrationService(db *gorm.DB, portPool *PortPoolService, authService *AuthService) -> 初始化设备注册服务
+//1. Register(ctx context.Context, payload models.DeviceRegistration) -> 设备提交注册申请
+//2. List(ctx context.Context, status string) -> 按状态查询注册申请
+//3. Approve(ctx context.Context, id int64, req ApproveRegistrationRequest, reviewer string) -> 审批通过并下发凭证
+//4. Reject(ctx context.Context, id int64, reason string, reviewer string) -> 拒绝注册申请
+//5. ExpireStale(ctx context.Context, olderThan time.Duration, reviewer string) -> 过期长时间未处理的申请
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"icctv-http-service/models"
+
+	"gorm.io/gorm"
+)
+
+// 错误定义
+var (
+	ErrRegistrationNotFound   = errors.New("registration not found")
+	ErrRegistrationNotPending = errors.New("registration is not pending")
+)
+
+// RegistrationServiceInterface 定义设备注册业务能力
+type RegistrationServiceInterface interface {
+	Register(ctx context.Context, payload models.DeviceRegistration) (*models.DeviceRegistration, error)                   //1.设备提交注册
+	List(ctx context.Context, status string) ([]models.DeviceRegistration, error)                                          //2.查询注册申请
+	Approve(ctx context.Context, id int64, req ApproveRegistrationRequest, reviewer string) (*RegistrationApproval, error) //3.审批通过
+	Reject(ctx context.Context, id int64, reason string, reviewer string) (*models.DeviceRegistration, error)              //4.拒绝申请
+	ExpireStale(ctx context.Context, olderThan time.Duration, reviewer string) (int64, error)                              //5.过期陈旧申请
+}
+
+// ApproveRegistrationRequest 审批参数
+type ApproveRegistrationRequest struct {
+	Name       string `json:"name"`        // 覆盖设备名称(可选)
+	BuildingID int64  `json:"building_id"` // 绑定的建筑ID(可选)
+}
+
+// DeviceCredentials 下发给 OrangePi 的凭证
+type DeviceCredentials struct {
+	DeviceID         string `json:"device_id"`
+	DeviceKey        string `json:"device_key"`
+	VideoTokenSecret string `json:"video_token_secret"`
+}
+
+// RegistrationApproval 审批结果
+type RegistrationApproval struct {
+	Registration *models.DeviceRegistration `json:"registration"`
+	Device       *models.OrangePi           `json:"device"`
+	Credentials  DeviceCredentials          `json:"credentials"`
+}
+
+// RegistrationService 设备注册业务逻辑
+type RegistrationService struct {
+	db          *gorm.DB
+	portPool    *PortPoolService
+	authService *AuthService
+}
+
+// 0. NewRegistrationService 构造函数
+func NewRegistrationService(db *gorm.DB, portPool *PortPoolService, authService *AuthService) *RegistrationService {
+	return &RegistrationService{
+		db:          db,
+		portPool:    portPool,
+		authService: authService,
+	}
+}
+
+// 1. Register 设备提交注册申请（同一设备的待审批申请会被刷新而不是重复创建）
+func (s *RegistrationService) Register(ctx context.Context, payload models.DeviceRegistration) (*models.DeviceRegistration, error) {
+	payload.DeviceID = strings.TrimSpace(payload.DeviceID)
+	if payload.DeviceID == "" {
+		return nil, errors.New("device_id is required")
+	}
+	if payload.ICCTVAuthServiceRemotePort <= 0 || payload.SSHRemotePort <= 0 {
+		return nil, errors.New("icctv_auth_service_remote_port and ssh_remote_port are required")
+	}
+
+	var result models.DeviceRegistration
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing models.DeviceRegistration
+		err := tx.Where("device_id = ? AND status IN ?", payload.DeviceID,
+			[]string{models.RegistrationStatusPending, models.RegistrationStatusApproved}).
+			Order("id desc").First(&existing).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// 已审批通过的设备直接返回已有记录，由管理员侧下发凭证
+		if err == nil && existing.Status == models.RegistrationStatusApproved {
+			result = existing
+			return nil
+		}
+
+		// 刷新待审批申请
+		if err == nil {
+			existing.Name = payload.Name
+			existing.ICCTVAuthServiceRemotePort = payload.ICCTVAuthServiceRemotePort
+			existing.SSHRemotePort = payload.SSHRemotePort
+			existing.AgentVersion = payload.AgentVersion
+			existing.MediaMTXVersion = payload.MediaMTXVersion
+			if err := tx.Save(&existing).Error; err != nil {
+				return err
+			}
+			result = existing
+			return nil
+		}
+
+		record := models.DeviceRegistration{
+			DeviceID:                   payload.DeviceID,
+			Name:                       payload.Name,
+			ICCTVAuthServiceRemotePort: payload.ICCTVAuthServiceRemotePort,
+			SSHRemotePort:              payload.SSHRemotePort,
+			AgentVersion:               payload.AgentVersion,
+			MediaMTXVersion:            payload.MediaMTXVersion,
+			Status:                     models.RegistrationStatusPending,
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+		result = record
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// 2. List 查询注册申请列表
+func (s *RegistrationService) List(ctx context.Context, status string) ([]models.DeviceRegistration, error) {
+	var items []models.DeviceRegistration
+	tx := s.db.WithContext(ctx).Model(&models.DeviceRegistration{})
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	if err := tx.Order("created_at desc").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// 3. Approve 审批通过：创建 OrangePi 设备记录，可选绑定建筑，并返回设备凭证
+func (s *RegistrationService) Approve(ctx context.Context, id int64, req ApproveRegistrationRequest, reviewer string) (*RegistrationApproval, error) {
+	deviceKey, err := generateDeviceKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		registration models.DeviceRegistration
+		device       models.OrangePi
+	)
+	err = s.portPool.Transaction(ctx, func(tx *gorm.DB) error {
+		if err := tx.First(&registration, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRegistrationNotFound
+			}
+			return err
+		}
+		if registration.Status != models.RegistrationStatusPending {
+			return ErrRegistrationNotPending
+		}
+
+		// 可选绑定建筑
+		var buildingID *int64
+		if req.BuildingID > 0 {
+			var building models.Building
+			if err := tx.First(&building, req.BuildingID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrBuildingNotFound
+				}
+				return err
+			}
+			if building.ISmartID == "" {
+				return ErrBuildingNoISmartID
+			}
+			buildingID = &building.ID
+		}
+
+		// 上报端口必须在端口池范围内且未被占用
+		if err := s.portPool.Validate(tx, 0, registration.ICCTVAuthServiceRemotePort, registration.SSHRemotePort); err != nil {
+			return err
+		}
+
+		// 同一硬件不能对应多条设备记录（包括回收站中的记录）
+		taken, err := deviceIDTaken(tx, registration.DeviceID, 0)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrDeviceIDConflict
+		}
+
+		name := strings.TrimSpace(req.Name)
+		if name == "" {
+			name = registration.Name
+		}
+		if name == "" {
+			name = defaultDeviceName(registration.DeviceID)
+		}
+
+		device = models.OrangePi{
+			BuildingID:                 buildingID,
+			Name:                       name,
+			ICCTVAuthServiceRemotePort: registration.ICCTVAuthServiceRemotePort,
+			SSHRemotePort:              registration.SSHRemotePort,
+			LifecycleState:             models.LifecycleDeployed,
+			LifecycleReason:            "registration approved",
+			DeviceKey:                  deviceKey,
+			DeviceID:                   &registration.DeviceID,
+			AgentVersion:               registration.AgentVersion,
+			MediaMTXVersion:            registration.MediaMTXVersion,
+		}
+		if registration.AgentVersion != "" || registration.MediaMTXVersion != "" {
+			device.VersionReportedAt = &registration.UpdatedAt
+		}
+		if err := tx.Create(&device).Error; err != nil {
+			return err
+		}
+		if err := recordOrangePiBinding(tx, device.ID, nil, buildingID, "registration approved", reviewer); err != nil {
+			return err
+		}
+		if err := recordInitialLifecycle(tx, &device, reviewer); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		registration.Status = models.RegistrationStatusApproved
+		registration.OrangePiID = &device.ID
+		registration.ReviewedBy = reviewer
+		registration.ReviewedAt = &now
+		return tx.Save(&registration).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RegistrationApproval{
+		Registration: &registration,
+		Device:       &device,
+		Credentials: DeviceCredentials{
+			DeviceID:         registration.DeviceID,
+			DeviceKey:        deviceKey,
+			VideoTokenSecret: s.authService.VideoTokenSecret(),
+		},
+	}, nil
+}
+
+// 4. Reject 拒绝注册申请
+func (s *RegistrationService) Reject(ctx context.Context, id int64, reason string, reviewer string) (*models.DeviceRegistration, error) {
+	var registration models.DeviceRegistration
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&registration, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRegistrationNotFound
+			}
+			return err
+		}
+		if registration.Status != models.RegistrationStatusPending {
+			return ErrRegistrationNotPending
+		}
+
+		now := time.Now()
+		registration.Status = models.RegistrationStatusRejected
+		registration.Reason = reason
+		registration.ReviewedBy = reviewer
+		registration.ReviewedAt = &now
+		return tx.Save(&registration).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &registration, nil
+}
+
+// 5. ExpireStale 将超过指定时长仍未审批的申请标记为过期，返回受影响数量
+func (s *RegistrationService) ExpireStale(ctx context.Context, olderThan time.Duration, reviewer string) (int64, error) {
+	if olderThan <= 0 {
+		return 0, errors.New("older_than must be positive")
+	}
+
+	now := time.Now()
+	result := s.db.WithContext(ctx).Model(&models.DeviceRegistration{}).
+		Where("status = ? AND updated_at < ?", models.RegistrationStatusPending, now.Add(-olderThan)).
+		Updates(map[string]interface{}{
+			"status":      models.RegistrationStatusExpired,
+			"reason":      fmt.Sprintf("not approved within %s", olderThan),
+			"reviewed_by": reviewer,
+			"reviewed_at": now,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// generateDeviceKey 生成随机设备凭证
+func generateDeviceKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// defaultDeviceName 未指定名称时根据设备ID生成默认名称
+func defaultDeviceName(deviceID string) string {
+	short := deviceID
+	if len(short) > 8 {
+		short = short[:8]
+	}
+	return "orangepi-" + short
+}
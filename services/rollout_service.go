@@ -0,0 +1,682 @@
+package services
+
+// RolloutService Methods:
+//0. NewRolloutService(db *gorm.DB, orangePiService *OrangePiService, jobService *JobService, auditService *AuditService) -> 初始化升级活动服务
+//1. Create(ctx context.Context, req CreateRolloutRequest, actor Actor) -> 创建并启动升级活动
+//2. Get(ctx context.Context, id int64) -> 查询活动及设备明细
+//3. List(ctx context.Context, query RolloutListQuery) -> 分页查询活动
+//4. Pause(ctx context.Context, id int64, actor Actor) -> 暂停（执行中的设备继续完成）
+//5. Resume(ctx context.Context, id int64, retryFailed bool, actor Actor) -> 继续（金丝雀阶段结束时进入全量阶段）
+//6. Cancel(ctx context.Context, id int64, actor Actor) -> 取消（未升级的设备标记为跳过）
+//7. RegisterJobHandlers(jobs *JobService) -> 注册单台设备升级任务
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"icctv-http-service/models"
+
+	"gorm.io/gorm"
+)
+
+// 错误定义
+var (
+	ErrRolloutNotFound     = errors.New("rollout campaign not found")
+	ErrRolloutInvalid      = errors.New("invalid rollout campaign")
+	ErrRolloutNoDevices    = errors.New("no devices match the rollout selector")
+	ErrRolloutStateInvalid = errors.New("rollout campaign state does not allow this operation")
+)
+
+// rolloutVerifyInterval 升级后轮询设备版本的间隔
+const rolloutVerifyInterval = 5 * time.Second
+
+// RolloutServiceInterface 定义升级活动能力
+type RolloutServiceInterface interface {
+	Create(ctx context.Context, req CreateRolloutRequest, actor Actor) (*models.RolloutCampaign, error)          //1.创建活动
+	Get(ctx context.Context, id int64) (*models.RolloutCampaign, error)                                          //2.活动详情
+	List(ctx context.Context, query RolloutListQuery) ([]models.RolloutCampaign, models.PaginationResult, error) //3.活动列表
+	Pause(ctx context.Context, id int64, actor Actor) (*models.RolloutCampaign, error)                           //4.暂停
+	Resume(ctx context.Context, id int64, retryFailed bool, actor Actor) (*models.RolloutCampaign, error)        //5.继续
+	Cancel(ctx context.Context, id int64, actor Actor) (*models.RolloutCampaign, error)                          //6.取消
+	RegisterJobHandlers(jobs *JobService)                                                                        //7.注册异步任务
+}
+
+// CreateRolloutRequest 创建升级活动参数
+type CreateRolloutRequest struct {
+	Name          string                 `json:"name"`
+	Component     string                 `json:"component"` // agent 或 mediamtx
+	TargetVersion string                 `json:"target_version"`
+	Selector      models.RolloutSelector `json:"selector"`
+	CanaryPercent int                    `json:"canary_percent"` // 0 表示不分阶段
+}
+
+// RolloutListQuery 活动列表查询条件
+type RolloutListQuery struct {
+	models.PaginationQuery
+	Status string
+}
+
+// RolloutService 升级活动：通过 agent 的 /api/device/update 分阶段升级设备，并以设备上报的版本确认结果
+// 每个阶段的设备作为一个 rollout_update 异步任务执行，金丝雀阶段有失败时自动暂停
+type RolloutService struct {
+	db              *gorm.DB
+	orangePiService *OrangePiService
+	jobService      *JobService
+	auditService    *AuditService
+	verifyTimeout   time.Duration
+}
+
+// 0. NewRolloutService 构造函数，ROLLOUT_VERIFY_TIMEOUT_SECONDS 为升级后等待设备上报目标版本的时间（默认 300）
+func NewRolloutService(db *gorm.DB, orangePiService *OrangePiService, jobService *JobService, auditService *AuditService) *RolloutService {
+	timeout := 300
+	if val, err := strconv.Atoi(getenvDefault("ROLLOUT_VERIFY_TIMEOUT_SECONDS", "300")); err == nil && val > 0 {
+		timeout = val
+	}
+
+	return &RolloutService{
+		db:              db,
+		orangePiService: orangePiService,
+		jobService:      jobService,
+		auditService:    auditService,
+		verifyTimeout:   time.Duration(timeout) * time.Second,
+	}
+}
+
+// 1. Create 按筛选条件确定设备（已是目标版本的跳过），按比例选出金丝雀设备后立即开始
+func (s *RolloutService) Create(ctx context.Context, req CreateRolloutRequest, actor Actor) (*models.RolloutCampaign, error) {
+	req.TargetVersion = strings.TrimSpace(req.TargetVersion)
+	switch {
+	case req.Component != models.RolloutComponentAgent && req.Component != models.RolloutComponentMediaMTX:
+		return nil, fmt.Errorf("%w: component must be %s or %s", ErrRolloutInvalid, models.RolloutComponentAgent, models.RolloutComponentMediaMTX)
+	case req.TargetVersion == "":
+		return nil, fmt.Errorf("%w: target_version is required", ErrRolloutInvalid)
+	case req.CanaryPercent < 0 || req.CanaryPercent > 100:
+		return nil, fmt.Errorf("%w: canary_percent must be between 0 and 100", ErrRolloutInvalid)
+	}
+
+	tx := s.db.WithContext(ctx).Model(&models.OrangePi{}).Where("lifecycle_state IN ?", models.LifecycleManagedStates)
+	if len(req.Selector.OrangePiIDs) > 0 {
+		tx = tx.Where("id IN ?", req.Selector.OrangePiIDs)
+	}
+	if len(req.Selector.ISmartIDs) > 0 {
+		tx = tx.Where("building_id IN (?)", s.db.Model(&models.Building{}).Select("id").Where("ismart_id IN ?", req.Selector.ISmartIDs))
+	}
+	if len(req.Selector.FromVersions) > 0 {
+		tx = tx.Where(rolloutVersionColumn(req.Component)+" IN ?", req.Selector.FromVersions)
+	}
+	var devices []models.OrangePi
+	if err := tx.Order("id asc").Find(&devices).Error; err != nil {
+		return nil, err
+	}
+	if len(devices) == 0 {
+		return nil, ErrRolloutNoDevices
+	}
+
+	campaign := models.RolloutCampaign{
+		Name:          req.Name,
+		Component:     req.Component,
+		TargetVersion: req.TargetVersion,
+		Selector:      req.Selector,
+		CanaryPercent: req.CanaryPercent,
+		Phase:         models.RolloutPhaseFull,
+		Status:        models.RolloutStatusRunning,
+		Total:         len(devices),
+		CreatedBy:     actor.Username,
+	}
+	items := make([]models.RolloutDevice, 0, len(devices))
+	toUpdate := 0
+	for _, device := range devices {
+		item := models.RolloutDevice{
+			OrangePiID:  device.ID,
+			Status:      models.RolloutDevicePending,
+			FromVersion: rolloutDeviceVersion(device, req.Component),
+		}
+		if item.FromVersion == req.TargetVersion {
+			item.Status = models.RolloutDeviceSkipped
+			item.Version = item.FromVersion
+			campaign.Skipped++
+		} else {
+			toUpdate++
+		}
+		items = append(items, item)
+	}
+
+	// 金丝雀数量向上取整，比例大于 0 时至少一台
+	if canary := (toUpdate*req.CanaryPercent + 99) / 100; canary > 0 {
+		campaign.Phase = models.RolloutPhaseCanary
+		for i := range items {
+			if canary == 0 {
+				break
+			}
+			if items[i].Status == models.RolloutDevicePending {
+				items[i].Canary = true
+				canary--
+			}
+		}
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&campaign).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].CampaignID = campaign.ID
+		}
+		return tx.CreateInBatches(&items, 100).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, actor, models.AuditLog{
+		Action:     "rollout.create",
+		TargetType: "rollout_campaign",
+		TargetID:   campaign.ID,
+		Detail: map[string]interface{}{
+			"component":      campaign.Component,
+			"target_version": campaign.TargetVersion,
+			"devices":        campaign.Total,
+			"canary_percent": campaign.CanaryPercent,
+		},
+		Success: true,
+	})
+
+	// 提交任务失败时活动已暂停并记录原因，返回活动供调用方查看或继续
+	if err := s.startPhase(ctx, campaign.ID, actor.Username); err != nil {
+		log.Printf("rollout %d: failed to start: %v", campaign.ID, err)
+	}
+	return s.Get(ctx, campaign.ID)
+}
+
+// 2. Get 查询活动及每台设备的升级结果
+func (s *RolloutService) Get(ctx context.Context, id int64) (*models.RolloutCampaign, error) {
+	var campaign models.RolloutCampaign
+	if err := s.db.WithContext(ctx).
+		Preload("Devices", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+		First(&campaign, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRolloutNotFound
+		}
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+// 3. List 分页查询活动（不含设备明细），可按状态筛选
+func (s *RolloutService) List(ctx context.Context, query RolloutListQuery) ([]models.RolloutCampaign, models.PaginationResult, error) {
+	if query.PageNum <= 0 {
+		query.PageNum = 1
+	}
+	if query.PageSize <= 0 || query.PageSize > 100 {
+		query.PageSize = 20
+	}
+
+	var (
+		campaigns []models.RolloutCampaign
+		total     int64
+	)
+	tx := s.db.WithContext(ctx).Model(&models.RolloutCampaign{})
+	if query.Status != "" {
+		tx = tx.Where("status = ?", query.Status)
+	}
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, models.PaginationResult{}, err
+	}
+
+	order := "id desc"
+	if query.Asc {
+		order = "id asc"
+	}
+	if err := tx.Order(order).
+		Offset((query.PageNum - 1) * query.PageSize).
+		Limit(query.PageSize).
+		Find(&campaigns).Error; err != nil {
+		return nil, models.PaginationResult{}, err
+	}
+
+	return campaigns, models.PaginationResult{
+		Total:    int(total),
+		PageNum:  query.PageNum,
+		PageSize: query.PageSize,
+	}, nil
+}
+
+// 4. Pause 暂停活动：未开始的设备不再升级，正在升级的设备继续完成
+func (s *RolloutService) Pause(ctx context.Context, id int64, actor Actor) (*models.RolloutCampaign, error) {
+	campaign, err := s.transition(ctx, id, models.RolloutStatusRunning, map[string]interface{}{
+		"status":       models.RolloutStatusPaused,
+		"pause_reason": "paused by " + actor.Username,
+	})
+	if err == nil {
+		s.stopJob(ctx, campaign)
+	}
+	s.audit(ctx, actor, "rollout.pause", id, nil, err)
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// 5. Resume 继续已暂停的活动；当前阶段没有待升级设备时进入下一阶段，retryFailed 时失败的设备重新升级
+func (s *RolloutService) Resume(ctx context.Context, id int64, retryFailed bool, actor Actor) (*models.RolloutCampaign, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var campaign models.RolloutCampaign
+		if err := tx.First(&campaign, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRolloutNotFound
+			}
+			return err
+		}
+		if campaign.Status != models.RolloutStatusPaused {
+			return fmt.Errorf("%w: campaign is %s", ErrRolloutStateInvalid, campaign.Status)
+		}
+
+		if retryFailed {
+			if err := tx.Model(&models.RolloutDevice{}).
+				Where("campaign_id = ? AND status = ?", id, models.RolloutDeviceFailed).
+				Updates(map[string]interface{}{"status": models.RolloutDevicePending, "error": "", "finished_at": nil}).Error; err != nil {
+				return err
+			}
+		}
+
+		updates := map[string]interface{}{"status": models.RolloutStatusRunning, "pause_reason": ""}
+		if campaign.Phase == models.RolloutPhaseCanary {
+			var pending int64
+			if err := s.phaseDevices(tx, campaign).
+				Where("status IN ?", []string{models.RolloutDevicePending, models.RolloutDeviceUpdating}).
+				Count(&pending).Error; err != nil {
+				return err
+			}
+			// 金丝雀设备已全部结束，继续即确认进入全量阶段
+			if pending == 0 {
+				updates["phase"] = models.RolloutPhaseFull
+			}
+		}
+		return tx.Model(&campaign).Updates(updates).Error
+	})
+	s.audit(ctx, actor, "rollout.resume", id, map[string]interface{}{"retry_failed": retryFailed}, err)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.startPhase(ctx, id, actor.Username); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// 6. Cancel 取消活动，未开始的设备标记为跳过
+func (s *RolloutService) Cancel(ctx context.Context, id int64, actor Actor) (*models.RolloutCampaign, error) {
+	var campaign models.RolloutCampaign
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&campaign, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRolloutNotFound
+			}
+			return err
+		}
+		if campaign.Status != models.RolloutStatusRunning && campaign.Status != models.RolloutStatusPaused {
+			return fmt.Errorf("%w: campaign is %s", ErrRolloutStateInvalid, campaign.Status)
+		}
+
+		now := time.Now()
+		if err := tx.Model(&models.RolloutDevice{}).
+			Where("campaign_id = ? AND status = ?", id, models.RolloutDevicePending).
+			Updates(map[string]interface{}{"status": models.RolloutDeviceSkipped, "error": "campaign cancelled", "finished_at": now}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&campaign).Updates(map[string]interface{}{
+			"status":       models.RolloutStatusCancelled,
+			"pause_reason": "",
+			"finished_at":  now,
+		}).Error
+	})
+	if err == nil {
+		s.stopJob(ctx, &campaign)
+		err = s.refreshCounts(ctx, id)
+	}
+	s.audit(ctx, actor, "rollout.cancel", id, nil, err)
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// 7. RegisterJobHandlers 注册 rollout_update 任务：升级单台设备并确认版本，结束后推进活动阶段
+func (s *RolloutService) RegisterJobHandlers(jobs *JobService) {
+	jobs.RegisterHandler(models.JobTypeRolloutUpdate, func(ctx context.Context, task JobTask) (interface{}, error) {
+		campaignID, err := task.Int("campaign_id")
+		if err != nil {
+			return nil, err
+		}
+		item, err := s.runDevice(ctx, int64(campaignID), task.OrangePiID)
+		s.advance(context.WithoutCancel(ctx), int64(campaignID))
+		if err != nil {
+			// 每台设备只升级一次，是否重试由管理员通过 Resume(retry_failed) 决定
+			return item, fmt.Errorf("%w: %v", ErrJobNotRetryable, err)
+		}
+		return item, nil
+	})
+}
+
+// runDevice 升级单台设备并等待其上报目标版本
+func (s *RolloutService) runDevice(ctx context.Context, campaignID, orangePiID int64) (*models.RolloutDevice, error) {
+	var campaign models.RolloutCampaign
+	if err := s.db.WithContext(ctx).First(&campaign, campaignID).Error; err != nil {
+		return nil, err
+	}
+	if campaign.Status != models.RolloutStatusRunning {
+		return nil, fmt.Errorf("%w: campaign is %s", ErrRolloutStateInvalid, campaign.Status)
+	}
+
+	// 进程重启后重新排队的目标可能处于 updating 状态，升级请求可重复发送
+	now := time.Now()
+	res := s.db.WithContext(ctx).Model(&models.RolloutDevice{}).
+		Where("campaign_id = ? AND orangepi_id = ? AND status IN ?", campaignID, orangePiID,
+			[]string{models.RolloutDevicePending, models.RolloutDeviceUpdating}).
+		Updates(map[string]interface{}{"status": models.RolloutDeviceUpdating, "started_at": now})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	var item models.RolloutDevice
+	if err := s.db.WithContext(ctx).
+		Where("campaign_id = ? AND orangepi_id = ?", campaignID, orangePiID).
+		First(&item).Error; err != nil {
+		return nil, err
+	}
+	if res.RowsAffected == 0 {
+		return &item, nil
+	}
+
+	version, updateErr := s.update(ctx, campaign, orangePiID)
+	if ctx.Err() != nil {
+		// 服务退出或任务被中断，保持 updating 由重新排队的目标继续
+		return &item, ctx.Err()
+	}
+
+	finished := time.Now()
+	item.Version = version
+	item.FinishedAt = &finished
+	if updateErr != nil {
+		item.Status = models.RolloutDeviceFailed
+		item.Error = updateErr.Error()
+	} else {
+		item.Status = models.RolloutDeviceSucceeded
+		item.Error = ""
+	}
+	if err := s.db.WithContext(ctx).Model(&item).
+		Select("Status", "Version", "Error", "FinishedAt").
+		Updates(&item).Error; err != nil {
+		return &item, err
+	}
+	return &item, updateErr
+}
+
+// update 通知 agent 升级组件，然后轮询设备信息直到上报目标版本或超时，返回最后确认的版本
+func (s *RolloutService) update(ctx context.Context, campaign models.RolloutCampaign, orangePiID int64) (string, error) {
+	var device models.OrangePi
+	if err := s.db.WithContext(ctx).First(&device, orangePiID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrOrangePiNotFound
+		}
+		return "", err
+	}
+	if device.DeviceIDMismatch {
+		return "", ErrDeviceIDMismatch
+	}
+
+	requestBody := map[string]string{
+		"component": campaign.Component,
+		"version":   campaign.TargetVersion,
+	}
+	var result RemoteUpdateResult
+	if err := s.orangePiService.callRemote(ctx, device.ICCTVAuthServiceRemotePort, http.MethodPost, "/api/device/update", requestBody, 60*time.Second, &result); err != nil {
+		return "", err
+	}
+	if !result.Success {
+		return "", fmt.Errorf("agent rejected update: %s", result.Message)
+	}
+
+	// 升级过程中 agent 或 MediaMTX 会重启，轮询期间的连接失败忽略
+	version := rolloutDeviceVersion(device, campaign.Component)
+	deadline := time.Now().Add(s.verifyTimeout)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return version, ctx.Err()
+		case <-time.After(rolloutVerifyInterval):
+		}
+
+		info, err := s.orangePiService.RemoteGetInfo(ctx, orangePiID)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if info.IdentityMismatch {
+			return version, ErrDeviceIDMismatch
+		}
+		reported := info.AgentVersion
+		if campaign.Component == models.RolloutComponentMediaMTX {
+			reported = info.MediaMTXVersion
+		}
+		if reported != "" {
+			version = reported
+		}
+		if version == campaign.TargetVersion {
+			return version, nil
+		}
+	}
+
+	if lastErr != nil && version != campaign.TargetVersion {
+		return version, fmt.Errorf("device did not report version %s within %s (last error: %v)", campaign.TargetVersion, s.verifyTimeout, lastErr)
+	}
+	return version, fmt.Errorf("device did not report version %s within %s (reported %q)", campaign.TargetVersion, s.verifyTimeout, version)
+}
+
+// startPhase 把当前阶段的待升级设备作为一个异步任务提交，没有待升级设备时直接推进；
+// 提交失败时暂停活动并记录原因（否则活动停留在 running 却没有任务推进），可通过继续接口重试
+func (s *RolloutService) startPhase(ctx context.Context, id int64, createdBy string) error {
+	var campaign models.RolloutCampaign
+	if err := s.db.WithContext(ctx).First(&campaign, id).Error; err != nil {
+		return err
+	}
+	if campaign.Status != models.RolloutStatusRunning {
+		return nil
+	}
+
+	var ids []int64
+	if err := s.phaseDevices(s.db.WithContext(ctx), campaign).
+		Where("status = ?", models.RolloutDevicePending).
+		Order("id asc").
+		Pluck("orangepi_id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		s.advance(ctx, id)
+		return nil
+	}
+
+	job, err := s.jobService.Enqueue(ctx, EnqueueJobRequest{
+		Type:        models.JobTypeRolloutUpdate,
+		OrangePiIDs: ids,
+		Params:      map[string]interface{}{"campaign_id": campaign.ID},
+	}, createdBy)
+	if err == nil {
+		err = s.db.WithContext(ctx).Model(&campaign).Update("job_id", job.ID).Error
+	}
+	if err != nil {
+		s.pause(ctx, id, fmt.Sprintf("failed to start %s phase: %v", campaign.Phase, err))
+		return err
+	}
+	return nil
+}
+
+// advance 当前阶段全部结束后推进：金丝雀有失败则暂停，否则进入全量阶段；全量阶段结束则完成
+func (s *RolloutService) advance(ctx context.Context, id int64) {
+	if err := s.refreshCounts(ctx, id); err != nil {
+		log.Printf("rollout %d: failed to refresh progress: %v", id, err)
+	}
+
+	var campaign models.RolloutCampaign
+	if err := s.db.WithContext(ctx).First(&campaign, id).Error; err != nil {
+		log.Printf("rollout %d: failed to load campaign: %v", id, err)
+		return
+	}
+	if campaign.Status != models.RolloutStatusRunning {
+		return
+	}
+
+	var rows []struct {
+		Status string
+		Count  int
+	}
+	if err := s.phaseDevices(s.db.WithContext(ctx), campaign).
+		Select("status, COUNT(*) AS count").
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		log.Printf("rollout %d: failed to count devices: %v", id, err)
+		return
+	}
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	if counts[models.RolloutDevicePending] > 0 || counts[models.RolloutDeviceUpdating] > 0 {
+		return
+	}
+
+	// 条件更新保证并发结束的设备只有一个推进成功
+	base := s.db.WithContext(ctx).Model(&models.RolloutCampaign{}).
+		Where("id = ? AND status = ? AND phase = ?", id, models.RolloutStatusRunning, campaign.Phase)
+	if campaign.Phase == models.RolloutPhaseCanary {
+		if failed := counts[models.RolloutDeviceFailed]; failed > 0 {
+			if err := base.Updates(map[string]interface{}{
+				"status":       models.RolloutStatusPaused,
+				"pause_reason": fmt.Sprintf("canary failed on %d device(s)", failed),
+			}).Error; err != nil {
+				log.Printf("rollout %d: failed to pause after canary failure: %v", id, err)
+			}
+			return
+		}
+		if res := base.Update("phase", models.RolloutPhaseFull); res.Error == nil && res.RowsAffected == 1 {
+			if err := s.startPhase(ctx, id, campaign.CreatedBy); err != nil {
+				log.Printf("rollout %d: failed to start full phase: %v", id, err)
+			}
+		}
+		return
+	}
+
+	if err := base.Updates(map[string]interface{}{"status": models.RolloutStatusCompleted, "finished_at": time.Now()}).Error; err != nil {
+		log.Printf("rollout %d: failed to mark completed: %v", id, err)
+	}
+}
+
+// refreshCounts 汇总设备升级结果
+func (s *RolloutService) refreshCounts(ctx context.Context, id int64) error {
+	var rows []struct {
+		Status string
+		Count  int
+	}
+	if err := s.db.WithContext(ctx).Model(&models.RolloutDevice{}).
+		Select("status, COUNT(*) AS count").
+		Where("campaign_id = ?", id).
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return s.db.WithContext(ctx).Model(&models.RolloutCampaign{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"succeeded": counts[models.RolloutDeviceSucceeded],
+			"failed":    counts[models.RolloutDeviceFailed],
+			"skipped":   counts[models.RolloutDeviceSkipped],
+		}).Error
+}
+
+// transition 在活动处于 from 状态时更新，否则返回 ErrRolloutStateInvalid
+func (s *RolloutService) transition(ctx context.Context, id int64, from string, updates map[string]interface{}) (*models.RolloutCampaign, error) {
+	var campaign models.RolloutCampaign
+	if err := s.db.WithContext(ctx).First(&campaign, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRolloutNotFound
+		}
+		return nil, err
+	}
+	res := s.db.WithContext(ctx).Model(&models.RolloutCampaign{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(updates)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, fmt.Errorf("%w: campaign is %s", ErrRolloutStateInvalid, campaign.Status)
+	}
+	return &campaign, nil
+}
+
+// pause 系统暂停执行中的活动并记录原因
+func (s *RolloutService) pause(ctx context.Context, id int64, reason string) {
+	if err := s.db.WithContext(ctx).Model(&models.RolloutCampaign{}).
+		Where("id = ? AND status = ?", id, models.RolloutStatusRunning).
+		Updates(map[string]interface{}{"status": models.RolloutStatusPaused, "pause_reason": reason}).Error; err != nil {
+		log.Printf("rollout %d: failed to pause (%s): %v", id, reason, err)
+	}
+}
+
+// stopJob 取消当前阶段任务中尚未开始的设备
+func (s *RolloutService) stopJob(ctx context.Context, campaign *models.RolloutCampaign) {
+	if campaign.JobID == nil {
+		return
+	}
+	if err := s.jobService.CancelPending(ctx, *campaign.JobID); err != nil && !errors.Is(err, ErrJobFinished) {
+		log.Printf("rollout %d: failed to cancel job %d: %v", campaign.ID, *campaign.JobID, err)
+	}
+}
+
+// phaseDevices 当前阶段包含的设备：金丝雀阶段只有金丝雀设备
+func (s *RolloutService) phaseDevices(tx *gorm.DB, campaign models.RolloutCampaign) *gorm.DB {
+	tx = tx.Model(&models.RolloutDevice{}).Where("campaign_id = ?", campaign.ID)
+	if campaign.Phase == models.RolloutPhaseCanary {
+		tx = tx.Where("canary = ?", true)
+	}
+	return tx
+}
+
+// audit 记录活动操作
+func (s *RolloutService) audit(ctx context.Context, actor Actor, action string, id int64, detail map[string]interface{}, err error) {
+	entry := models.AuditLog{
+		Action:     action,
+		TargetType: "rollout_campaign",
+		TargetID:   id,
+		Detail:     detail,
+		Success:    err == nil,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	s.auditService.Record(ctx, actor, entry)
+}
+
+func rolloutVersionColumn(component string) string {
+	if component == models.RolloutComponentMediaMTX {
+		return "mediamtx_version"
+	}
+	return "agent_version"
+}
+
+func rolloutDeviceVersion(device models.OrangePi, component string) string {
+	if component == models.RolloutComponentMediaMTX {
+		return device.MediaMTXVersion
+	}
+	return device.AgentVersion
+}
@@ -0,0 +1,225 @@
+package services
+
+// ServiceControlService Methods:
+//0. NewServiceControlService(db *gorm.DB, orangePiService *OrangePiService, buildingService *BuildingService, auditService *AuditService) -> 初始化远程容器控制服务（从环境变量读取允许的服务名）
+//1. AllowedServices() -> 允许控制的服务名
+//2. Control(ctx context.Context, id int64, service, action string, actor Actor) -> 控制单个设备上的服务
+//3. ControlBuilding(ctx context.Context, buildingID int64, service, action string, actor Actor) -> 控制建筑下所有设备上的服务
+//4. RegisterJobHandlers(jobs *JobService) -> 注册批量服务控制异步任务
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"icctv-http-service/models"
+
+	"gorm.io/gorm"
+)
+
+// 错误定义
+var (
+	ErrServiceNotAllowed    = errors.New("service not allowed")
+	ErrInvalidServiceAction = errors.New("invalid service action")
+)
+
+// 服务控制动作
+const (
+	ServiceActionStart   = "start"
+	ServiceActionStop    = "stop"
+	ServiceActionRestart = "restart"
+)
+
+// 默认允许控制的 docker 服务
+const defaultRemoteServiceAllowlist = "mediamtx,frpc"
+
+// ServiceControlServiceInterface 定义远程容器控制能力
+type ServiceControlServiceInterface interface {
+	AllowedServices() []string                                                                                                  //1.允许的服务
+	Control(ctx context.Context, id int64, service, action string, actor Actor) (*ServiceControlResult, error)                  //2.单设备
+	ControlBuilding(ctx context.Context, buildingID int64, service, action string, actor Actor) ([]ServiceControlResult, error) //3.整栋建筑
+	RegisterJobHandlers(jobs *JobService)                                                                                       //4.注册异步任务
+}
+
+// ServiceControlResult 单个设备的服务控制结果
+type ServiceControlResult struct {
+	OrangePiID int64  `json:"orangepi_id"`
+	Name       string `json:"name"`
+	Service    string `json:"service"`
+	Action     string `json:"action"`
+	Success    bool   `json:"success"`
+	Message    string `json:"message"`
+	Status     string `json:"status,omitempty"` // 操作后容器状态（设备返回时）
+}
+
+// ServiceControlService 通过设备 agent 启停 docker 服务
+type ServiceControlService struct {
+	db              *gorm.DB
+	orangePiService *OrangePiService
+	buildingService *BuildingService
+	auditService    *AuditService
+	allowlist       map[string]bool
+}
+
+// 0. NewServiceControlService 构造函数，REMOTE_SERVICE_ALLOWLIST 为逗号分隔的服务名（默认 mediamtx,frpc）
+func NewServiceControlService(db *gorm.DB, orangePiService *OrangePiService, buildingService *BuildingService, auditService *AuditService) *ServiceControlService {
+	allowlist := make(map[string]bool)
+	for _, name := range strings.Split(getenvDefault("REMOTE_SERVICE_ALLOWLIST", defaultRemoteServiceAllowlist), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			allowlist[name] = true
+		}
+	}
+
+	return &ServiceControlService{
+		db:              db,
+		orangePiService: orangePiService,
+		buildingService: buildingService,
+		auditService:    auditService,
+		allowlist:       allowlist,
+	}
+}
+
+// 1. AllowedServices 允许控制的服务名（已排序）
+func (s *ServiceControlService) AllowedServices() []string {
+	names := make([]string, 0, len(s.allowlist))
+	for name := range s.allowlist {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 2. Control 控制单个设备上的服务，无论成功与否都写审计日志
+func (s *ServiceControlService) Control(ctx context.Context, id int64, service, action string, actor Actor) (*ServiceControlResult, error) {
+	if err := s.validate(service, action); err != nil {
+		return nil, err
+	}
+
+	var device models.OrangePi
+	if err := s.db.WithContext(ctx).First(&device, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrangePiNotFound
+		}
+		return nil, err
+	}
+	// 端口上应答的不是该设备，拒绝操作（不写审计日志）
+	if device.DeviceIDMismatch {
+		return nil, ErrDeviceIDMismatch
+	}
+
+	result := s.control(ctx, device, service, action, actor)
+	return &result, nil
+}
+
+// 3. ControlBuilding 对建筑下每个设备执行同一操作（最多 reconcileConcurrency 台并发），单个设备失败不影响其他设备
+func (s *ServiceControlService) ControlBuilding(ctx context.Context, buildingID int64, service, action string, actor Actor) ([]ServiceControlResult, error) {
+	if err := s.validate(service, action); err != nil {
+		return nil, err
+	}
+
+	devices, err := s.buildingService.GetOrangePisByBuildingID(ctx, buildingID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBuildingNotFound
+		}
+		return nil, err
+	}
+
+	results := make([]ServiceControlResult, len(devices))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, reconcileConcurrency)
+	for i := range devices {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			results[i] = s.control(ctx, devices[i], service, action, actor)
+		}(i)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// 4. RegisterJobHandlers 注册 service_control 异步任务（参数 service、action）
+func (s *ServiceControlService) RegisterJobHandlers(jobs *JobService) {
+	jobs.RegisterHandler(models.JobTypeServiceControl, func(ctx context.Context, task JobTask) (interface{}, error) {
+		service, _ := task.Params["service"].(string)
+		action, _ := task.Params["action"].(string)
+		if err := s.validate(service, action); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrJobInvalidParam, err)
+		}
+		result, err := s.Control(ctx, task.OrangePiID, service, action, Actor{Username: task.CreatedBy})
+		if err != nil {
+			return nil, err
+		}
+		if !result.Success {
+			return result, errors.New(result.Message)
+		}
+		return result, nil
+	})
+}
+
+// validate 校验服务名在允许列表中且动作合法
+func (s *ServiceControlService) validate(service, action string) error {
+	if !s.allowlist[service] {
+		return fmt.Errorf("%w: %q (allowed: %s)", ErrServiceNotAllowed, service, strings.Join(s.AllowedServices(), ", "))
+	}
+	switch action {
+	case ServiceActionStart, ServiceActionStop, ServiceActionRestart:
+		return nil
+	}
+	return fmt.Errorf("%w: %q (expected start, stop or restart)", ErrInvalidServiceAction, action)
+}
+
+// control 调用设备 agent 并写审计日志
+func (s *ServiceControlService) control(ctx context.Context, device models.OrangePi, service, action string, actor Actor) ServiceControlResult {
+	result := ServiceControlResult{
+		OrangePiID: device.ID,
+		Name:       device.Name,
+		Service:    service,
+		Action:     action,
+	}
+
+	if device.DeviceIDMismatch {
+		// 端口上应答的不是该设备，避免误操作；未执行的操作不写审计日志
+		result.Message = ErrDeviceIDMismatch.Error()
+		return result
+	}
+
+	var remote struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	}
+	path := fmt.Sprintf("/api/device/services/%s/%s", url.PathEscape(service), action)
+	if err := s.orangePiService.callRemote(ctx, device.ICCTVAuthServiceRemotePort, http.MethodPost, path, nil, 60*time.Second, &remote); err != nil {
+		result.Message = err.Error()
+	} else {
+		result.Success = remote.Success
+		result.Message = remote.Message
+		result.Status = remote.Status
+	}
+
+	entry := models.AuditLog{
+		Action:     "service." + action,
+		TargetType: "orangepi",
+		TargetID:   device.ID,
+		Detail: map[string]interface{}{
+			"service": service,
+			"message": result.Message,
+			"status":  result.Status,
+		},
+		Success: result.Success,
+	}
+	if !result.Success {
+		entry.Error = result.Message
+	}
+	s.auditService.Record(ctx, actor, entry)
+	return result
+}
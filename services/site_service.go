@@ -0,0 +1,788 @@
+package services
+
+// SiteService Methods:
+//0. NewSiteService(db *gorm.DB, auditService *AuditService) -> 初始化站点服务
+//1. List(ctx context.Context, query SiteListQuery, scope *SiteScope) -> 查询站点节点（按类型、所属建筑筛选，受限管理员只返回授权范围内的节点）
+//2. Create(ctx context.Context, payload models.Site, actor Actor) -> 创建站点节点
+//3. Update(ctx context.Context, id int64, payload models.Site, actor Actor) -> 更新站点节点（名称、备注、上级节点）
+//4. Delete(ctx context.Context, id int64, actor Actor) -> 删除站点节点（仍有下级节点、建筑或摄像头时拒绝）
+//5. Tree(ctx context.Context, id int64, scope *SiteScope) -> 查询节点子树（含建筑、楼层/区域与摄像头）
+//6. ListGrants(ctx context.Context, username string) -> 查询站点授权
+//7. CreateGrant(ctx context.Context, payload models.SiteGrant, actor Actor) -> 新增站点授权
+//8. DeleteGrant(ctx context.Context, id int64, actor Actor) -> 撤销站点授权
+//9. IsScoped(ctx context.Context, username string) -> 管理员是否为受限管理员（有站点授权记录）
+//10. Scope(ctx context.Context, username, permission string) -> 计算管理员指定权限的访问范围
+//11. Subtree(ctx context.Context, id int64) -> 计算节点子树覆盖的范围（列表接口 ?site_id= 筛选）
+//12. AuthorizeVideo(ctx context.Context, username, ismartID string, channels []string) -> 校验受限管理员签发视频 Token 的楼栋与通道
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"icctv-http-service/models"
+
+	"gorm.io/gorm"
+)
+
+// 错误定义
+var (
+	ErrSiteNotFound      = errors.New("site not found")
+	ErrSiteInvalid       = errors.New("invalid site")
+	ErrSiteInUse         = errors.New("site still has children, buildings or cameras")
+	ErrSiteGrantNotFound = errors.New("site grant not found")
+	ErrSiteGrantInvalid  = errors.New("invalid site grant")
+	ErrSiteForbidden     = errors.New("outside of granted sites")
+)
+
+// SiteServiceInterface 定义站点业务能力
+type SiteServiceInterface interface {
+	List(ctx context.Context, query SiteListQuery, scope *SiteScope) ([]models.Site, error)            //1.查询站点节点
+	Create(ctx context.Context, payload models.Site, actor Actor) (*models.Site, error)                //2.创建站点节点
+	Update(ctx context.Context, id int64, payload models.Site, actor Actor) (*models.Site, error)      //3.更新站点节点
+	Delete(ctx context.Context, id int64, actor Actor) error                                           //4.删除站点节点
+	Tree(ctx context.Context, id int64, scope *SiteScope) (*SiteTreeNode, error)                       //5.查询节点子树
+	ListGrants(ctx context.Context, username string) ([]models.SiteGrant, error)                       //6.查询站点授权
+	CreateGrant(ctx context.Context, payload models.SiteGrant, actor Actor) (*models.SiteGrant, error) //7.新增站点授权
+	DeleteGrant(ctx context.Context, id int64, actor Actor) error                                      //8.撤销站点授权
+	IsScoped(ctx context.Context, username string) (bool, error)                                       //9.是否为受限管理员
+	Scope(ctx context.Context, username, permission string) (*SiteScope, error)                        //10.计算授权范围
+	Subtree(ctx context.Context, id int64) (*SiteScope, error)                                         //11.计算子树范围
+	AuthorizeVideo(ctx context.Context, username, ismartID string, channels []string) error            //12.校验视频Token范围
+}
+
+// SiteListQuery 站点节点查询条件
+type SiteListQuery struct {
+	Kind       string // 节点类型
+	BuildingID int64  // 所属建筑（楼层/区域）
+}
+
+// SiteScope 访问范围：可访问的建筑与站点节点，以及只开放部分通道的建筑
+// nil 表示不受限制（没有站点授权的管理员、未指定 site_id 筛选）
+type SiteScope struct {
+	BuildingIDs []int64         `json:"building_ids"`
+	SiteIDs     []int64         `json:"site_ids"`
+	Channels    map[int64][]int `json:"channels,omitempty"` // 建筑ID -> 可访问的通道号，不在其中的建筑全部通道可访问
+}
+
+// SiteCamera 放置在楼层/区域的摄像头（NVR 通道）
+type SiteCamera struct {
+	NVRID   int64  `json:"nvr_id"`
+	NVRName string `json:"nvr_name"`
+	Channel int    `json:"channel"`
+}
+
+// SiteTreeBuilding 子树中的建筑及其楼层/区域
+type SiteTreeBuilding struct {
+	models.Building
+	Sites []SiteTreeNode `json:"sites"`
+}
+
+// SiteTreeNode 站点子树节点：site 节点包含下级节点与归属的建筑，floor/zone 节点包含下级区域与放置的摄像头
+type SiteTreeNode struct {
+	models.Site
+	Children  []SiteTreeNode     `json:"children"`
+	Buildings []SiteTreeBuilding `json:"buildings,omitempty"`
+	Cameras   []SiteCamera       `json:"cameras,omitempty"`
+}
+
+// AllowsBuilding 是否可访问建筑
+func (s *SiteScope) AllowsBuilding(id int64) bool {
+	return s == nil || slices.Contains(s.BuildingIDs, id)
+}
+
+// AllowsSite 是否可访问站点节点
+func (s *SiteScope) AllowsSite(id int64) bool {
+	return s == nil || slices.Contains(s.SiteIDs, id)
+}
+
+// AllowsChannel 是否可访问建筑的通道（path 为 MediaMTX 路径名，如 channel1）
+func (s *SiteScope) AllowsChannel(buildingID int64, path string) bool {
+	if !s.AllowsBuilding(buildingID) {
+		return false
+	}
+	if s == nil {
+		return true
+	}
+	channels, limited := s.Channels[buildingID]
+	if !limited {
+		return true
+	}
+	for _, ch := range channels {
+		if mediaMTXPathName(ch) == path {
+			return true
+		}
+	}
+	return false
+}
+
+// LimitChannels 去掉 NVR 中范围外的通道（只开放部分通道的建筑）
+func (s *SiteScope) LimitChannels(nvr *models.NVR) {
+	if s == nil {
+		return
+	}
+	if _, limited := s.Channels[nvr.BuildingID]; !limited {
+		return
+	}
+	channels := []models.ChannelURL{}
+	for _, ch := range nvr.RTSPUrls {
+		if s.AllowsChannel(nvr.BuildingID, mediaMTXPathName(ch.Channel)) {
+			channels = append(channels, ch)
+		}
+	}
+	nvr.RTSPUrls = channels
+}
+
+// Intersect 两个范围的交集，任一为 nil 时返回另一个
+func (s *SiteScope) Intersect(other *SiteScope) *SiteScope {
+	if s == nil {
+		return other
+	}
+	if other == nil {
+		return s
+	}
+	result := &SiteScope{BuildingIDs: []int64{}, SiteIDs: []int64{}, Channels: map[int64][]int{}}
+	for _, id := range s.BuildingIDs {
+		if !other.AllowsBuilding(id) {
+			continue
+		}
+		result.BuildingIDs = append(result.BuildingIDs, id)
+		mine, mineLimited := s.Channels[id]
+		theirs, theirsLimited := other.Channels[id]
+		switch {
+		case mineLimited && theirsLimited:
+			both := []int{}
+			for _, ch := range mine {
+				if slices.Contains(theirs, ch) {
+					both = append(both, ch)
+				}
+			}
+			result.Channels[id] = both
+		case mineLimited:
+			result.Channels[id] = mine
+		case theirsLimited:
+			result.Channels[id] = theirs
+		}
+	}
+	for _, id := range s.SiteIDs {
+		if other.AllowsSite(id) {
+			result.SiteIDs = append(result.SiteIDs, id)
+		}
+	}
+	return result
+}
+
+// SiteService 站点层级与授权
+type SiteService struct {
+	db           *gorm.DB
+	auditService *AuditService
+}
+
+// 0. NewSiteService 构造函数
+func NewSiteService(db *gorm.DB, auditService *AuditService) *SiteService {
+	return &SiteService{db: db, auditService: auditService}
+}
+
+// 1. List 查询站点节点，scope 不为空时只返回范围内的节点
+func (s *SiteService) List(ctx context.Context, query SiteListQuery, scope *SiteScope) ([]models.Site, error) {
+	tx := s.db.WithContext(ctx).Model(&models.Site{})
+	if query.Kind != "" {
+		tx = tx.Where("kind = ?", query.Kind)
+	}
+	if query.BuildingID != 0 {
+		tx = tx.Where("building_id = ?", query.BuildingID)
+	}
+	if scope != nil {
+		tx = tx.Where("id IN ?", scope.SiteIDs)
+	}
+	var items []models.Site
+	if err := tx.Order("id").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// 2. Create 创建站点节点：site 节点的上级必须是 site 节点；floor 必须指定所属建筑且直属于建筑；
+// zone 的上级可以是同一建筑的 floor/zone（未指定 building_id 时继承上级的建筑）
+func (s *SiteService) Create(ctx context.Context, payload models.Site, actor Actor) (*models.Site, error) {
+	if payload.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrSiteInvalid)
+	}
+	if payload.ParentID != nil && *payload.ParentID == 0 {
+		payload.ParentID = nil
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := validateSiteParent(tx, &payload); err != nil {
+			return err
+		}
+		return tx.Create(&payload).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, actor, models.AuditLog{
+		Action:     "site.create",
+		TargetType: "site",
+		TargetID:   payload.ID,
+		Detail:     map[string]interface{}{"kind": payload.Kind, "name": payload.Name, "parent_id": payload.ParentID, "building_id": payload.BuildingID},
+		Success:    true,
+	})
+	return &payload, nil
+}
+
+// 3. Update 更新站点节点：parent_id 为 0 时移到顶层，不能移到自身子树下；类型与所属建筑不可修改
+func (s *SiteService) Update(ctx context.Context, id int64, payload models.Site, actor Actor) (*models.Site, error) {
+	var item models.Site
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&item, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSiteNotFound
+			}
+			return err
+		}
+		if payload.Name != "" {
+			item.Name = payload.Name
+		}
+		if payload.Remark != "" {
+			item.Remark = payload.Remark
+		}
+		if payload.ParentID != nil {
+			item.ParentID = payload.ParentID
+			if *payload.ParentID == 0 {
+				item.ParentID = nil
+			}
+			idx, err := loadSiteIndex(tx)
+			if err != nil {
+				return err
+			}
+			if item.ParentID != nil && slices.Contains(idx.subtree(item.ID), *item.ParentID) {
+				return fmt.Errorf("%w: parent cannot be the site itself or its descendant", ErrSiteInvalid)
+			}
+			if err := validateSiteParent(tx, &item); err != nil {
+				return err
+			}
+		}
+		return tx.Save(&item).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, actor, models.AuditLog{
+		Action:     "site.update",
+		TargetType: "site",
+		TargetID:   item.ID,
+		Detail:     map[string]interface{}{"name": item.Name, "parent_id": item.ParentID},
+		Success:    true,
+	})
+	return &item, nil
+}
+
+// 4. Delete 删除站点节点：仍有下级节点、归属的建筑或放置的摄像头时返回 ErrSiteInUse，节点上的授权一并删除
+func (s *SiteService) Delete(ctx context.Context, id int64, actor Actor) error {
+	var site models.Site
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&site, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSiteNotFound
+			}
+			return err
+		}
+
+		var children, buildings int64
+		if err := tx.Model(&models.Site{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Building{}).Where("site_id = ?", id).Count(&buildings).Error; err != nil {
+			return err
+		}
+		cameras, err := siteCameras(tx, []int64{id})
+		if err != nil {
+			return err
+		}
+		if children > 0 || buildings > 0 || len(cameras[id]) > 0 {
+			return fmt.Errorf("%w: %d children, %d buildings, %d cameras", ErrSiteInUse, children, buildings, len(cameras[id]))
+		}
+
+		if err := tx.Where("site_id = ?", id).Delete(&models.SiteGrant{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&site).Error
+	})
+
+	entry := models.AuditLog{
+		Action:     "site.delete",
+		TargetType: "site",
+		TargetID:   id,
+		Detail:     map[string]interface{}{"kind": site.Kind, "name": site.Name},
+		Success:    err == nil,
+	}
+	if err != nil {
+		if errors.Is(err, ErrSiteNotFound) {
+			return err
+		}
+		entry.Error = err.Error()
+	}
+	s.auditService.Record(ctx, actor, entry)
+	return err
+}
+
+// 5. Tree 查询节点子树：site 节点包含归属的建筑（及建筑的楼层/区域），floor/zone 节点包含放置的摄像头
+// scope 不为空时只包含范围内的节点、建筑与通道，根节点不在范围内时返回 ErrSiteNotFound
+func (s *SiteService) Tree(ctx context.Context, id int64, scope *SiteScope) (*SiteTreeNode, error) {
+	db := s.db.WithContext(ctx)
+	idx, err := loadSiteIndex(db)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := idx.sites[id]; !ok || !scope.AllowsSite(id) {
+		return nil, ErrSiteNotFound
+	}
+
+	subtree := idx.subtree(id)
+	var buildings []models.Building
+	if err := db.Where("site_id IN ?", subtree).Order("id").Find(&buildings).Error; err != nil {
+		return nil, err
+	}
+	buildingSites := map[int64][]models.Building{}
+	placed := slices.Clone(subtree)
+	for _, building := range buildings {
+		buildingSites[*building.SiteID] = append(buildingSites[*building.SiteID], building)
+		for _, site := range idx.sites {
+			if site.BuildingID != nil && *site.BuildingID == building.ID {
+				placed = append(placed, site.ID)
+			}
+		}
+	}
+	cameras, err := siteCameras(db, placed)
+	if err != nil {
+		return nil, err
+	}
+
+	var build func(site models.Site) SiteTreeNode
+	build = func(site models.Site) SiteTreeNode {
+		node := SiteTreeNode{Site: site, Children: []SiteTreeNode{}}
+		for _, childID := range idx.children[site.ID] {
+			if scope.AllowsSite(childID) {
+				node.Children = append(node.Children, build(idx.sites[childID]))
+			}
+		}
+		for _, building := range buildingSites[site.ID] {
+			if !scope.AllowsBuilding(building.ID) {
+				continue
+			}
+			item := SiteTreeBuilding{Building: building, Sites: []SiteTreeNode{}}
+			for _, rootID := range idx.buildingRoots(building.ID) {
+				if scope.AllowsSite(rootID) {
+					item.Sites = append(item.Sites, build(idx.sites[rootID]))
+				}
+			}
+			node.Buildings = append(node.Buildings, item)
+		}
+		if site.BuildingID != nil {
+			for _, camera := range cameras[site.ID] {
+				if scope.AllowsChannel(*site.BuildingID, mediaMTXPathName(camera.Channel)) {
+					node.Cameras = append(node.Cameras, camera)
+				}
+			}
+		}
+		return node
+	}
+	root := build(idx.sites[id])
+	return &root, nil
+}
+
+// 6. ListGrants 查询站点授权（username 为空时返回全部）
+func (s *SiteService) ListGrants(ctx context.Context, username string) ([]models.SiteGrant, error) {
+	tx := s.db.WithContext(ctx).Model(&models.SiteGrant{})
+	if username != "" {
+		tx = tx.Where("username = ?", username)
+	}
+	var items []models.SiteGrant
+	if err := tx.Order("id").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// 7. CreateGrant 新增站点授权：site_id 与 building_id 必须二选一；
+// 管理员获得第一条授权后即成为受限管理员，因此不允许授权给最后一个不受限的管理员
+func (s *SiteService) CreateGrant(ctx context.Context, payload models.SiteGrant, actor Actor) (*models.SiteGrant, error) {
+	if payload.Permission == "" {
+		payload.Permission = models.SitePermissionView
+	}
+	if payload.Permission != models.SitePermissionView && payload.Permission != models.SitePermissionVideo {
+		return nil, fmt.Errorf("%w: permission must be view or video", ErrSiteGrantInvalid)
+	}
+	if (payload.SiteID == nil) == (payload.BuildingID == nil) {
+		return nil, fmt.Errorf("%w: exactly one of site_id and building_id is required", ErrSiteGrantInvalid)
+	}
+	payload.CreatedBy = actor.Username
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var admin models.Adminer
+		if err := tx.Where("username = ?", payload.Username).First(&admin).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: admin %q not found", ErrSiteGrantInvalid, payload.Username)
+			}
+			return err
+		}
+		if payload.SiteID != nil {
+			if err := tx.First(&models.Site{}, *payload.SiteID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("%w: %d", ErrSiteNotFound, *payload.SiteID)
+				}
+				return err
+			}
+		}
+		if err := checkBuildingExists(tx, payload.BuildingID); err != nil {
+			return err
+		}
+
+		var unrestricted int64
+		if err := tx.Model(&models.Adminer{}).
+			Where("username <> ? AND username NOT IN (?)", payload.Username, tx.Model(&models.SiteGrant{}).Select("username")).
+			Count(&unrestricted).Error; err != nil {
+			return err
+		}
+		if unrestricted == 0 {
+			return fmt.Errorf("%w: at least one admin must remain unrestricted", ErrSiteGrantInvalid)
+		}
+		return tx.Create(&payload).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, actor, models.AuditLog{
+		Action:     "site.grant",
+		TargetType: "site_grant",
+		TargetID:   payload.ID,
+		Detail:     map[string]interface{}{"username": payload.Username, "site_id": payload.SiteID, "building_id": payload.BuildingID, "permission": payload.Permission},
+		Success:    true,
+	})
+	return &payload, nil
+}
+
+// 8. DeleteGrant 撤销站点授权（管理员的最后一条授权被撤销后恢复为不受限管理员）
+func (s *SiteService) DeleteGrant(ctx context.Context, id int64, actor Actor) error {
+	var grant models.SiteGrant
+	if err := s.db.WithContext(ctx).First(&grant, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSiteGrantNotFound
+		}
+		return err
+	}
+	if err := s.db.WithContext(ctx).Delete(&grant).Error; err != nil {
+		return err
+	}
+
+	s.auditService.Record(ctx, actor, models.AuditLog{
+		Action:     "site.revoke",
+		TargetType: "site_grant",
+		TargetID:   grant.ID,
+		Detail:     map[string]interface{}{"username": grant.Username, "site_id": grant.SiteID, "building_id": grant.BuildingID, "permission": grant.Permission},
+		Success:    true,
+	})
+	return nil
+}
+
+// 9. IsScoped 管理员是否为受限管理员（有任意站点授权记录）
+func (s *SiteService) IsScoped(ctx context.Context, username string) (bool, error) {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.SiteGrant{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// 10. Scope 计算管理员指定权限的访问范围：没有任何授权时返回 nil（不受限）；
+// permission 为 view 时 view 与 video 授权均计入，为 video 时只计入 video 授权
+func (s *SiteService) Scope(ctx context.Context, username, permission string) (*SiteScope, error) {
+	db := s.db.WithContext(ctx)
+	var grants []models.SiteGrant
+	if err := db.Where("username = ?", username).Find(&grants).Error; err != nil {
+		return nil, err
+	}
+	if len(grants) == 0 {
+		return nil, nil
+	}
+
+	var siteIDs, buildingIDs []int64
+	for _, grant := range grants {
+		if permission == models.SitePermissionVideo && grant.Permission != models.SitePermissionVideo {
+			continue
+		}
+		if grant.SiteID != nil {
+			siteIDs = append(siteIDs, *grant.SiteID)
+		}
+		if grant.BuildingID != nil {
+			buildingIDs = append(buildingIDs, *grant.BuildingID)
+		}
+	}
+	idx, err := loadSiteIndex(db)
+	if err != nil {
+		return nil, err
+	}
+	return resolveSiteScope(db, idx, siteIDs, buildingIDs)
+}
+
+// 11. Subtree 计算节点子树覆盖的范围
+func (s *SiteService) Subtree(ctx context.Context, id int64) (*SiteScope, error) {
+	db := s.db.WithContext(ctx)
+	idx, err := loadSiteIndex(db)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := idx.sites[id]; !ok {
+		return nil, fmt.Errorf("%w: %d", ErrSiteNotFound, id)
+	}
+	return resolveSiteScope(db, idx, []int64{id}, nil)
+}
+
+// 12. AuthorizeVideo 校验管理员可以签发楼栋指定通道的视频 Token（不受限管理员直接通过）
+func (s *SiteService) AuthorizeVideo(ctx context.Context, username, ismartID string, channels []string) error {
+	scope, err := s.Scope(ctx, username, models.SitePermissionVideo)
+	if err != nil || scope == nil {
+		return err
+	}
+	building, err := findBuildingByISmartID(ctx, s.db, ismartID)
+	if err != nil {
+		return err
+	}
+	for _, path := range channels {
+		if !scope.AllowsChannel(building.ID, path) {
+			return fmt.Errorf("%w: %s %s", ErrSiteForbidden, ismartID, path)
+		}
+	}
+	return nil
+}
+
+// siteIndex 全部站点节点及上下级关系（节点数量有限，子树在内存中计算）
+type siteIndex struct {
+	sites    map[int64]models.Site
+	children map[int64][]int64
+}
+
+func loadSiteIndex(db *gorm.DB) (*siteIndex, error) {
+	var sites []models.Site
+	if err := db.Order("id").Find(&sites).Error; err != nil {
+		return nil, err
+	}
+	idx := &siteIndex{sites: make(map[int64]models.Site, len(sites)), children: map[int64][]int64{}}
+	for _, site := range sites {
+		idx.sites[site.ID] = site
+		if site.ParentID != nil {
+			idx.children[*site.ParentID] = append(idx.children[*site.ParentID], site.ID)
+		}
+	}
+	return idx, nil
+}
+
+// subtree 节点及其所有下级节点的ID
+func (idx *siteIndex) subtree(id int64) []int64 {
+	ids := []int64{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, idx.children[ids[i]]...)
+	}
+	return ids
+}
+
+// buildingRoots 直属于建筑的楼层/区域节点
+func (idx *siteIndex) buildingRoots(buildingID int64) []int64 {
+	var ids []int64
+	for _, site := range idx.sites {
+		if site.ParentID == nil && site.BuildingID != nil && *site.BuildingID == buildingID {
+			ids = append(ids, site.ID)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// resolveSiteScope 计算一组节点与建筑覆盖的范围：
+// site 节点覆盖子树内所有 site 节点下的建筑，整栋可访问的建筑包含其全部楼层/区域；
+// floor/zone 节点只覆盖所属建筑中放置在该节点子树内的摄像头通道
+func resolveSiteScope(db *gorm.DB, idx *siteIndex, siteIDs, buildingIDs []int64) (*SiteScope, error) {
+	scope := &SiteScope{BuildingIDs: []int64{}, SiteIDs: []int64{}, Channels: map[int64][]int{}}
+	whole := slices.Clone(buildingIDs)
+	placed := map[int64][]int64{} // 建筑ID -> 授权的楼层/区域子树节点
+	var groups []int64
+	for _, id := range siteIDs {
+		site, ok := idx.sites[id]
+		if !ok {
+			continue
+		}
+		subtree := idx.subtree(id)
+		scope.SiteIDs = append(scope.SiteIDs, subtree...)
+		if site.Kind == models.SiteKindSite {
+			groups = append(groups, subtree...)
+		} else if site.BuildingID != nil {
+			placed[*site.BuildingID] = append(placed[*site.BuildingID], subtree...)
+		}
+	}
+	if len(groups) > 0 {
+		var ids []int64
+		if err := db.Model(&models.Building{}).Where("site_id IN ?", groups).Pluck("id", &ids).Error; err != nil {
+			return nil, err
+		}
+		whole = append(whole, ids...)
+	}
+	for _, site := range idx.sites {
+		if site.BuildingID != nil && slices.Contains(whole, *site.BuildingID) {
+			scope.SiteIDs = append(scope.SiteIDs, site.ID)
+		}
+	}
+
+	var partial []int64
+	for buildingID := range placed {
+		if !slices.Contains(whole, buildingID) {
+			partial = append(partial, buildingID)
+			scope.Channels[buildingID] = []int{}
+		}
+	}
+	if len(partial) > 0 {
+		var nvrs []models.NVR
+		if err := db.Where("building_id IN ?", partial).Find(&nvrs).Error; err != nil {
+			return nil, err
+		}
+		for _, nvr := range nvrs {
+			nodes, ok := placed[nvr.BuildingID]
+			if !ok {
+				continue
+			}
+			for _, ch := range nvr.RTSPUrls {
+				if ch.SiteID != nil && slices.Contains(nodes, *ch.SiteID) {
+					scope.Channels[nvr.BuildingID] = append(scope.Channels[nvr.BuildingID], ch.Channel)
+				}
+			}
+		}
+	}
+
+	scope.BuildingIDs = append(whole, partial...)
+	slices.Sort(scope.BuildingIDs)
+	scope.BuildingIDs = slices.Compact(scope.BuildingIDs)
+	slices.Sort(scope.SiteIDs)
+	scope.SiteIDs = slices.Compact(scope.SiteIDs)
+	return scope, nil
+}
+
+// siteCameras 查询放置在指定楼层/区域节点的摄像头（只统计节点所属建筑的 NVR，迁移到其他建筑后的旧位置不计入）
+func siteCameras(db *gorm.DB, siteIDs []int64) (map[int64][]SiteCamera, error) {
+	var sites []models.Site
+	if err := db.Where("id IN ? AND building_id IS NOT NULL", siteIDs).Find(&sites).Error; err != nil {
+		return nil, err
+	}
+	siteBuildings := map[int64]int64{}
+	var buildingIDs []int64
+	for _, site := range sites {
+		siteBuildings[site.ID] = *site.BuildingID
+		buildingIDs = append(buildingIDs, *site.BuildingID)
+	}
+
+	result := map[int64][]SiteCamera{}
+	if len(buildingIDs) == 0 {
+		return result, nil
+	}
+	var nvrs []models.NVR
+	if err := db.Where("building_id IN ?", buildingIDs).Order("id").Find(&nvrs).Error; err != nil {
+		return nil, err
+	}
+	for _, nvr := range nvrs {
+		for _, ch := range nvr.RTSPUrls {
+			if ch.SiteID == nil {
+				continue
+			}
+			if buildingID, ok := siteBuildings[*ch.SiteID]; ok && buildingID == nvr.BuildingID {
+				result[*ch.SiteID] = append(result[*ch.SiteID], SiteCamera{NVRID: nvr.ID, NVRName: nvr.Name, Channel: ch.Channel})
+			}
+		}
+	}
+	return result, nil
+}
+
+// validateSiteParent 按节点类型校验上级节点与所属建筑
+func validateSiteParent(tx *gorm.DB, site *models.Site) error {
+	var parent *models.Site
+	if site.ParentID != nil {
+		parent = &models.Site{}
+		if err := tx.First(parent, *site.ParentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: parent %d", ErrSiteNotFound, *site.ParentID)
+			}
+			return err
+		}
+	}
+
+	switch site.Kind {
+	case models.SiteKindSite:
+		site.BuildingID = nil
+		if parent != nil && parent.Kind != models.SiteKindSite {
+			return fmt.Errorf("%w: parent of a site must be a site", ErrSiteInvalid)
+		}
+		return nil
+	case models.SiteKindFloor:
+		if parent != nil {
+			return fmt.Errorf("%w: a floor belongs directly to a building", ErrSiteInvalid)
+		}
+	case models.SiteKindZone:
+		if parent != nil {
+			if parent.Kind == models.SiteKindSite {
+				return fmt.Errorf("%w: parent of a zone must be a floor or zone", ErrSiteInvalid)
+			}
+			if site.BuildingID == nil {
+				site.BuildingID = parent.BuildingID
+			}
+			if *site.BuildingID != *parent.BuildingID {
+				return fmt.Errorf("%w: parent belongs to another building", ErrSiteInvalid)
+			}
+		}
+	default:
+		return fmt.Errorf("%w: kind must be site, floor or zone", ErrSiteInvalid)
+	}
+
+	if site.BuildingID == nil {
+		return fmt.Errorf("%w: building_id is required for a %s", ErrSiteInvalid, site.Kind)
+	}
+	return checkBuildingExists(tx, site.BuildingID)
+}
+
+// checkSiteGroup 校验建筑归属的站点节点存在且为 site 类型
+func checkSiteGroup(tx *gorm.DB, siteID *int64) error {
+	if siteID == nil {
+		return nil
+	}
+	var site models.Site
+	if err := tx.First(&site, *siteID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %d", ErrSiteNotFound, *siteID)
+		}
+		return err
+	}
+	if site.Kind != models.SiteKindSite {
+		return fmt.Errorf("%w: buildings belong to a site, not a %s", ErrSiteInvalid, site.Kind)
+	}
+	return nil
+}
+
+// checkChannelPlacements 校验通道位置必须是 NVR 所属建筑的楼层/区域节点
+func checkChannelPlacements(tx *gorm.DB, buildingID int64, channels []models.ChannelURL) error {
+	for _, ch := range channels {
+		if ch.SiteID == nil {
+			continue
+		}
+		var site models.Site
+		if err := tx.First(&site, *ch.SiteID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: %d", ErrSiteNotFound, *ch.SiteID)
+			}
+			return err
+		}
+		if site.Kind == models.SiteKindSite || site.BuildingID == nil || *site.BuildingID != buildingID {
+			return fmt.Errorf("%w: channel %d must be placed at a floor or zone of building %d", ErrSiteInvalid, ch.Channel, buildingID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+package services
+
+// StreamService Methods:
+//0. NewStreamService(db *gorm.DB, orangePiService *OrangePiService) -> 初始化推流状态服务（从环境变量读取采样周期与保留天数）
+//1. Start(ctx context.Context) -> 启动定时采样
+//2. Paths(ctx context.Context, id int64) -> 实时获取设备 MediaMTX 路径状态（同时记录采样）
+//3. Samples(ctx context.Context, query StreamSampleQuery) -> 查询历史采样
+//4. BuildingStreams(ctx context.Context, buildingID int64) -> 楼栋每个 NVR 通道的推流状态
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"icctv-http-service/models"
+
+	"gorm.io/gorm"
+)
+
+// StreamServiceInterface 定义推流状态能力
+type StreamServiceInterface interface {
+	Start(ctx context.Context)                                                            //1.定时采样
+	Paths(ctx context.Context, id int64) ([]RemotePathState, error)                       //2.实时路径状态
+	Samples(ctx context.Context, query StreamSampleQuery) ([]models.StreamSample, error)  //3.历史采样
+	BuildingStreams(ctx context.Context, buildingID int64) ([]ChannelStreamStatus, error) //4.楼栋通道状态
+}
+
+// mediaMTXPath MediaMTX /v3/paths/list 返回的单个路径（agent 原样转发）
+type mediaMTXPath struct {
+	Name   string `json:"name"`
+	Source *struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	} `json:"source"`
+	Ready         bool       `json:"ready"`
+	ReadyTime     *time.Time `json:"readyTime"`
+	Tracks        []string   `json:"tracks"`
+	BytesReceived int64      `json:"bytesReceived"`
+	BytesSent     int64      `json:"bytesSent"`
+	Readers       []struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	} `json:"readers"`
+}
+
+// RemotePathState 设备上一个 MediaMTX 路径的实时状态
+type RemotePathState struct {
+	Name          string     `json:"name"`
+	Ready         bool       `json:"ready"`
+	ReadyTime     *time.Time `json:"ready_time"`
+	SourceType    string     `json:"source_type"`
+	Tracks        []string   `json:"tracks"`
+	Readers       int        `json:"readers"`
+	BytesReceived int64      `json:"bytes_received"`
+	BytesSent     int64      `json:"bytes_sent"`
+}
+
+// StreamSampleQuery 历史采样查询条件
+type StreamSampleQuery struct {
+	OrangePiID int64
+	PathName   string
+	Since      time.Time
+	Limit      int
+}
+
+// ChannelStreamStatus 楼栋中一个 NVR 通道的推流状态（取绑定设备上同名路径的最近采样）
+type ChannelStreamStatus struct {
+	NVRID         int64      `json:"nvr_id"`
+	NVRName       string     `json:"nvr_name"`
+	Channel       int        `json:"channel"`
+	PathName      string     `json:"path_name"`
+	Status        string     `json:"status"` // up / down / unknown
+	OrangePiID    int64      `json:"orangepi_id,omitempty"`
+	Readers       int        `json:"readers"`
+	BytesReceived int64      `json:"bytes_received"`
+	BytesSent     int64      `json:"bytes_sent"`
+	SampledAt     *time.Time `json:"sampled_at"`
+}
+
+// StreamService 通过 agent 的 /api/device/mediamtx/paths（转发 MediaMTX /v3/paths/list）获取路径状态并定期采样
+type StreamService struct {
+	db              *gorm.DB
+	orangePiService *OrangePiService
+	interval        time.Duration
+	retention       time.Duration
+}
+
+// 0. NewStreamService 构造函数
+// STREAM_SAMPLE_INTERVAL_MINUTES 控制采样周期（默认 5，0 表示关闭），STREAM_SAMPLE_RETENTION_DAYS 控制采样保留天数（默认 7）
+func NewStreamService(db *gorm.DB, orangePiService *OrangePiService) *StreamService {
+	minutes := 5
+	if val := os.Getenv("STREAM_SAMPLE_INTERVAL_MINUTES"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			minutes = parsed
+		} else {
+			log.Printf("Warning: invalid STREAM_SAMPLE_INTERVAL_MINUTES=%q, using %d", val, minutes)
+		}
+	}
+	days := 7
+	if val, err := strconv.Atoi(getenvDefault("STREAM_SAMPLE_RETENTION_DAYS", "7")); err == nil && val > 0 {
+		days = val
+	}
+
+	return &StreamService{
+		db:              db,
+		orangePiService: orangePiService,
+		interval:        time.Duration(minutes) * time.Minute,
+		retention:       time.Duration(days) * 24 * time.Hour,
+	}
+}
+
+// 1. Start 启动定时采样（所有已部署设备）并清理过期采样，ctx 结束时停止
+func (s *StreamService) Start(ctx context.Context) {
+	if s.interval <= 0 {
+		log.Println("stream sampling disabled (STREAM_SAMPLE_INTERVAL_MINUTES=0)")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.sampleAll(ctx)
+				if err := s.db.WithContext(ctx).
+					Where("sampled_at < ?", time.Now().Add(-s.retention)).
+					Delete(&models.StreamSample{}).Error; err != nil {
+					log.Printf("stream sampling: failed to prune samples: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// 2. Paths 实时获取设备上所有 MediaMTX 路径的状态，并记录为一次采样
+func (s *StreamService) Paths(ctx context.Context, id int64) ([]RemotePathState, error) {
+	var device models.OrangePi
+	if err := s.db.WithContext(ctx).First(&device, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrangePiNotFound
+		}
+		return nil, err
+	}
+	// 端口上应答的不是该设备时，路径状态属于别的设备
+	if device.DeviceIDMismatch {
+		return nil, ErrDeviceIDMismatch
+	}
+
+	var out struct {
+		Items []mediaMTXPath `json:"items"`
+	}
+	if err := s.orangePiService.callRemote(ctx, device.ICCTVAuthServiceRemotePort, http.MethodGet, "/api/device/mediamtx/paths", nil, 15*time.Second, &out); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	paths := make([]RemotePathState, 0, len(out.Items))
+	samples := make([]models.StreamSample, 0, len(out.Items))
+	for _, item := range out.Items {
+		state := RemotePathState{
+			Name:          item.Name,
+			Ready:         item.Ready,
+			ReadyTime:     item.ReadyTime,
+			Tracks:        item.Tracks,
+			Readers:       len(item.Readers),
+			BytesReceived: item.BytesReceived,
+			BytesSent:     item.BytesSent,
+		}
+		if item.Source != nil {
+			state.SourceType = item.Source.Type
+		}
+		paths = append(paths, state)
+		samples = append(samples, models.StreamSample{
+			OrangePiID:    device.ID,
+			PathName:      state.Name,
+			SourceType:    state.SourceType,
+			Ready:         state.Ready,
+			ReadyTime:     state.ReadyTime,
+			Readers:       state.Readers,
+			BytesReceived: state.BytesReceived,
+			BytesSent:     state.BytesSent,
+			SampledAt:     now,
+		})
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i].Name < paths[j].Name })
+
+	if len(samples) > 0 {
+		if err := s.db.WithContext(ctx).CreateInBatches(&samples, 100).Error; err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+// 3. Samples 查询设备的历史采样（按时间倒序），默认最近 24 小时、最多 500 条
+func (s *StreamService) Samples(ctx context.Context, query StreamSampleQuery) ([]models.StreamSample, error) {
+	if query.Since.IsZero() {
+		query.Since = time.Now().Add(-24 * time.Hour)
+	}
+	if query.Limit <= 0 || query.Limit > 5000 {
+		query.Limit = 500
+	}
+
+	tx := s.db.WithContext(ctx).
+		Where("orangepi_id = ? AND sampled_at >= ?", query.OrangePiID, query.Since)
+	if query.PathName != "" {
+		tx = tx.Where("path_name = ?", query.PathName)
+	}
+	var items []models.StreamSample
+	if err := tx.Order("sampled_at desc, id desc").Limit(query.Limit).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// 4. BuildingStreams 楼栋每个 NVR 通道的推流状态
+// 通道 N 对应 MediaMTX 路径 channelN；最近采样超过 3 个采样周期（至少 15 分钟）视为未知
+func (s *StreamService) BuildingStreams(ctx context.Context, buildingID int64) ([]ChannelStreamStatus, error) {
+	var building models.Building
+	if err := s.db.WithContext(ctx).First(&building, buildingID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBuildingNotFound
+		}
+		return nil, err
+	}
+
+	var nvrs []models.NVR
+	if err := s.db.WithContext(ctx).Where("building_id = ?", buildingID).Order("id asc").Find(&nvrs).Error; err != nil {
+		return nil, err
+	}
+	var deviceIDs []int64
+	if err := s.db.WithContext(ctx).Model(&models.OrangePi{}).
+		Where("building_id = ?", building.ID).
+		Pluck("id", &deviceIDs).Error; err != nil {
+		return nil, err
+	}
+
+	stale := 3 * s.interval
+	if stale < 15*time.Minute {
+		stale = 15 * time.Minute
+	}
+	freshSince := time.Now().Add(-stale)
+
+	// 每个路径只取绑定设备上最新的一条采样
+	latest := make(map[string]models.StreamSample)
+	if len(deviceIDs) > 0 {
+		var samples []models.StreamSample
+		if err := s.db.WithContext(ctx).
+			Where("orangepi_id IN ? AND sampled_at >= ?", deviceIDs, freshSince).
+			Order("sampled_at desc, id desc").
+			Find(&samples).Error; err != nil {
+			return nil, err
+		}
+		for _, sample := range samples {
+			if _, ok := latest[sample.PathName]; !ok {
+				latest[sample.PathName] = sample
+			}
+		}
+	}
+
+	statuses := make([]ChannelStreamStatus, 0)
+	for _, nvr := range nvrs {
+		for _, ch := range nvr.RTSPUrls {
+			status := ChannelStreamStatus{
+				NVRID:    nvr.ID,
+				NVRName:  nvr.Name,
+				Channel:  ch.Channel,
+				PathName: mediaMTXPathName(ch.Channel),
+				Status:   models.StreamStatusUnknown,
+			}
+			if sample, ok := latest[status.PathName]; ok {
+				sampledAt := sample.SampledAt
+				status.Status = models.StreamStatusDown
+				if sample.Ready {
+					status.Status = models.StreamStatusUp
+				}
+				status.OrangePiID = sample.OrangePiID
+				status.Readers = sample.Readers
+				status.BytesReceived = sample.BytesReceived
+				status.BytesSent = sample.BytesSent
+				status.SampledAt = &sampledAt
+			}
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, nil
+}
+
+// sampleAll 定时采样所有已部署设备（维护、故障等状态不巡检）
+func (s *StreamService) sampleAll(ctx context.Context) {
+	var ids []int64
+	if err := s.db.WithContext(ctx).Model(&models.OrangePi{}).
+		Where("lifecycle_state IN ?", models.LifecycleServingStates).Pluck("id", &ids).Error; err != nil {
+		log.Printf("stream sampling: failed to list devices: %v", err)
+		return
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed int
+	)
+	sem := make(chan struct{}, reconcileConcurrency)
+	for _, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(id int64) {
+			defer func() { <-sem; wg.Done() }()
+			if _, err := s.Paths(ctx, id); err != nil {
+				mu.Lock()
+				failed++
+				mu.Unlock()
+			}
+		}(id)
+	}
+	wg.Wait()
+	if failed > 0 {
+		log.Printf("stream sampling: %d of %d devices failed", failed, len(ids))
+	}
+}
+
+// mediaMTXPathName NVR 通道在 OrangePi MediaMTX 上的路径名
+func mediaMTXPathName(channel int) string {
+	return fmt.Sprintf("channel%d", channel)
+}
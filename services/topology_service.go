@@ -0,0 +1,252 @@
+package services
+
+// TopologyService Methods:
+//0. NewTopologyService(db *gorm.DB, orangePiService *OrangePiService, streamService *StreamService) -> 初始化楼栋拓扑服务（从环境变量读取健康状态缓存时间）
+//1. Building(ctx context.Context, id int64, refresh bool, scope *SiteScope) -> 楼栋拓扑：设备及健康状态、NVR 及通道推流状态、统计
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"icctv-http-service/models"
+
+	"gorm.io/gorm"
+)
+
+// 设备健康状态
+const (
+	TopologyHealthHealthy     = "healthy"     // /health 返回 healthy
+	TopologyHealthUnhealthy   = "unhealthy"   // /health 可访问但状态不是 healthy
+	TopologyHealthUnreachable = "unreachable" // /health 访问失败
+	TopologyHealthSkipped     = "skipped"     // 非服务状态（维护、故障等）的设备不检查
+)
+
+// topologyHealthTimeout 拓扑中单台设备健康检查的超时时间
+const topologyHealthTimeout = 5 * time.Second
+
+// defaultTopologyHealthCacheSeconds 设备健康状态的默认缓存时间（秒）
+const defaultTopologyHealthCacheSeconds = 30
+
+// TopologyServiceInterface 定义楼栋拓扑能力
+type TopologyServiceInterface interface {
+	Building(ctx context.Context, id int64, refresh bool, scope *SiteScope) (*BuildingTopology, error) //1.楼栋拓扑
+}
+
+// TopologyHealth 设备健康状态（Cached 表示来自缓存，CheckedAt 为实际检查时间）
+type TopologyHealth struct {
+	Status    string              `json:"status"`
+	Detail    *RemoteHealthStatus `json:"detail,omitempty"`
+	Error     string              `json:"error,omitempty"`
+	CheckedAt *time.Time          `json:"checked_at"`
+	Cached    bool                `json:"cached"`
+}
+
+// TopologyOrangePi 楼栋绑定的设备及健康状态
+type TopologyOrangePi struct {
+	models.OrangePi
+	Health TopologyHealth `json:"health"`
+}
+
+// TopologyNVR 楼栋的 NVR 及每个通道的推流状态（不返回 NVR 账户信息）
+type TopologyNVR struct {
+	ID       int64                 `json:"id"`
+	Name     string                `json:"name"`
+	URL      string                `json:"url"`
+	Channels []ChannelStreamStatus `json:"channels"`
+}
+
+// TopologyCounts 楼栋拓扑统计
+type TopologyCounts struct {
+	OrangePis        int `json:"orangepis"`
+	OrangePisHealthy int `json:"orangepis_healthy"`
+	NVRs             int `json:"nvrs"`
+	Channels         int `json:"channels"`
+	ChannelsUp       int `json:"channels_up"`
+	ChannelsDown     int `json:"channels_down"`
+	ChannelsUnknown  int `json:"channels_unknown"`
+}
+
+// BuildingTopology 楼栋拓扑
+type BuildingTopology struct {
+	Building    models.Building    `json:"building"`
+	OrangePis   []TopologyOrangePi `json:"orangepis"`
+	NVRs        []TopologyNVR      `json:"nvrs"`
+	Counts      TopologyCounts     `json:"counts"`
+	GeneratedAt time.Time          `json:"generated_at"`
+}
+
+// topologyHealthEntry 缓存的设备健康检查结果
+type topologyHealthEntry struct {
+	health    TopologyHealth
+	authPort  int
+	checkedAt time.Time
+}
+
+// TopologyService 汇总楼栋的设备、NVR 与通道状态，设备健康检查并发受限并缓存结果
+type TopologyService struct {
+	db              *gorm.DB
+	orangePiService *OrangePiService
+	streamService   *StreamService
+	cacheTTL        time.Duration
+
+	mu     sync.Mutex
+	health map[int64]topologyHealthEntry
+}
+
+// 0. NewTopologyService 构造函数，TOPOLOGY_HEALTH_CACHE_SECONDS 为设备健康状态的缓存时间（默认 30，0 表示不缓存）
+func NewTopologyService(db *gorm.DB, orangePiService *OrangePiService, streamService *StreamService) *TopologyService {
+	seconds := defaultTopologyHealthCacheSeconds
+	if val := os.Getenv("TOPOLOGY_HEALTH_CACHE_SECONDS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			seconds = parsed
+		} else {
+			log.Printf("Warning: invalid TOPOLOGY_HEALTH_CACHE_SECONDS=%q, using %d", val, seconds)
+		}
+	}
+	return &TopologyService{
+		db:              db,
+		orangePiService: orangePiService,
+		streamService:   streamService,
+		cacheTTL:        time.Duration(seconds) * time.Second,
+		health:          make(map[int64]topologyHealthEntry),
+	}
+}
+
+// 1. Building 楼栋拓扑：绑定的设备（服务状态的设备并发检查健康，缓存期内复用结果，refresh 为 true 时重新检查）、
+// NVR 及每个通道的推流状态（取定时采样结果）与统计；scope 不为空时楼栋不在范围内返回 ErrBuildingNotFound，并去掉范围外的通道
+func (s *TopologyService) Building(ctx context.Context, id int64, refresh bool, scope *SiteScope) (*BuildingTopology, error) {
+	if !scope.AllowsBuilding(id) {
+		return nil, ErrBuildingNotFound
+	}
+	topology := &BuildingTopology{
+		OrangePis:   []TopologyOrangePi{},
+		NVRs:        []TopologyNVR{},
+		GeneratedAt: time.Now(),
+	}
+	if err := s.db.WithContext(ctx).First(&topology.Building, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBuildingNotFound
+		}
+		return nil, err
+	}
+
+	var devices []models.OrangePi
+	if err := s.db.WithContext(ctx).Where("building_id = ?", id).Order("id asc").Find(&devices).Error; err != nil {
+		return nil, err
+	}
+	var nvrs []models.NVR
+	if err := s.db.WithContext(ctx).Where("building_id = ?", id).Order("id asc").Find(&nvrs).Error; err != nil {
+		return nil, err
+	}
+	streams, err := s.streamService.BuildingStreams(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	healths := s.checkHealth(ctx, devices, refresh)
+	for i, device := range devices {
+		topology.OrangePis = append(topology.OrangePis, TopologyOrangePi{OrangePi: device, Health: healths[i]})
+		if healths[i].Status == TopologyHealthHealthy {
+			topology.Counts.OrangePisHealthy++
+		}
+	}
+	topology.Counts.OrangePis = len(devices)
+
+	for _, nvr := range nvrs {
+		item := TopologyNVR{ID: nvr.ID, Name: nvr.Name, URL: nvr.URL, Channels: []ChannelStreamStatus{}}
+		for _, stream := range streams {
+			if stream.NVRID != nvr.ID || !scope.AllowsChannel(id, stream.PathName) {
+				continue
+			}
+			item.Channels = append(item.Channels, stream)
+			topology.Counts.Channels++
+			switch stream.Status {
+			case models.StreamStatusUp:
+				topology.Counts.ChannelsUp++
+			case models.StreamStatusDown:
+				topology.Counts.ChannelsDown++
+			default:
+				topology.Counts.ChannelsUnknown++
+			}
+		}
+		topology.NVRs = append(topology.NVRs, item)
+	}
+	topology.Counts.NVRs = len(nvrs)
+	return topology, nil
+}
+
+// checkHealth 并发（最多 reconcileConcurrency 台）检查设备健康，缓存期内且端口未变化的结果直接复用
+func (s *TopologyService) checkHealth(ctx context.Context, devices []models.OrangePi, refresh bool) []TopologyHealth {
+	results := make([]TopologyHealth, len(devices))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, reconcileConcurrency)
+	for i, device := range devices {
+		if !slices.Contains(models.LifecycleServingStates, device.LifecycleState) {
+			results[i] = TopologyHealth{Status: TopologyHealthSkipped}
+			continue
+		}
+		if !refresh {
+			if health, ok := s.cachedHealth(device); ok {
+				results[i] = health
+				continue
+			}
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, device models.OrangePi) {
+			defer func() { <-sem; wg.Done() }()
+			results[i] = s.fetchHealth(ctx, device)
+		}(i, device)
+	}
+	wg.Wait()
+	return results
+}
+
+// cachedHealth 读取未过期的健康检查结果
+func (s *TopologyService) cachedHealth(device models.OrangePi) (TopologyHealth, bool) {
+	if s.cacheTTL <= 0 {
+		return TopologyHealth{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.health[device.ID]
+	if !ok || entry.authPort != device.ICCTVAuthServiceRemotePort || time.Since(entry.checkedAt) > s.cacheTTL {
+		return TopologyHealth{}, false
+	}
+	health := entry.health
+	health.Cached = true
+	return health, true
+}
+
+// fetchHealth 访问设备 /health 并写入缓存
+func (s *TopologyService) fetchHealth(ctx context.Context, device models.OrangePi) TopologyHealth {
+	checkedAt := time.Now()
+	health := TopologyHealth{CheckedAt: &checkedAt}
+	status, err := s.orangePiService.fetchHealth(ctx, device.ICCTVAuthServiceRemotePort, topologyHealthTimeout)
+	switch {
+	case err != nil:
+		health.Status = TopologyHealthUnreachable
+		health.Error = err.Error()
+	case status.Status == "healthy":
+		health.Status = TopologyHealthHealthy
+		health.Detail = status
+	default:
+		health.Status = TopologyHealthUnhealthy
+		health.Detail = status
+	}
+
+	// 请求被取消时的失败结果不缓存
+	if s.cacheTTL > 0 && ctx.Err() == nil {
+		s.mu.Lock()
+		s.health[device.ID] = topologyHealthEntry{health: health, authPort: device.ICCTVAuthServiceRemotePort, checkedAt: checkedAt}
+		s.mu.Unlock()
+	}
+	return health
+}
@@ -0,0 +1,464 @@
+package services
+
+// TrashService Methods:
+//0. NewTrashService(db *gorm.DB, portPool *PortPoolService, auditService *AuditService) -> 初始化回收站服务
+//1. Start(ctx context.Context) -> 启动保留期清理，超过 TRASH_RETENTION_DAYS 的记录彻底删除
+//2. List(ctx context.Context, resource string) -> 查询回收站中某类资源的已删除记录
+//3. Restore(ctx context.Context, resource string, id int64, actor Actor) -> 恢复已删除记录（检查唯一性冲突）
+//4. Purge(ctx context.Context, resource string, id int64, actor Actor) -> 彻底删除回收站中的记录
+//5. PurgeExpired(ctx context.Context) -> 彻底删除超过保留期的记录
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"icctv-http-service/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTrashResourceUnknown = errors.New("unknown trash resource, expected building, orangepi, nvr or admin")
+	ErrTrashItemNotFound    = errors.New("trash item not found")
+	ErrRestoreConflict      = errors.New("restore conflicts with an existing record")
+	ErrInTrash              = errors.New("value is used by a deleted record, restore or purge it from trash first")
+)
+
+// 回收站支持的资源类型
+const (
+	TrashBuilding = "building"
+	TrashOrangePi = "orangepi"
+	TrashNVR      = "nvr"
+	TrashAdmin    = "admin"
+)
+
+// trashResources 保留期清理的顺序：先设备后建筑
+var trashResources = []string{TrashOrangePi, TrashNVR, TrashBuilding, TrashAdmin}
+
+// trashPurgeInterval 保留期清理的执行间隔
+const trashPurgeInterval = time.Hour
+
+// TrashServiceInterface 定义回收站能力
+type TrashServiceInterface interface {
+	Start(ctx context.Context)                                                               //1.启动保留期清理
+	List(ctx context.Context, resource string) ([]TrashItem, error)                          //2.回收站列表
+	Restore(ctx context.Context, resource string, id int64, actor Actor) (*TrashItem, error) //3.恢复记录
+	Purge(ctx context.Context, resource string, id int64, actor Actor) error                 //4.彻底删除
+	PurgeExpired(ctx context.Context) (map[string]int, error)                                //5.清理过期记录
+}
+
+// TrashItem 回收站中的记录
+type TrashItem struct {
+	Resource  string      `json:"resource"`
+	ID        int64       `json:"id"`
+	Name      string      `json:"name"`
+	DeletedAt *time.Time  `json:"deleted_at"`
+	PurgeAt   *time.Time  `json:"purge_at"` // 保留期到期后自动彻底删除的时间（未开启保留期清理时为空）
+	Record    interface{} `json:"record"`
+}
+
+// TrashService 回收站：所有模型软删除，回收站可查看、恢复和彻底删除已删除的建筑、设备、NVR 与管理员
+type TrashService struct {
+	db           *gorm.DB
+	portPool     *PortPoolService
+	auditService *AuditService
+	retention    time.Duration
+}
+
+// 0. NewTrashService 构造函数，TRASH_RETENTION_DAYS 为已删除记录的保留天数（0 不自动清理）
+func NewTrashService(db *gorm.DB, portPool *PortPoolService, auditService *AuditService) *TrashService {
+	days := 30
+	if val := os.Getenv("TRASH_RETENTION_DAYS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			days = parsed
+		} else {
+			log.Printf("Warning: invalid TRASH_RETENTION_DAYS=%q, using %d", val, days)
+		}
+	}
+
+	return &TrashService{
+		db:           db,
+		portPool:     portPool,
+		auditService: auditService,
+		retention:    time.Duration(days) * 24 * time.Hour,
+	}
+}
+
+// 1. Start 启动保留期清理（启动时执行一次，之后每小时执行），ctx 结束时停止
+func (s *TrashService) Start(ctx context.Context) {
+	if s.retention <= 0 {
+		log.Println("trash retention disabled (TRASH_RETENTION_DAYS=0)")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+		for {
+			if _, err := s.PurgeExpired(ctx); err != nil {
+				log.Printf("trash: failed to purge expired records: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// 2. List 查询回收站中某类资源的已删除记录（最近删除的在前）
+func (s *TrashService) List(ctx context.Context, resource string) ([]TrashItem, error) {
+	tx := s.db.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at desc")
+	items := make([]TrashItem, 0)
+	switch resource {
+	case TrashBuilding:
+		var rows []models.Building
+		if err := tx.Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			items = append(items, s.item(resource, row.ModelFields, row.Name, row))
+		}
+	case TrashOrangePi:
+		var rows []models.OrangePi
+		if err := tx.Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			items = append(items, s.item(resource, row.ModelFields, row.Name, row))
+		}
+	case TrashNVR:
+		var rows []models.NVR
+		if err := tx.Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			items = append(items, s.item(resource, row.ModelFields, row.Name, row))
+		}
+	case TrashAdmin:
+		var rows []models.Adminer
+		if err := tx.Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			items = append(items, s.item(resource, row.ModelFields, row.Username, row))
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrTrashResourceUnknown, resource)
+	}
+	return items, nil
+}
+
+// 3. Restore 恢复已删除记录：唯一字段（ismartId、device_id、设备凭证、端口、用户名）已被其他记录使用时返回冲突；
+// 设备或 NVR 原来绑定的建筑已删除时恢复为未绑定
+func (s *TrashService) Restore(ctx context.Context, resource string, id int64, actor Actor) (*TrashItem, error) {
+	var item TrashItem
+	// 恢复设备会重新占用其端口，持有端口池锁
+	err := s.portPool.Transaction(ctx, func(tx *gorm.DB) error {
+		switch resource {
+		case TrashBuilding:
+			var building models.Building
+			if err := findTrashed(tx, &building, id); err != nil {
+				return err
+			}
+			if err := checkRestoreConflict(tx, &models.Building{}, id, "ismart_id", building.ISmartID); err != nil {
+				return err
+			}
+			var count int64
+			if err := tx.Model(&models.BuildingAlias{}).
+				Where("ismart_id = ? AND building_id <> ? AND expires_at > ?", building.ISmartID, id, time.Now()).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("%w: ismart_id %s is an alias of another building", ErrRestoreConflict, building.ISmartID)
+			}
+			if err := restoreTrashed(tx, &building); err != nil {
+				return err
+			}
+			item = s.item(resource, building.ModelFields, building.Name, building)
+		case TrashOrangePi:
+			var device models.OrangePi
+			if err := findTrashed(tx, &device, id); err != nil {
+				return err
+			}
+			if device.DeviceID != nil {
+				if err := checkRestoreConflict(tx, &models.OrangePi{}, id, "device_id", *device.DeviceID); err != nil {
+					return err
+				}
+			}
+			if device.DeviceKey != "" {
+				if err := checkRestoreConflict(tx, &models.OrangePi{}, id, "device_key", device.DeviceKey); err != nil {
+					return err
+				}
+			}
+			// 端口已释放（替换后的旧设备）时不检查
+			if device.ICCTVAuthServiceRemotePort != 0 || device.SSHRemotePort != 0 {
+				if err := s.portPool.Validate(tx, id, device.ICCTVAuthServiceRemotePort, device.SSHRemotePort); err != nil {
+					return fmt.Errorf("%w: %v", ErrRestoreConflict, err)
+				}
+			}
+			if err := restoreTrashed(tx, &device); err != nil {
+				return err
+			}
+			// 原建筑仍存在（包括期间被删除后又恢复）时保留绑定，否则解绑；绑定历史从最近一条事件续写（随建筑级联删除时已记录解绑）
+			if previous := device.BuildingID; previous != nil {
+				exists, err := buildingExists(tx, *previous)
+				if err != nil {
+					return err
+				}
+				if !exists {
+					if err := tx.Model(&device).Update("building_id", nil).Error; err != nil {
+						return err
+					}
+					if err := recordRestoredBinding(tx, models.BindingDeviceOrangePi, id, *previous, 0, "restored, building deleted", actor.Username); err != nil {
+						return err
+					}
+					device.BuildingID = nil
+				} else if err := recordRestoredBinding(tx, models.BindingDeviceOrangePi, id, *previous, *previous, "restored", actor.Username); err != nil {
+					return err
+				}
+			}
+			item = s.item(resource, device.ModelFields, device.Name, device)
+		case TrashNVR:
+			var nvr models.NVR
+			if err := findTrashed(tx, &nvr, id); err != nil {
+				return err
+			}
+			if err := restoreTrashed(tx, &nvr); err != nil {
+				return err
+			}
+			// 与 OrangePi 相同：检查原建筑并续写绑定历史
+			if previous := nvr.BuildingID; previous != 0 {
+				exists, err := buildingExists(tx, previous)
+				if err != nil {
+					return err
+				}
+				if !exists {
+					if err := tx.Model(&nvr).Update("building_id", 0).Error; err != nil {
+						return err
+					}
+					if err := recordRestoredBinding(tx, models.BindingDeviceNVR, id, previous, 0, "restored, building deleted", actor.Username); err != nil {
+						return err
+					}
+					nvr.BuildingID = 0
+				} else if err := recordRestoredBinding(tx, models.BindingDeviceNVR, id, previous, previous, "restored", actor.Username); err != nil {
+					return err
+				}
+			}
+			item = s.item(resource, nvr.ModelFields, nvr.Name, nvr)
+		case TrashAdmin:
+			var admin models.Adminer
+			if err := findTrashed(tx, &admin, id); err != nil {
+				return err
+			}
+			if err := checkRestoreConflict(tx, &models.Adminer{}, id, "username", admin.Username); err != nil {
+				return err
+			}
+			if err := restoreTrashed(tx, &admin); err != nil {
+				return err
+			}
+			item = s.item(resource, admin.ModelFields, admin.Username, admin)
+		default:
+			return fmt.Errorf("%w: %s", ErrTrashResourceUnknown, resource)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrTrashResourceUnknown) || errors.Is(err, ErrTrashItemNotFound) {
+		return nil, err
+	}
+
+	s.record(ctx, actor, "trash.restore", resource, id, err)
+	if err != nil {
+		return nil, err
+	}
+	item.DeletedAt, item.PurgeAt = nil, nil
+	return &item, nil
+}
+
+// 4. Purge 彻底删除回收站中的记录（只能删除已软删除的记录），建筑的 ismartId 别名、楼层/区域与站点授权，设备的同步状态一并删除
+func (s *TrashService) Purge(ctx context.Context, resource string, id int64, actor Actor) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return purgeTrashed(tx, resource, id)
+	})
+	if errors.Is(err, ErrTrashResourceUnknown) || errors.Is(err, ErrTrashItemNotFound) {
+		return err
+	}
+	s.record(ctx, actor, "trash.purge", resource, id, err)
+	return err
+}
+
+// 5. PurgeExpired 彻底删除删除时间早于保留期的记录，返回每类资源删除的数量
+func (s *TrashService) PurgeExpired(ctx context.Context) (map[string]int, error) {
+	purged := make(map[string]int, len(trashResources))
+	if s.retention <= 0 {
+		return purged, nil
+	}
+
+	cutoff := time.Now().Add(-s.retention)
+	total := 0
+	for _, resource := range trashResources {
+		var ids []int64
+		if err := s.db.WithContext(ctx).Unscoped().Model(trashModel(resource)).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+			Pluck("id", &ids).Error; err != nil {
+			return purged, err
+		}
+		for _, id := range ids {
+			if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				return purgeTrashed(tx, resource, id)
+			}); err != nil {
+				log.Printf("trash: failed to purge %s %d: %v", resource, id, err)
+				continue
+			}
+			purged[resource]++
+			total++
+		}
+	}
+
+	if total > 0 {
+		log.Printf("trash: purged %d records deleted before %s", total, cutoff.Format(time.RFC3339))
+		s.auditService.Record(ctx, Actor{Username: "system"}, models.AuditLog{
+			Action:     "trash.retention",
+			TargetType: "trash",
+			Detail:     map[string]interface{}{"cutoff": cutoff, "purged": purged},
+			Success:    true,
+		})
+	}
+	return purged, nil
+}
+
+// item 组装回收站记录，保留期清理开启时计算自动删除时间
+func (s *TrashService) item(resource string, fields models.ModelFields, name string, record interface{}) TrashItem {
+	item := TrashItem{Resource: resource, ID: fields.ID, Name: name, Record: record}
+	if fields.DeletedAt.Valid {
+		deletedAt := fields.DeletedAt.Time
+		item.DeletedAt = &deletedAt
+		if s.retention > 0 {
+			purgeAt := deletedAt.Add(s.retention)
+			item.PurgeAt = &purgeAt
+		}
+	}
+	return item
+}
+
+// record 写入回收站操作的审计日志
+func (s *TrashService) record(ctx context.Context, actor Actor, action, resource string, id int64, err error) {
+	entry := models.AuditLog{
+		Action:     action,
+		TargetType: resource,
+		TargetID:   id,
+		Success:    err == nil,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	s.auditService.Record(ctx, actor, entry)
+}
+
+// trashModel 资源类型对应的模型
+func trashModel(resource string) interface{} {
+	switch resource {
+	case TrashBuilding:
+		return &models.Building{}
+	case TrashOrangePi:
+		return &models.OrangePi{}
+	case TrashNVR:
+		return &models.NVR{}
+	case TrashAdmin:
+		return &models.Adminer{}
+	}
+	return nil
+}
+
+// findTrashed 查询已软删除的记录
+func findTrashed(tx *gorm.DB, dest interface{}, id int64) error {
+	if err := tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(dest).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %d", ErrTrashItemNotFound, id)
+		}
+		return err
+	}
+	return nil
+}
+
+// restoreTrashed 清除记录的删除时间
+func restoreTrashed(tx *gorm.DB, record interface{}) error {
+	return tx.Unscoped().Model(record).Update("deleted_at", nil).Error
+}
+
+// purgeTrashed 彻底删除已软删除的记录及只属于该记录的状态数据
+func purgeTrashed(tx *gorm.DB, resource string, id int64) error {
+	model := trashModel(resource)
+	if model == nil {
+		return fmt.Errorf("%w: %s", ErrTrashResourceUnknown, resource)
+	}
+	if err := findTrashed(tx, model, id); err != nil {
+		return err
+	}
+
+	switch resource {
+	case TrashBuilding:
+		if err := tx.Unscoped().Where("building_id = ?", id).Delete(&models.BuildingAlias{}).Error; err != nil {
+			return err
+		}
+		// 建筑的楼层/区域节点及建筑、这些节点上的授权
+		if err := tx.Unscoped().Where("building_id = ? OR site_id IN (?)", id,
+			tx.Unscoped().Model(&models.Site{}).Select("id").Where("building_id = ?", id)).
+			Delete(&models.SiteGrant{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("building_id = ?", id).Delete(&models.Site{}).Error; err != nil {
+			return err
+		}
+	case TrashOrangePi:
+		if err := tx.Unscoped().Where("orangepi_id = ?", id).Delete(&models.MediaMTXSyncState{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("orangepi_id = ?", id).Delete(&models.DeviceDrift{}).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Unscoped().Delete(model).Error
+}
+
+// checkRestoreConflict 唯一字段的值已被其他未删除的记录使用时返回 ErrRestoreConflict
+func checkRestoreConflict(tx *gorm.DB, model interface{}, id int64, column, value string) error {
+	var ids []int64
+	if err := tx.Model(model).Where(column+" = ? AND id <> ?", value, id).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		return fmt.Errorf("%w: %s %q is used by record %d", ErrRestoreConflict, column, value, ids[0])
+	}
+	return nil
+}
+
+// checkNotInTrash 唯一字段的值被回收站中的记录占用时返回 ErrInTrash（唯一索引包含已删除的记录，需先恢复或彻底删除）
+func checkNotInTrash(tx *gorm.DB, model interface{}, column, value string) error {
+	var ids []int64
+	if err := tx.Unscoped().Model(model).
+		Where(column+" = ? AND deleted_at IS NOT NULL", value).
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		return fmt.Errorf("%w: %s %q is used by deleted record %d", ErrInTrash, column, value, ids[0])
+	}
+	return nil
+}
+
+// buildingExists 建筑是否存在且未删除（删除后又恢复的建筑视为存在），查询失败时返回错误而不是视为不存在
+func buildingExists(tx *gorm.DB, id int64) (bool, error) {
+	var count int64
+	if err := tx.Model(&models.Building{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}